@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/api"
 	"github.com/user/airpollution/internal/db"
 	"github.com/user/airpollution/internal/services/kafka"
 	"github.com/user/airpollution/internal/services/websocket"
@@ -100,6 +101,12 @@ func main() {
 		c.JSON(http.StatusOK, anomalies)
 	})
 
+	// Current air quality index of each location on the computed scales
+	api.NewAQIHandler(database).RegisterRoutes(router)
+
+	// Read-only OGC SensorThings API over the stored readings
+	api.NewSensorThingsHandler(database).WithBaseURL(getEnv("STA_BASE_URL", "")).RegisterRoutes(router)
+
 	// Create HTTP server
 	srv := &http.Server{
 		Addr:    ":" + port,
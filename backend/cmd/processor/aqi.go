@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/aqi"
+	"github.com/user/airpollution/internal/services/averaging"
+)
+
+// loadScales reads the AQI scales to compute, all supported scales by default
+func loadScales() []aqi.Scale {
+	names := make([]string, len(aqi.Scales))
+	for i, s := range aqi.Scales {
+		names[i] = string(s)
+	}
+
+	scales, err := aqi.ParseScales(getEnv("AQI_SCALES", strings.Join(names, ",")))
+	if err != nil {
+		log.Fatalf("Invalid AQI_SCALES: %v", err)
+	}
+	return scales
+}
+
+// updateIndices computes the indices of the location of a reading from its hourly averages and
+// stores those that changed. Scales without enough complete averages at the location are skipped.
+func (p *processor) updateIndices(ctx context.Context, data *models.AirQualityData) error {
+	location := averaging.Location(data)
+	means := func(parameter string, period averaging.Period) (float64, bool) {
+		mean, ok := p.averages.Mean(parameter, location, period, data.Timestamp, averaging.Requirement{})
+		return mean.Value, ok
+	}
+
+	for _, scale := range p.scales {
+		index, err := aqi.Compute(scale, means)
+		if errors.Is(err, aqi.ErrInsufficientData) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error computing %s index of %s: %v", scale, location, err)
+			continue
+		}
+		index.Location = location
+		index.Latitude = data.Latitude
+		index.Longitude = data.Longitude
+		index.ObservedAt = data.Timestamp
+		index.UpdatedAt = time.Now()
+
+		if !p.indices.changed(index) {
+			continue
+		}
+		err = retry(ctx, "storing air quality index", func() error {
+			return p.database.UpsertAirQualityIndex(index)
+		})
+		if err != nil {
+			return err
+		}
+		p.indices.remember(index)
+	}
+
+	return nil
+}
+
+// indexCache remembers the last stored index of each location and scale, so that readings that
+// leave every sub-index unchanged do not rewrite it. Indices are still rewritten once per clock
+// hour so that their observation time stays current.
+type indexCache struct {
+	mu     sync.Mutex
+	stored map[string]*models.AirQualityIndex
+}
+
+// newIndexCache creates an empty cache
+func newIndexCache() *indexCache {
+	return &indexCache{stored: make(map[string]*models.AirQualityIndex)}
+}
+
+// changed reports whether an index differs from the stored one of its location and scale
+func (c *indexCache) changed(index *models.AirQualityIndex) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored, ok := c.stored[index.Location+"/"+index.Scale]
+	if !ok || stored.Value != index.Value || stored.DominantPollutant != index.DominantPollutant ||
+		len(stored.Pollutants) != len(index.Pollutants) ||
+		!stored.ObservedAt.Truncate(time.Hour).Equal(index.ObservedAt.Truncate(time.Hour)) {
+		return true
+	}
+	for i := range index.Pollutants {
+		if stored.Pollutants[i].Parameter != index.Pollutants[i].Parameter ||
+			stored.Pollutants[i].AveragingPeriod != index.Pollutants[i].AveragingPeriod ||
+			stored.Pollutants[i].Index != index.Pollutants[i].Index {
+			return true
+		}
+	}
+	return false
+}
+
+// remember records an index as stored
+func (c *indexCache) remember(index *models.AirQualityIndex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stored[index.Location+"/"+index.Scale] = index
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/user/airpollution/internal/services/kafka"
+)
+
+// deadLetterFilter selects dead letters by their position and failure
+type deadLetterFilter struct {
+	partition   int
+	offset      int64
+	sourceTopic string
+	errorText   string
+}
+
+// matches reports whether a dead letter is selected by the filter
+func (f deadLetterFilter) matches(dl *kafka.DeadLetter) bool {
+	return (f.partition < 0 || dl.Partition == f.partition) &&
+		(f.offset < 0 || dl.Offset == f.offset) &&
+		(f.sourceTopic == "" || dl.SourceTopic == f.sourceTopic) &&
+		strings.Contains(dl.Error, f.errorText)
+}
+
+// runDeadLetters inspects the dead-letter topic or re-injects its messages and returns the exit code.
+// Usage: processor dlq list|replay [-partition n] [-offset n] [-source-topic topic] [-error text] [-all]
+func runDeadLetters(args []string) int {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "replay") {
+		fmt.Fprintln(os.Stderr, "Usage: processor dlq list|replay [flags]")
+		return 2
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("dlq "+command, flag.ContinueOnError)
+	var filter deadLetterFilter
+	flags.IntVar(&filter.partition, "partition", -1, "Only dead letters in this partition of the dead-letter topic")
+	flags.Int64Var(&filter.offset, "offset", -1, "Only the dead letter at this offset of the dead-letter topic")
+	flags.StringVar(&filter.sourceTopic, "source-topic", "", "Only dead letters from this topic")
+	flags.StringVar(&filter.errorText, "error", "", "Only dead letters whose error contains this text")
+	all := flags.Bool("all", false, "Replay all dead letters when no other filter is given")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: processor dlq %s [flags]\n", command)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// Replaying everything by accident would flood the source topics
+	if command == "replay" && flags.NFlag() == 0 && !*all {
+		fmt.Fprintln(os.Stderr, "Select the dead letters to replay with a filter or -all")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	brokers := []string{getEnv("KAFKA_BROKERS", "localhost:9092")}
+
+	var err error
+	if command == "list" {
+		err = listDeadLetters(ctx, brokers, filter)
+	} else {
+		err = replayDeadLetters(ctx, brokers, filter)
+	}
+	if err != nil {
+		log.Printf("Failed to %s dead letters: %v", command, err)
+		return 1
+	}
+	return 0
+}
+
+// listDeadLetters prints the selected dead letters as JSON lines, with the original message as a string
+func listDeadLetters(ctx context.Context, brokers []string, filter deadLetterFilter) error {
+	encoder := json.NewEncoder(os.Stdout)
+	return kafka.ReadDeadLetters(ctx, brokers, func(dl *kafka.DeadLetter) error {
+		if !filter.matches(dl) {
+			return nil
+		}
+		return encoder.Encode(struct {
+			*kafka.DeadLetter
+			Value string `json:"value"`
+		}{dl, string(dl.Value)})
+	})
+}
+
+// replayDeadLetters writes the original messages of the selected dead letters back to their
+// source topics. The dead letters stay in the dead-letter topic.
+func replayDeadLetters(ctx context.Context, brokers []string, filter deadLetterFilter) error {
+	producers := make(map[string]*kafka.Producer)
+	defer func() {
+		for _, producer := range producers {
+			producer.Close()
+		}
+	}()
+
+	replayed := 0
+	err := kafka.ReadDeadLetters(ctx, brokers, func(dl *kafka.DeadLetter) error {
+		if !filter.matches(dl) {
+			return nil
+		}
+
+		producer, ok := producers[dl.SourceTopic]
+		if !ok {
+			producer = kafka.NewProducer(brokers, dl.SourceTopic)
+			producers[dl.SourceTopic] = producer
+		}
+		if err := producer.ProduceMessages(ctx, [][]byte{dl.Value}); err != nil {
+			return fmt.Errorf("replaying dead letter at partition %d offset %d: %w", dl.Partition, dl.Offset, err)
+		}
+
+		log.Printf("Replayed dead letter at partition %d offset %d to %s", dl.Partition, dl.Offset, dl.SourceTopic)
+		replayed++
+		return nil
+	})
+	log.Printf("Replayed %d dead letters", replayed)
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/user/airpollution/internal/services/anomaly"
+	"github.com/user/airpollution/internal/services/window"
+)
+
+// fileVersion identifies the contents of a file by its modification time and size
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// statVersion returns the version of a file, or the zero version when it cannot be read
+func statVersion(path string) fileVersion {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}
+}
+
+// loadDetectionConfig loads the detection configuration file, or returns the built-in
+// configuration when no file is configured
+func loadDetectionConfig(path string) *anomaly.Config {
+	if path == "" {
+		return anomaly.DefaultConfig()
+	}
+	cfg, err := anomaly.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("Invalid ANOMALY_CONFIG_FILE: %v", err)
+	}
+	log.Printf("Loaded detection configuration from %s", path)
+	return cfg
+}
+
+// watchDetectionConfig reloads the detection configuration on SIGHUP and when the file changes,
+// checked every interval, until the context is cancelled. A configuration that fails to load is
+// logged and the detector keeps the last good one. The windows keep the neighborhood they were
+// created with, so a changed neighborhood is only logged.
+func watchDetectionConfig(ctx context.Context, path string, interval time.Duration, detector *anomaly.Detector, windows window.Config) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	loaded := statVersion(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			log.Printf("Received SIGHUP, reloading detection configuration from %s", path)
+		case <-ticker.C:
+			if statVersion(path) == loaded {
+				continue
+			}
+			log.Printf("Detection configuration %s changed, reloading", path)
+		}
+
+		// Remember the version even when loading fails, so that a bad file is reported once
+		loaded = statVersion(path)
+		cfg, err := anomaly.LoadConfig(path)
+		if err != nil {
+			log.Printf("Keeping the current detection configuration: %v", err)
+			continue
+		}
+		detector.SetConfig(cfg)
+		log.Printf("Reloaded detection configuration from %s", path)
+		if cfg.Neighborhood.Windows(windows) != windows {
+			log.Printf("The neighborhood in %s changed; restart the processor to regroup the detection windows", path)
+		}
+	}
+}
@@ -4,17 +4,33 @@ CREATE EXTENSION IF NOT EXISTS timescaledb CASCADE;
 -- Create air quality data table
 CREATE TABLE IF NOT EXISTS air_quality_data (
     id UUID,
+    sensor_id TEXT,
     latitude FLOAT NOT NULL,
     longitude FLOAT NOT NULL,
     parameter TEXT NOT NULL,
     value FLOAT NOT NULL,
     timestamp TIMESTAMPTZ NOT NULL,
+    unit TEXT,
+    original_value FLOAT,
+    original_unit TEXT,
+    signature_status TEXT,
+    observation_id UUID,
+    humidity FLOAT,
+    temperature FLOAT,
+    altitude FLOAT,
+    sensor_model TEXT,
+    firmware_version TEXT,
+    tags JSONB,
+    lateness TEXT,
     PRIMARY KEY (id, timestamp)
 );
 
 -- Convert to TimescaleDB hypertable
 SELECT create_hypertable('air_quality_data', 'timestamp', if_not_exists => TRUE);
 
+-- Readings of the same multi-parameter observation
+CREATE INDEX IF NOT EXISTS idx_air_quality_observation ON air_quality_data (observation_id, timestamp);
+
 -- Create anomalies table
 CREATE TABLE IF NOT EXISTS anomalies (
     id UUID,
@@ -26,6 +42,8 @@ CREATE TABLE IF NOT EXISTS anomalies (
     detected_at TIMESTAMPTZ NOT NULL,
     air_quality_data_id UUID,
     air_quality_data_timestamp TIMESTAMPTZ,
+    published_at TIMESTAMPTZ,
+    averaging_period TEXT,
     FOREIGN KEY (air_quality_data_id, air_quality_data_timestamp) REFERENCES air_quality_data(id, timestamp),
     PRIMARY KEY (id, detected_at)
 );
@@ -33,8 +51,41 @@ CREATE TABLE IF NOT EXISTS anomalies (
 -- Convert to TimescaleDB hypertable
 SELECT create_hypertable('anomalies', 'detected_at', if_not_exists => TRUE);
 
+-- Create sensors table
+CREATE TABLE IF NOT EXISTS sensors (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    api_key_hash TEXT NOT NULL UNIQUE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    class TEXT NOT NULL DEFAULT 'default',
+    signing_secret TEXT,
+    require_signature BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Create current air quality index table, the latest index of each location on each scale
+CREATE TABLE IF NOT EXISTS current_aqi (
+    location TEXT NOT NULL,
+    scale TEXT NOT NULL,
+    latitude FLOAT NOT NULL,
+    longitude FLOAT NOT NULL,
+    value INTEGER NOT NULL,
+    category TEXT NOT NULL,
+    color TEXT NOT NULL,
+    dominant_pollutant TEXT NOT NULL,
+    pollutants JSONB NOT NULL,
+    observed_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (location, scale)
+);
+
 -- Create indexes for better query performance
 CREATE INDEX IF NOT EXISTS idx_air_quality_location ON air_quality_data (latitude, longitude);
 CREATE INDEX IF NOT EXISTS idx_air_quality_parameter ON air_quality_data (parameter);
+CREATE INDEX IF NOT EXISTS idx_air_quality_sensor ON air_quality_data (sensor_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_air_quality_natural_key ON air_quality_data (sensor_id, parameter, timestamp);
 CREATE INDEX IF NOT EXISTS idx_anomalies_type ON anomalies (type);
-CREATE INDEX IF NOT EXISTS idx_anomalies_parameter ON anomalies (parameter); 
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_anomalies_parameter ON anomalies (parameter);
+CREATE INDEX IF NOT EXISTS idx_anomalies_reading ON anomalies (air_quality_data_id);
+CREATE INDEX IF NOT EXISTS idx_anomalies_id ON anomalies (id); 
\ No newline at end of file
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/aqi"
+)
+
+// AQIStore reads the current air quality indices
+type AQIStore interface {
+	GetCurrentAirQualityIndices(scale, location string) ([]models.AirQualityIndex, error)
+}
+
+// AQIHandler serves the current air quality index of each location
+type AQIHandler struct {
+	store AQIStore
+}
+
+// NewAQIHandler creates a new AQI handler
+func NewAQIHandler(store AQIStore) *AQIHandler {
+	return &AQIHandler{
+		store: store,
+	}
+}
+
+// RegisterRoutes registers the AQI routes to the given router
+func (h *AQIHandler) RegisterRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	group := router.Group("/api/aqi", middleware...)
+	group.GET("", h.ListIndices)
+	group.GET("/:location", h.GetLocationIndices)
+}
+
+// ListIndices godoc
+// @Summary Current air quality indices
+// @Description Get the current air quality index of every location, on every computed scale or on one
+// @Tags aqi
+// @Produce json
+// @Param scale query string false "Scale: us-epa, eu-caqi, uk-daqi or in-naqi"
+// @Success 200 {array} models.AirQualityIndex
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/aqi [get]
+func (h *AQIHandler) ListIndices(c *gin.Context) {
+	scale, ok := h.scale(c)
+	if !ok {
+		return
+	}
+
+	indices, err := h.store.GetCurrentAirQualityIndices(scale, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch air quality indices: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, indices)
+}
+
+// GetLocationIndices godoc
+// @Summary Current air quality index of a location
+// @Description Get the current air quality index of a location, on every computed scale or on one. Locations are sensor IDs, or rounded coordinates such as 52.5200,13.4050 for readings without one.
+// @Tags aqi
+// @Produce json
+// @Param location path string true "Location"
+// @Param scale query string false "Scale: us-epa, eu-caqi, uk-daqi or in-naqi"
+// @Success 200 {array} models.AirQualityIndex
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/aqi/{location} [get]
+func (h *AQIHandler) GetLocationIndices(c *gin.Context) {
+	scale, ok := h.scale(c)
+	if !ok {
+		return
+	}
+
+	indices, err := h.store.GetCurrentAirQualityIndices(scale, c.Param("location"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch air quality indices: " + err.Error(),
+		})
+		return
+	}
+	if len(indices) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No air quality index for this location",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, indices)
+}
+
+// scale reads the optional scale query parameter and responds with 400 when it is not supported
+func (h *AQIHandler) scale(c *gin.Context) (string, bool) {
+	name := c.Query("scale")
+	if name == "" {
+		return "", true
+	}
+
+	scale, err := aqi.ParseScale(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return "", false
+	}
+	return string(scale), true
+}
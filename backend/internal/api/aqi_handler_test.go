@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+)
+
+// MockAQIStore serves fixed indices
+type MockAQIStore struct {
+	indices []models.AirQualityIndex
+}
+
+func (m *MockAQIStore) GetCurrentAirQualityIndices(scale, location string) ([]models.AirQualityIndex, error) {
+	results := []models.AirQualityIndex{}
+	for _, index := range m.indices {
+		if (scale == "" || index.Scale == scale) && (location == "" || index.Location == location) {
+			results = append(results, index)
+		}
+	}
+	return results, nil
+}
+
+func TestAQIHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	observedAt := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+	store := &MockAQIStore{
+		indices: []models.AirQualityIndex{
+			{Location: "station-7", Scale: "us-epa", Value: 56, Category: "Moderate", Color: "#FFFF00", DominantPollutant: "PM2.5",
+				Pollutants: []models.PollutantIndex{{Parameter: "PM2.5", AveragingPeriod: "24h", Concentration: 12.0, Unit: "µg/m³", Index: 56}},
+				ObservedAt: observedAt},
+			{Location: "station-7", Scale: "uk-daqi", Value: 2, Category: "Low", Color: "#31FF00", DominantPollutant: "PM2.5", ObservedAt: observedAt},
+			{Location: "station-8", Scale: "us-epa", Value: 20, Category: "Good", Color: "#00E400", DominantPollutant: "O3", ObservedAt: observedAt},
+		},
+	}
+
+	router := gin.New()
+	NewAQIHandler(store).RegisterRoutes(router)
+
+	get := func(path string) (*httptest.ResponseRecorder, []models.AirQualityIndex) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body []models.AirQualityIndex
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		count  int
+	}{
+		{"All Indices", "/api/aqi", http.StatusOK, 3},
+		{"One Scale", "/api/aqi?scale=US-EPA", http.StatusOK, 2},
+		{"Unsupported Scale", "/api/aqi?scale=aqhi", http.StatusBadRequest, 0},
+		{"One Location", "/api/aqi/station-7", http.StatusOK, 2},
+		{"One Location On One Scale", "/api/aqi/station-7?scale=uk-daqi", http.StatusOK, 1},
+		{"Unknown Location", "/api/aqi/station-9", http.StatusNotFound, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w, body := get(tc.path)
+			if w.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if len(body) != tc.count {
+				t.Errorf("Expected %d indices, got %d", tc.count, len(body))
+			}
+		})
+	}
+
+	// Sub-indices are returned with the index
+	_, body := get("/api/aqi/station-7?scale=us-epa")
+	if len(body) != 1 || len(body[0].Pollutants) != 1 || body[0].Pollutants[0].AveragingPeriod != "24h" {
+		t.Errorf("Expected the PM2.5 sub-index over 24h, got %+v", body)
+	}
+}
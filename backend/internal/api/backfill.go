@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+// BackfillRouter publishes readings of the routed lateness classifications to the backfill topic and
+// all other readings to the raw data topic
+type BackfillRouter struct {
+	live     Publisher
+	backfill Publisher
+	routed   map[string]bool
+}
+
+// NewBackfillRouter creates a publisher that routes readings of the given lateness classifications,
+// such as late readings, to backfill
+func NewBackfillRouter(live, backfill Publisher, lateness ...string) *BackfillRouter {
+	routed := make(map[string]bool)
+	for _, l := range lateness {
+		routed[l] = true
+	}
+	return &BackfillRouter{live: live, backfill: backfill, routed: routed}
+}
+
+// ProduceAirQualityData publishes a reading to the topic matching its lateness
+func (r *BackfillRouter) ProduceAirQualityData(ctx context.Context, data *models.AirQualityData) error {
+	if r.routed[data.Lateness] {
+		return r.backfill.ProduceAirQualityData(ctx, data)
+	}
+	return r.live.ProduceAirQualityData(ctx, data)
+}
+
+// ProduceAirQualityDataBatch splits a batch by lateness and publishes each part to its topic
+func (r *BackfillRouter) ProduceAirQualityDataBatch(ctx context.Context, data []*models.AirQualityData) error {
+	var live, late []*models.AirQualityData
+	for _, d := range data {
+		if r.routed[d.Lateness] {
+			late = append(late, d)
+		} else {
+			live = append(live, d)
+		}
+	}
+
+	if len(live) > 0 {
+		if err := r.live.ProduceAirQualityDataBatch(ctx, live); err != nil {
+			return err
+		}
+	}
+	if len(late) > 0 {
+		return r.backfill.ProduceAirQualityDataBatch(ctx, late)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+func TestBackfillRouterRoutesLateReadings(t *testing.T) {
+	live := &MockPublisher{}
+	backfill := &MockPublisher{}
+	router := NewBackfillRouter(live, backfill, models.LatenessLate)
+
+	onTime := &models.AirQualityData{Parameter: "PM2.5", Lateness: models.LatenessOnTime}
+	late := &models.AirQualityData{Parameter: "PM10", Lateness: models.LatenessLate}
+	outOfOrder := &models.AirQualityData{Parameter: "NO2", Lateness: models.LatenessOutOfOrder}
+
+	if err := router.ProduceAirQualityData(context.Background(), late); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := router.ProduceAirQualityDataBatch(context.Background(), []*models.AirQualityData{onTime, late, outOfOrder}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if live.count() != 2 {
+		t.Errorf("Expected 2 live readings, got %d", live.count())
+	}
+	if backfill.count() != 2 {
+		t.Errorf("Expected 2 backfill readings, got %d", backfill.count())
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+)
+
+// MaxBatchSize is the maximum number of readings accepted in a single batch request
+const MaxBatchSize = 1000
+
+// BatchItemResult represents the outcome for a single item of a batch request
+type BatchItemResult struct {
+	Index     int    `json:"index"`
+	ID        string `json:"id,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchResponse represents the response body for a batch request
+type BatchResponse struct {
+	Accepted   int               `json:"accepted"`
+	Rejected   int               `json:"rejected"`
+	Duplicates int               `json:"duplicates"`
+	Results    []BatchItemResult `json:"results"`
+}
+
+// PostAirQualityDataBatch godoc
+// @Summary Submit a batch of air quality data
+// @Description Submit multiple air quality data points in one request. Each item is validated independently; valid items are queued even if others are rejected. Items that were already submitted are reported as duplicates and not queued again.
+// @Tags data
+// @Accept json
+// @Produce json
+// @Param data body []AirQualityDataRequest true "Air quality data points"
+// @Param Idempotency-Key header string false "Client key identifying the batch"
+// @Success 200 {object} BatchResponse
+// @Success 202 {object} BatchResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /api/data/batch [post]
+func (h *IngestHandler) PostAirQualityDataBatch(c *gin.Context) {
+	// Decode items individually so one malformed item does not reject the whole batch
+	var items []json.RawMessage
+	if err := c.ShouldBindJSON(&items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Batch must contain at least one item",
+		})
+		return
+	}
+
+	if len(items) > MaxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Batch must not contain more than %d items", MaxBatchSize),
+		})
+		return
+	}
+
+	now := time.Now()
+	results, accepted := parseBatchItems(items, provenanceFromContext(c), c.GetHeader(IdempotencyKeyHeader),
+		func(id string) bool { return h.recent.contains(id, now) })
+
+	response := BatchResponse{
+		Accepted: len(accepted),
+		Results:  results,
+	}
+	for _, result := range results {
+		if result.Duplicate {
+			response.Duplicates++
+		} else if result.Error != "" {
+			response.Rejected++
+		}
+	}
+
+	if len(accepted) == 0 {
+		status := http.StatusBadRequest
+		if response.Rejected == 0 {
+			// Every item was a resubmission, which is not an error
+			status = http.StatusOK
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	// Each accepted item counts against the rate limit of the sender
+	if allowed, retryAfter := allowReadings(c, len(accepted)); !allowed {
+		respondRateLimited(c, retryAfter)
+		return
+	}
+
+	// Publish all valid items to Kafka in one write
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.producer.ProduceAirQualityDataBatch(ctx, accepted); err != nil {
+		c.JSON(publishErrorStatus(err), gin.H{
+			"error": "Failed to publish data: " + err.Error(),
+		})
+		return
+	}
+
+	for _, data := range accepted {
+		h.recent.remember(data.ID.String(), time.Now())
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// parseBatchItems validates each raw item and converts the valid ones to domain models stamped with the provenance.
+// Item IDs without a client-supplied ID are derived from the idempotency key and the item index.
+// Items whose ID was already submitted, or appears earlier in the batch, are marked as duplicates.
+// The returned results are in request order and carry either the assigned ID or the validation error.
+func parseBatchItems(items []json.RawMessage, prov provenance, idempotencyKey string, isDuplicate func(id string) bool) ([]BatchItemResult, []*models.AirQualityData) {
+	results := make([]BatchItemResult, len(items))
+	accepted := make([]*models.AirQualityData, 0, len(items))
+	inBatch := make(map[string]bool, len(items))
+
+	for i, raw := range items {
+		results[i].Index = i
+
+		var req AirQualityDataRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			results[i].Error = "Invalid item: " + err.Error()
+			continue
+		}
+
+		if err := validateDecodedRequest(&req); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		itemKey := ""
+		if idempotencyKey != "" {
+			itemKey = fmt.Sprintf("%s:%d", idempotencyKey, i)
+		}
+		data := newReadingFromRequest(&req, readingID(&req, itemKey, prov.SensorID))
+		prov.apply(data)
+
+		id := data.ID.String()
+		results[i].ID = id
+		if inBatch[id] || isDuplicate(id) {
+			results[i].Duplicate = true
+			continue
+		}
+		inBatch[id] = true
+		accepted = append(accepted, data)
+	}
+
+	return results, accepted
+}
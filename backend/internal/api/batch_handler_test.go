@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/airpollution/internal/models"
+)
+
+func TestParseBatchItems(t *testing.T) {
+	items := []json.RawMessage{
+		json.RawMessage(`{"latitude": 41.015, "longitude": 28.979, "parameter": "PM2.5", "value": 25.0, "timestamp": "2025-05-02T13:45:00Z"}`),
+		json.RawMessage(`{"latitude": 95.0, "longitude": 28.979, "parameter": "PM2.5", "value": 25.0, "timestamp": "2025-05-02T13:45:00Z"}`),
+		json.RawMessage(`{"latitude": 41.015, "longitude": 28.979, "value": 25.0, "timestamp": "2025-05-02T13:45:00Z"}`),
+		json.RawMessage(`"not an object"`),
+		json.RawMessage(`{"latitude": 41.015, "longitude": 28.979, "parameter": "PM10", "value": 40.0, "timestamp": "2025-05-02T13:46:00Z"}`),
+	}
+
+	results, accepted := parseBatchItems(items, provenance{SensorID: "station-7", SignatureStatus: "verified"}, "",
+		func(string) bool { return false })
+
+	if len(results) != len(items) {
+		t.Fatalf("Expected %d results, got %d", len(items), len(results))
+	}
+
+	if len(accepted) != 2 {
+		t.Fatalf("Expected 2 accepted items, got %d", len(accepted))
+	}
+
+	expectedValid := []bool{true, false, false, false, true}
+	for i, result := range results {
+		if result.Index != i {
+			t.Errorf("Expected result %d to have index %d, got %d", i, i, result.Index)
+		}
+
+		if expectedValid[i] && (result.ID == "" || result.Error != "") {
+			t.Errorf("Expected item %d to be accepted, got %+v", i, result)
+		}
+
+		if !expectedValid[i] && (result.ID != "" || result.Error == "") {
+			t.Errorf("Expected item %d to be rejected, got %+v", i, result)
+		}
+	}
+
+	if accepted[0].SensorID != "station-7" || accepted[0].SignatureStatus != "verified" {
+		t.Errorf("Expected accepted items to carry the provenance, got %+v", accepted[0])
+	}
+
+	if accepted[0].ID.String() != results[0].ID || accepted[1].ID.String() != results[4].ID {
+		t.Errorf("Accepted IDs do not match the per-item results")
+	}
+}
+
+func TestParseBatchItemsDuplicates(t *testing.T) {
+	items := []json.RawMessage{
+		json.RawMessage(`{"id": "5f2b7c1e-3a4d-4e6f-8a9b-0c1d2e3f4a5b", "latitude": 41.015, "longitude": 28.979, "parameter": "PM2.5", "value": 25.0, "timestamp": "2025-05-02T13:45:00Z"}`),
+		json.RawMessage(`{"id": "5f2b7c1e-3a4d-4e6f-8a9b-0c1d2e3f4a5b", "latitude": 41.015, "longitude": 28.979, "parameter": "PM2.5", "value": 25.0, "timestamp": "2025-05-02T13:45:00Z"}`),
+		json.RawMessage(`{"id": "already-sent", "latitude": 41.015, "longitude": 28.979, "parameter": "PM2.5", "value": 25.0, "timestamp": "2025-05-02T13:45:00Z"}`),
+		json.RawMessage(`{"latitude": 41.015, "longitude": 28.979, "parameter": "PM10", "value": 40.0, "timestamp": "2025-05-02T13:46:00Z"}`),
+	}
+
+	seen := newExpiringSet(time.Minute)
+	isDuplicate := func(id string) bool { return seen.contains(id, time.Now()) }
+
+	results, accepted := parseBatchItems(items, provenance{SensorID: "station-7"}, "retry-key", isDuplicate)
+	if len(accepted) != 2 {
+		t.Fatalf("Expected 2 accepted items, got %d", len(accepted))
+	}
+	if !results[1].Duplicate {
+		t.Errorf("Expected repeated ID within the batch to be a duplicate")
+	}
+	if results[2].Error == "" {
+		t.Errorf("Expected invalid UUID to be rejected")
+	}
+
+	// Retrying the batch with the same idempotency key yields the same IDs, which are now duplicates
+	for _, data := range accepted {
+		seen.remember(data.ID.String(), time.Now())
+	}
+	retried, acceptedRetry := parseBatchItems(items, provenance{SensorID: "station-7"}, "retry-key", isDuplicate)
+	if len(acceptedRetry) != 0 {
+		t.Errorf("Expected no items to be accepted on retry, got %d", len(acceptedRetry))
+	}
+	if retried[3].ID != results[3].ID || !retried[3].Duplicate {
+		t.Errorf("Expected derived ID to be stable across retries, got %+v and %+v", results[3], retried[3])
+	}
+}
+
+func TestValidateAirQualityDataRequest(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		req      AirQualityDataRequest
+		expected bool
+	}{
+		{"Valid", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Timestamp: now}, true},
+		{"Latitude Out Of Range", AirQualityDataRequest{Latitude: -91, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Timestamp: now}, false},
+		{"Longitude Out Of Range", AirQualityDataRequest{Latitude: 41.015, Longitude: 181, Parameter: "PM2.5", Value: 10.0, Timestamp: now}, false},
+		{"Negative Value", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: -1.0, Timestamp: now}, false},
+		{"Alias With Unit", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "no2", Value: 10.0, Unit: "ppb", Timestamp: now}, true},
+		{"Unknown Parameter", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "benzene", Value: 10.0, Timestamp: now}, false},
+		{"Unit Not Allowed", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Unit: "ppb", Timestamp: now}, false},
+		{"Invalid ID", AirQualityDataRequest{ID: "abc", Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Timestamp: now}, false},
+		{"With Metadata", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Timestamp: now,
+			ReadingMetadata: models.ReadingMetadata{Humidity: floatPtr(65), Temperature: floatPtr(-5), SensorModel: "SDS011", Tags: map[string]string{"site": "roof"}}}, true},
+		{"Missing Timestamp", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0}, false},
+		{"Future Timestamp", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Timestamp: now.Add(time.Hour)}, false},
+		{"Late Timestamp", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Timestamp: now.Add(-48 * time.Hour)}, true},
+		{"Humidity Out Of Range", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Timestamp: now,
+			ReadingMetadata: models.ReadingMetadata{Humidity: floatPtr(101)}}, false},
+		{"Empty Tag Key", AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 10.0, Timestamp: now,
+			ReadingMetadata: models.ReadingMetadata{Tags: map[string]string{"": "roof"}}}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAirQualityDataRequest(&tc.req)
+			if tc.expected && err != nil {
+				t.Errorf("Expected valid request but got error: %v", err)
+			}
+			if !tc.expected && err == nil {
+				t.Errorf("Expected validation error but got nil")
+			}
+		})
+	}
+}
+
+func TestNewReadingFromRequestNormalizes(t *testing.T) {
+	req := AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "no2", Value: 10.0, Unit: "ppb"}
+
+	data := newReadingFromRequest(&req, uuid.New())
+
+	if data.Parameter != "NO2" || data.Unit != "µg/m³" {
+		t.Errorf("Expected canonical NO2 in µg/m³, got %s in %s", data.Parameter, data.Unit)
+	}
+	if math.Abs(data.Value-18.816) > 0.001 {
+		t.Errorf("Expected converted value 18.816, got %f", data.Value)
+	}
+	if data.OriginalValue != 10.0 || data.OriginalUnit != "ppb" {
+		t.Errorf("Expected original 10 ppb to be kept, got %f %s", data.OriginalValue, data.OriginalUnit)
+	}
+}
+
+func TestNewReadingFromRequestMetadata(t *testing.T) {
+	var req AirQualityDataRequest
+	body := `{"latitude": 41.015, "longitude": 28.979, "parameter": "PM2.5", "value": 25.0, "timestamp": "2025-05-02T13:45:00Z",
+		"humidity": 80, "altitude": 120.5, "firmware_version": "1.4.2", "tags": {"site": "roof"}}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+
+	data := newReadingFromRequest(&req, uuid.New())
+
+	if data.Humidity == nil || *data.Humidity != 80 || data.Altitude == nil || *data.Altitude != 120.5 {
+		t.Errorf("Expected humidity and altitude to be copied, got %+v", data.ReadingMetadata)
+	}
+	if data.Temperature != nil {
+		t.Errorf("Expected no temperature, got %f", *data.Temperature)
+	}
+	if data.FirmwareVersion != "1.4.2" || data.Tags["site"] != "roof" {
+		t.Errorf("Expected firmware version and tags to be copied, got %+v", data.ReadingMetadata)
+	}
+
+	// Readings without metadata encode exactly as before
+	plain := newReadingFromRequest(&AirQualityDataRequest{Latitude: 41.015, Longitude: 28.979, Parameter: "PM2.5", Value: 25.0}, uuid.New())
+	encoded, err := json.Marshal(plain)
+	if err != nil {
+		t.Fatalf("Failed to encode reading: %v", err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(encoded, &fields)
+	for _, field := range []string{"humidity", "temperature", "altitude", "sensor_model", "firmware_version", "tags"} {
+		if _, ok := fields[field]; ok {
+			t.Errorf("Expected %s to be omitted, got %s", field, encoded)
+		}
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// maxExpiringSetSize is the most keys an expiring set holds. When it is full, the oldest key is
+// forgotten before it expires to make room for a new one.
+const maxExpiringSetSize = 100000
+
+// expiringSet remembers keys for a fixed duration. It is used to detect replayed
+// signatures and resubmitted readings.
+type expiringSet struct {
+	ttl      time.Duration
+	capacity int
+	mu       sync.Mutex
+	seen     map[string]*list.Element
+	// order holds the entries oldest first; the ttl is fixed, so that is also the order they expire in
+	order *list.List
+}
+
+// expiringEntry is a key of an expiring set and the time it expires
+type expiringEntry struct {
+	key     string
+	expires time.Time
+}
+
+// newExpiringSet creates a set that forgets keys after ttl
+func newExpiringSet(ttl time.Duration) *expiringSet {
+	return &expiringSet{
+		ttl:      ttl,
+		capacity: maxExpiringSetSize,
+		seen:     make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// add records a key and reports whether it was not already present
+func (s *expiringSet) add(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.containsLocked(key, now) {
+		return false
+	}
+	s.rememberLocked(key, now)
+	return true
+}
+
+// contains reports whether a key is present and not expired
+func (s *expiringSet) contains(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.containsLocked(key, now)
+}
+
+// remember records a key, extending its expiry if it is already present
+func (s *expiringSet) remember(key string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rememberLocked(key, now)
+}
+
+func (s *expiringSet) containsLocked(key string, now time.Time) bool {
+	element, ok := s.seen[key]
+	return ok && now.Before(element.Value.(*expiringEntry).expires)
+}
+
+func (s *expiringSet) rememberLocked(key string, now time.Time) {
+	// Prune expired entries from the front, then make room by forgetting the oldest entry
+	for front := s.order.Front(); front != nil && !now.Before(front.Value.(*expiringEntry).expires); front = s.order.Front() {
+		s.removeLocked(front)
+	}
+
+	if element, ok := s.seen[key]; ok {
+		element.Value.(*expiringEntry).expires = now.Add(s.ttl)
+		s.order.MoveToBack(element)
+		return
+	}
+
+	if s.order.Len() >= s.capacity {
+		s.removeLocked(s.order.Front())
+	}
+	s.seen[key] = s.order.PushBack(&expiringEntry{key: key, expires: now.Add(s.ttl)})
+}
+
+func (s *expiringSet) removeLocked(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.seen, element.Value.(*expiringEntry).key)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExpiringSetExpiresKeys(t *testing.T) {
+	set := newExpiringSet(time.Minute)
+	now := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	if !set.add("a", now) {
+		t.Errorf("Expected a new key to be added")
+	}
+	if set.add("a", now.Add(30*time.Second)) {
+		t.Errorf("Expected a present key not to be added again")
+	}
+	if set.contains("a", now.Add(time.Minute)) {
+		t.Errorf("Expected the key to expire after the ttl")
+	}
+
+	// Remembering a key again extends its expiry
+	set.remember("b", now)
+	set.remember("b", now.Add(45*time.Second))
+	if !set.contains("b", now.Add(90*time.Second)) {
+		t.Errorf("Expected the key to be extended")
+	}
+
+	// Expired keys are pruned when new keys are remembered
+	set.remember("c", now.Add(2*time.Minute))
+	if set.order.Len() != 1 || len(set.seen) != 1 {
+		t.Errorf("Expected expired keys to be pruned, got %d entries", set.order.Len())
+	}
+}
+
+func TestExpiringSetCapacity(t *testing.T) {
+	set := newExpiringSet(time.Hour)
+	set.capacity = 3
+	now := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		set.remember(fmt.Sprintf("key-%d", i), now.Add(time.Duration(i)*time.Second))
+	}
+	// key-0 was remembered last, so key-1 is the oldest when the set is full
+	set.remember("key-0", now.Add(3*time.Second))
+	set.remember("key-3", now.Add(4*time.Second))
+
+	if set.order.Len() != 3 {
+		t.Errorf("Expected the set to hold 3 keys, got %d", set.order.Len())
+	}
+	if set.contains("key-1", now.Add(5*time.Second)) {
+		t.Errorf("Expected the oldest key to be forgotten")
+	}
+	for _, key := range []string{"key-0", "key-2", "key-3"} {
+		if !set.contains(key, now.Add(5*time.Second)) {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+}
@@ -0,0 +1,504 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/airpollution/internal/db"
+	"github.com/user/airpollution/internal/grpc/ingestpb"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/ratelimit"
+	"github.com/user/airpollution/internal/services/spool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultGRPCBatchSize is the number of streamed readings published to Kafka and acknowledged together
+	DefaultGRPCBatchSize = 100
+	// DefaultGRPCFlushInterval is the longest a streamed reading waits for its batch to be published
+	DefaultGRPCFlushInterval = time.Second
+	// DefaultGRPCWindow is the number of unacknowledged readings a client may have in flight on a stream.
+	// The server does not read past it before acknowledging earlier readings.
+	DefaultGRPCWindow = 1000
+)
+
+// provenanceKey is the context key of the provenance of a gRPC call
+type provenanceKey struct{}
+
+// GRPCServer implements the gRPC ingest service. It shares the validation, normalization,
+// deduplication and Kafka producer of an IngestHandler.
+type GRPCServer struct {
+	ingestpb.UnimplementedIngestServiceServer
+	handler       *IngestHandler
+	sensors       SensorStore
+	authRequired  bool
+	batchSize     int
+	flushInterval time.Duration
+	window        int
+	limiter       *ratelimit.Limiter
+}
+
+// NewGRPCServer creates a gRPC ingest server. Sensors authenticate with their API key in the
+// x-api-key metadata; when authRequired is false, calls without a key are accepted anonymously.
+func NewGRPCServer(handler *IngestHandler, sensors SensorStore, authRequired bool) *GRPCServer {
+	return &GRPCServer{
+		handler:       handler,
+		sensors:       sensors,
+		authRequired:  authRequired,
+		batchSize:     DefaultGRPCBatchSize,
+		flushInterval: DefaultGRPCFlushInterval,
+		window:        DefaultGRPCWindow,
+	}
+}
+
+// WithRateLimiter throttles calls with the given limiter, keyed like the HTTP RateLimit middleware.
+// Each call, including each stream, takes one token, which pays for its first reading; streams
+// take a token for each further reading they accept and are closed once the bucket is empty.
+func (s *GRPCServer) WithRateLimiter(limiter *ratelimit.Limiter) *GRPCServer {
+	s.limiter = limiter
+	return s
+}
+
+// ServerOptions returns the interceptors that authenticate and rate limit sensors, to be passed to grpc.NewServer
+func (s *GRPCServer) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, sensor, err := s.authenticate(ctx)
+			if err != nil {
+				return nil, err
+			}
+			ctx, retryAfter, ok := s.admit(ctx, sensor)
+			if !ok {
+				grpc.SetHeader(ctx, retryAfter)
+				return nil, status.Error(codes.ResourceExhausted, "Rate limit exceeded")
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, sensor, err := s.authenticate(stream.Context())
+			if err != nil {
+				return err
+			}
+			ctx, retryAfter, ok := s.admit(ctx, sensor)
+			if !ok {
+				stream.SetHeader(retryAfter)
+				return status.Error(codes.ResourceExhausted, "Rate limit exceeded")
+			}
+			return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+		}),
+	}
+}
+
+// quotaKey is the context key of the bucket a call was admitted to
+type quotaKey struct{}
+
+// admit takes a rate limit token for a call and records its bucket in the context, so that
+// streams charge their readings with allowReading. When the caller is throttled, it returns
+// the retry-after metadata to send.
+func (s *GRPCServer) admit(ctx context.Context, sensor *models.Sensor) (context.Context, metadata.MD, bool) {
+	if s.limiter == nil {
+		return ctx, nil, true
+	}
+
+	var clientIP string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		clientIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(clientIP); err == nil {
+			clientIP = host
+		}
+	}
+
+	key, class := rateLimitKey(sensor, clientIP)
+	allowed, retryAfter := s.limiter.Allow(key, class)
+	if !allowed {
+		return ctx, retryAfterMetadata(retryAfter), false
+	}
+	return context.WithValue(ctx, quotaKey{}, &readingQuota{limiter: s.limiter, key: key, class: class, covered: 1}), nil, true
+}
+
+// allowReading takes a token for a reading accepted on a stream, unless the token of the call
+// still covers it. When the caller is throttled, it returns the retry-after metadata to send.
+func allowReading(ctx context.Context) (metadata.MD, bool) {
+	quota, ok := ctx.Value(quotaKey{}).(*readingQuota)
+	if !ok {
+		return nil, true
+	}
+	if quota.covered > 0 {
+		quota.covered--
+		return nil, true
+	}
+
+	allowed, retryAfter := quota.limiter.AllowN(quota.key, quota.class, 1)
+	if !allowed {
+		return retryAfterMetadata(retryAfter), false
+	}
+	return nil, true
+}
+
+// retryAfterMetadata returns the retry-after metadata of a throttled call
+func retryAfterMetadata(retryAfter time.Duration) metadata.MD {
+	return metadata.Pairs("retry-after", strconv.Itoa(retryAfterSeconds(retryAfter)))
+}
+
+// Register registers the ingest service with a gRPC server
+func (s *GRPCServer) Register(server *grpc.Server) {
+	ingestpb.RegisterIngestServiceServer(server, s)
+}
+
+// SubmitReading submits a single reading
+func (s *GRPCServer) SubmitReading(ctx context.Context, reading *ingestpb.Reading) (*ingestpb.ReadingResult, error) {
+	data, result := s.processReading(provenanceFromGRPCContext(ctx), reading, reading.GetSequence(), nil)
+	if data == nil {
+		return result, nil
+	}
+
+	if err := s.publish(ctx, []*models.AirQualityData{data}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UploadReadings accepts a client stream of readings and returns a summary when the client closes the stream.
+// Readings are published in batches while the stream is open; if publishing fails, the call fails and
+// the readings of earlier batches stay published.
+func (s *GRPCServer) UploadReadings(stream ingestpb.IngestService_UploadReadingsServer) error {
+	prov := provenanceFromGRPCContext(stream.Context())
+	summary := &ingestpb.UploadSummary{}
+	var pending []*models.AirQualityData
+	inBatch := make(map[string]bool)
+	var count uint64
+
+	for {
+		reading, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		count++
+
+		data, result := s.processReading(prov, reading, sequenceOf(reading, count), inBatch)
+		switch result.Status {
+		case ingestpb.ReadingStatus_READING_STATUS_REJECTED:
+			summary.Rejected++
+			if len(summary.Rejections) < maxUploadErrors {
+				summary.Rejections = append(summary.Rejections, result)
+			} else {
+				summary.RejectionsTruncated = true
+			}
+		case ingestpb.ReadingStatus_READING_STATUS_DUPLICATE:
+			summary.Duplicates++
+		default:
+			// Publish what was accepted so far and close the stream once the sender is throttled
+			if retryAfter, ok := allowReading(stream.Context()); !ok {
+				if err := s.publish(stream.Context(), pending); err != nil {
+					return err
+				}
+				stream.SetTrailer(retryAfter)
+				return status.Errorf(codes.ResourceExhausted, "Rate limit exceeded after %d accepted readings", summary.Accepted+uint32(len(pending)))
+			}
+			pending = append(pending, data)
+			inBatch[result.Id] = true
+		}
+
+		if len(pending) >= s.batchSize {
+			if err := s.publish(stream.Context(), pending); err != nil {
+				return err
+			}
+			summary.Accepted += uint32(len(pending))
+			pending = pending[:0]
+			clear(inBatch)
+		}
+	}
+
+	if err := s.publish(stream.Context(), pending); err != nil {
+		return err
+	}
+	summary.Accepted += uint32(len(pending))
+
+	return stream.SendAndClose(summary)
+}
+
+// StreamReadings accepts a continuous stream of readings. Readings are published to Kafka in batches of
+// batchSize, or after flushInterval at the latest, and each batch is acknowledged with an Ack. The stream
+// is not read while a batch is being published, nor past window readings after the last acknowledged one,
+// so HTTP/2 flow control slows down clients that send too fast.
+func (s *GRPCServer) StreamReadings(stream ingestpb.IngestService_StreamReadingsServer) error {
+	ctx := stream.Context()
+	prov := provenanceFromGRPCContext(ctx)
+
+	// Receive in the background so that partial batches can be flushed on a timer. Each received
+	// reading holds a credit until it is acknowledged, which bounds the unacknowledged readings by the window.
+	readings := make(chan *ingestpb.Reading)
+	recvErr := make(chan error, 1)
+	credits := make(chan struct{}, s.window)
+	go func() {
+		defer close(readings)
+		for {
+			select {
+			case credits <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			reading, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					recvErr <- err
+				}
+				return
+			}
+			select {
+			case readings <- reading:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	ack := &ingestpb.Ack{}
+	var pending []*models.AirQualityData
+	inBatch := make(map[string]bool)
+	var count, unacked uint64
+
+	flush := func() error {
+		if unacked == 0 {
+			return nil
+		}
+		if err := s.publish(ctx, pending); err != nil {
+			return err
+		}
+		ack.Accepted = uint32(len(pending))
+		ack.Window = uint32(s.window)
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+		for ; unacked > 0; unacked-- {
+			<-credits
+		}
+		ack = &ingestpb.Ack{}
+		pending = nil
+		clear(inBatch)
+		return nil
+	}
+
+	for {
+		select {
+		case reading, ok := <-readings:
+			if !ok {
+				if err := flush(); err != nil {
+					return err
+				}
+				select {
+				case err := <-recvErr:
+					return err
+				default:
+					return nil
+				}
+			}
+			count++
+			unacked++
+
+			sequence := sequenceOf(reading, count)
+			data, result := s.processReading(prov, reading, sequence, inBatch)
+			switch result.Status {
+			case ingestpb.ReadingStatus_READING_STATUS_REJECTED:
+				ack.Rejections = append(ack.Rejections, result)
+			case ingestpb.ReadingStatus_READING_STATUS_DUPLICATE:
+				ack.Duplicates++
+			default:
+				// Acknowledge what was accepted so far and close the stream once the sender is
+				// throttled; the refused reading is not acknowledged
+				if retryAfter, ok := allowReading(ctx); !ok {
+					unacked--
+					if err := flush(); err != nil {
+						return err
+					}
+					stream.SetTrailer(retryAfter)
+					return status.Errorf(codes.ResourceExhausted, "Rate limit exceeded at reading %d", sequence)
+				}
+				pending = append(pending, data)
+				inBatch[result.Id] = true
+			}
+			ack.AckedThrough = sequence
+
+			if unacked >= uint64(s.batchSize) || unacked >= uint64(s.window) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// processReading validates and converts a reading. It returns the reading to publish, or nil if it was
+// rejected or is a duplicate of a published reading or of one in inBatch, along with the result to report
+// to the client.
+func (s *GRPCServer) processReading(prov provenance, reading *ingestpb.Reading, sequence uint64, inBatch map[string]bool) (*models.AirQualityData, *ingestpb.ReadingResult) {
+	result := &ingestpb.ReadingResult{Sequence: sequence}
+
+	req := requestFromProto(reading)
+	if err := validateDecodedRequest(req); err != nil {
+		result.Status = ingestpb.ReadingStatus_READING_STATUS_REJECTED
+		result.Error = err.Error()
+		return nil, result
+	}
+
+	data := newReadingFromRequest(req, readingID(req, "", prov.SensorID))
+	prov.apply(data)
+	result.Id = data.ID.String()
+
+	if inBatch[result.Id] || s.handler.recent.contains(result.Id, time.Now()) {
+		result.Status = ingestpb.ReadingStatus_READING_STATUS_DUPLICATE
+		return nil, result
+	}
+
+	result.Status = ingestpb.ReadingStatus_READING_STATUS_ACCEPTED
+	return data, result
+}
+
+// publish publishes readings to Kafka and remembers their IDs for deduplication
+func (s *GRPCServer) publish(ctx context.Context, data []*models.AirQualityData) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := s.handler.producer.ProduceAirQualityDataBatch(ctx, data); err != nil {
+		if errors.Is(err, spool.ErrFull) {
+			return status.Errorf(codes.ResourceExhausted, "Failed to publish data: %v", err)
+		}
+		return status.Errorf(codes.Unavailable, "Failed to publish data: %v", err)
+	}
+
+	for _, item := range data {
+		s.handler.recent.remember(item.ID.String(), time.Now())
+	}
+	return nil
+}
+
+// authenticate resolves the sensor from the x-api-key or authorization metadata and records the provenance.
+// The sensor is nil for anonymous calls.
+func (s *GRPCServer) authenticate(ctx context.Context) (context.Context, *models.Sensor, error) {
+	apiKey := metadataToken(ctx)
+	if apiKey == "" || s.sensors == nil {
+		if s.authRequired {
+			return nil, nil, status.Error(codes.Unauthenticated, "Missing API key")
+		}
+		return context.WithValue(ctx, provenanceKey{}, provenance{SignatureStatus: models.SignatureUnsigned}), nil, nil
+	}
+
+	sensor, err := s.sensors.GetSensorByAPIKeyHash(HashAPIKey(apiKey))
+	if errors.Is(err, db.ErrSensorNotFound) {
+		return nil, nil, status.Error(codes.Unauthenticated, "Invalid API key")
+	}
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "Failed to authenticate sensor: %v", err)
+	}
+
+	if !sensor.Enabled {
+		return nil, nil, status.Error(codes.PermissionDenied, "Sensor is disabled")
+	}
+
+	// Submissions over gRPC are not signed
+	if sensor.RequireSignature {
+		return nil, nil, status.Error(codes.PermissionDenied, "Sensor requires signed submissions, which are only supported over HTTP")
+	}
+
+	return context.WithValue(ctx, provenanceKey{}, provenance{
+		SensorID:        sensor.ID,
+		SignatureStatus: models.SignatureUnsigned,
+	}), sensor, nil
+}
+
+// authenticatedStream carries the authenticated context of a stream
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the authenticated context
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// provenanceFromGRPCContext returns the provenance recorded by the authentication interceptor
+func provenanceFromGRPCContext(ctx context.Context) provenance {
+	if prov, ok := ctx.Value(provenanceKey{}).(provenance); ok {
+		return prov
+	}
+	return provenance{SignatureStatus: models.SignatureUnsigned}
+}
+
+// metadataToken reads the API key from the x-api-key metadata or a bearer authorization
+func metadataToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(strings.ToLower(APIKeyHeader)); len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+		return strings.TrimSpace(values[0])
+	}
+
+	if values := md.Get("authorization"); len(values) > 0 {
+		authorization := values[0]
+		if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+			return strings.TrimSpace(authorization[7:])
+		}
+	}
+
+	return ""
+}
+
+// sequenceOf returns the client-assigned sequence number of a reading, or its position in the stream
+func sequenceOf(reading *ingestpb.Reading, position uint64) uint64 {
+	if reading.GetSequence() != 0 {
+		return reading.GetSequence()
+	}
+	return position
+}
+
+// requestFromProto converts a protobuf reading to an ingest request
+func requestFromProto(reading *ingestpb.Reading) *AirQualityDataRequest {
+	req := &AirQualityDataRequest{
+		ID:        reading.GetId(),
+		Latitude:  reading.GetLatitude(),
+		Longitude: reading.GetLongitude(),
+		Parameter: reading.GetParameter(),
+		Value:     reading.GetValue(),
+		Unit:      reading.GetUnit(),
+		ReadingMetadata: models.ReadingMetadata{
+			Humidity:        reading.Humidity,
+			Temperature:     reading.Temperature,
+			Altitude:        reading.Altitude,
+			SensorModel:     reading.GetSensorModel(),
+			FirmwareVersion: reading.GetFirmwareVersion(),
+			Tags:            reading.GetTags(),
+		},
+	}
+	if reading.GetTimestamp() != nil {
+		req.Timestamp = reading.GetTimestamp().AsTime()
+	}
+	return req
+}
@@ -0,0 +1,342 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/grpc/ingestpb"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// MockPublisher records published readings for testing
+type MockPublisher struct {
+	mu       sync.Mutex
+	readings []*models.AirQualityData
+}
+
+func (m *MockPublisher) ProduceAirQualityData(ctx context.Context, data *models.AirQualityData) error {
+	return m.ProduceAirQualityDataBatch(ctx, []*models.AirQualityData{data})
+}
+
+func (m *MockPublisher) ProduceAirQualityDataBatch(ctx context.Context, data []*models.AirQualityData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readings = append(m.readings, data...)
+	return nil
+}
+
+func (m *MockPublisher) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.readings)
+}
+
+// startGRPCServer serves the ingest service over an in-memory connection
+func startGRPCServer(t *testing.T, server *GRPCServer) ingestpb.IngestServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(server.ServerOptions()...)
+	server.Register(grpcServer)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return ingestpb.NewIngestServiceClient(conn)
+}
+
+func testReading(sequence uint64, value float64) *ingestpb.Reading {
+	return &ingestpb.Reading{
+		Latitude:  41.015,
+		Longitude: 28.979,
+		Parameter: "PM2.5",
+		Value:     value,
+		Timestamp: timestamppb.New(time.Date(2025, 5, 2, 13, 45, int(sequence), 0, time.UTC)),
+		Sequence:  sequence,
+	}
+}
+
+func TestGRPCSubmitReading(t *testing.T) {
+	publisher := &MockPublisher{}
+	client := startGRPCServer(t, NewGRPCServer(NewIngestHandler(publisher), nil, false))
+	ctx := context.Background()
+
+	reading := testReading(1, 25.0)
+	reading.Id = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	result, err := client.SubmitReading(ctx, reading)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Status != ingestpb.ReadingStatus_READING_STATUS_ACCEPTED || result.Id != reading.Id {
+		t.Errorf("Expected reading to be accepted with its ID, got %v", result)
+	}
+
+	result, err = client.SubmitReading(ctx, reading)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Status != ingestpb.ReadingStatus_READING_STATUS_DUPLICATE {
+		t.Errorf("Expected resubmission to be a duplicate, got %v", result.Status)
+	}
+
+	result, err = client.SubmitReading(ctx, testReading(2, -1.0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Status != ingestpb.ReadingStatus_READING_STATUS_REJECTED || result.Error == "" {
+		t.Errorf("Expected negative value to be rejected, got %v", result)
+	}
+
+	if publisher.count() != 1 {
+		t.Errorf("Expected 1 published reading, got %d", publisher.count())
+	}
+}
+
+func TestGRPCUploadReadings(t *testing.T) {
+	publisher := &MockPublisher{}
+	server := NewGRPCServer(NewIngestHandler(publisher), nil, false)
+	server.batchSize = 2
+	client := startGRPCServer(t, server)
+
+	stream, err := client.UploadReadings(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, value := range []float64{25.0, 26.0, -1.0, 27.0, 28.0} {
+		if err := stream.Send(testReading(uint64(i+1), value)); err != nil {
+			t.Fatalf("Failed to send reading: %v", err)
+		}
+	}
+
+	summary, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Accepted != 4 || summary.Rejected != 1 {
+		t.Errorf("Expected 4 accepted and 1 rejected, got %v", summary)
+	}
+	if len(summary.Rejections) != 1 || summary.Rejections[0].Sequence != 3 {
+		t.Errorf("Expected reading 3 to be rejected, got %v", summary.Rejections)
+	}
+	if publisher.count() != 4 {
+		t.Errorf("Expected 4 published readings, got %d", publisher.count())
+	}
+}
+
+func TestGRPCStreamReadingsAcks(t *testing.T) {
+	publisher := &MockPublisher{}
+	server := NewGRPCServer(NewIngestHandler(publisher), nil, false)
+	server.batchSize = 3
+	server.flushInterval = 20 * time.Millisecond
+	client := startGRPCServer(t, server)
+
+	stream, err := client.StreamReadings(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A full batch is acknowledged immediately
+	for i := 1; i <= 3; i++ {
+		if err := stream.Send(testReading(uint64(i), 25.0+float64(i))); err != nil {
+			t.Fatalf("Failed to send reading: %v", err)
+		}
+	}
+	ack, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ack.AckedThrough != 3 || ack.Accepted != 3 || ack.Window != DefaultGRPCWindow {
+		t.Errorf("Expected readings 1-3 to be acknowledged, got %v", ack)
+	}
+
+	// A partial batch is acknowledged after the flush interval
+	if err := stream.Send(testReading(4, -1.0)); err != nil {
+		t.Fatalf("Failed to send reading: %v", err)
+	}
+	ack, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ack.AckedThrough != 4 || ack.Accepted != 0 || len(ack.Rejections) != 1 {
+		t.Errorf("Expected reading 4 to be rejected, got %v", ack)
+	}
+
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Expected the stream to end cleanly, got %v", err)
+	}
+	if publisher.count() != 3 {
+		t.Errorf("Expected 3 published readings, got %d", publisher.count())
+	}
+}
+
+func TestGRPCStreamReadingsWindow(t *testing.T) {
+	publisher := &MockPublisher{}
+	server := NewGRPCServer(NewIngestHandler(publisher), nil, false)
+	server.window = 2
+	server.flushInterval = time.Hour
+	client := startGRPCServer(t, server)
+
+	stream, err := client.StreamReadings(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The window is acknowledged long before a batch fills up or the flush interval passes, and a
+	// reading repeated before it is published is a duplicate
+	first := testReading(1, 25.0)
+	first.Id = "9b2f6c1e-4d3a-4f5b-8c7d-1e2f3a4b5c6d"
+	repeated := testReading(2, 25.0)
+	repeated.Id = first.Id
+	for _, reading := range []*ingestpb.Reading{first, repeated} {
+		if err := stream.Send(reading); err != nil {
+			t.Fatalf("Failed to send reading: %v", err)
+		}
+	}
+	ack, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ack.AckedThrough != 2 || ack.Accepted != 1 || ack.Duplicates != 1 || ack.Window != 2 {
+		t.Errorf("Expected readings 1-2 to be acknowledged with one duplicate, got %v", ack)
+	}
+
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Expected the stream to end cleanly, got %v", err)
+	}
+	if publisher.count() != 1 {
+		t.Errorf("Expected 1 published reading, got %d", publisher.count())
+	}
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	store := NewMockSensorStore()
+	apiKey, _ := GenerateAPIKey()
+	store.CreateSensor(models.NewSensor("station-7", "Station 7", HashAPIKey(apiKey)))
+
+	publisher := &MockPublisher{}
+	client := startGRPCServer(t, NewGRPCServer(NewIngestHandler(publisher), store, true))
+
+	_, err := client.SubmitReading(context.Background(), testReading(1, 25.0))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without an API key, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "aqk_invalid")
+	_, err = client.SubmitReading(ctx, testReading(1, 25.0))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated with an invalid API key, got %v", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "x-api-key", apiKey)
+	result, err := client.SubmitReading(ctx, testReading(1, 25.0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Status != ingestpb.ReadingStatus_READING_STATUS_ACCEPTED {
+		t.Errorf("Expected reading to be accepted, got %v", result)
+	}
+	if publisher.readings[0].SensorID != "station-7" {
+		t.Errorf("Expected reading to be stamped with station-7, got %q", publisher.readings[0].SensorID)
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	limiter := ratelimit.New(map[string]ratelimit.Limit{ratelimit.AnonymousClass: {Rate: 1, Burst: 1}})
+	server := NewGRPCServer(NewIngestHandler(&MockPublisher{}), nil, false).WithRateLimiter(limiter)
+	client := startGRPCServer(t, server)
+
+	if _, err := client.SubmitReading(context.Background(), testReading(1, 25.0)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var header metadata.MD
+	_, err := client.SubmitReading(context.Background(), testReading(2, 25.0), grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted, got %v", err)
+	}
+	if values := header.Get("retry-after"); len(values) != 1 || values[0] != "1" {
+		t.Errorf("Expected retry-after 1, got %v", values)
+	}
+}
+
+func TestGRPCRateLimitCutsOffStreams(t *testing.T) {
+	// The stream's own token pays for its first reading and the bucket for two more
+	newServer := func(publisher *MockPublisher) *GRPCServer {
+		limiter := ratelimit.New(map[string]ratelimit.Limit{ratelimit.AnonymousClass: {Rate: 0.001, Burst: 3}})
+		server := NewGRPCServer(NewIngestHandler(publisher), nil, false).WithRateLimiter(limiter)
+		server.flushInterval = time.Hour
+		return server
+	}
+
+	t.Run("Stream Readings", func(t *testing.T) {
+		publisher := &MockPublisher{}
+		client := startGRPCServer(t, newServer(publisher))
+
+		stream, err := client.StreamReadings(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for i := 1; i <= 5; i++ {
+			stream.Send(testReading(uint64(i), 25.0))
+		}
+
+		ack, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Expected the readings before the limit to be acknowledged, got %v", err)
+		}
+		if ack.AckedThrough != 3 || ack.Accepted != 3 {
+			t.Errorf("Expected readings 1-3 to be acknowledged, got %v", ack)
+		}
+		if _, err := stream.Recv(); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("Expected the stream to be closed with ResourceExhausted, got %v", err)
+		}
+		if values := stream.Trailer().Get("retry-after"); len(values) != 1 {
+			t.Errorf("Expected a retry-after trailer, got %v", stream.Trailer())
+		}
+		if publisher.count() != 3 {
+			t.Errorf("Expected 3 published readings, got %d", publisher.count())
+		}
+	})
+
+	t.Run("Upload Readings", func(t *testing.T) {
+		publisher := &MockPublisher{}
+		client := startGRPCServer(t, newServer(publisher))
+
+		stream, err := client.UploadReadings(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for i := 1; i <= 5; i++ {
+			stream.Send(testReading(uint64(i), 25.0))
+		}
+
+		if _, err := stream.CloseAndRecv(); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("Expected the upload to be cut off with ResourceExhausted, got %v", err)
+		}
+		if publisher.count() != 3 {
+			t.Errorf("Expected 3 published readings, got %d", publisher.count())
+		}
+	})
+}
@@ -2,45 +2,89 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
 	"github.com/user/airpollution/internal/models"
-	"github.com/user/airpollution/internal/services/kafka"
+	"github.com/user/airpollution/internal/services/parameters"
+	"github.com/user/airpollution/internal/services/spool"
+	"github.com/user/airpollution/internal/services/timepolicy"
 )
 
+const (
+	// IdempotencyKeyHeader lets clients make retries of a submission safe without supplying reading IDs
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// DefaultIdempotencyWindow is how long submitted reading IDs are remembered for deduplication
+	DefaultIdempotencyWindow = 10 * time.Minute
+	// MaxTags is the maximum number of tags on a reading
+	MaxTags = 32
+	// MaxMetadataLength is the maximum length of the sensor model, firmware version and each tag key and value
+	MaxMetadataLength = 128
+)
+
+// readingIDNamespace is the UUID namespace of reading IDs derived from idempotency keys
+var readingIDNamespace = uuid.MustParse("8f3c1a2e-6d4b-4e8a-9b7c-2f5e0d1a3c6b")
+
+// Publisher publishes readings to the raw data topic
+type Publisher interface {
+	ProduceAirQualityData(ctx context.Context, data *models.AirQualityData) error
+	ProduceAirQualityDataBatch(ctx context.Context, data []*models.AirQualityData) error
+}
+
 // IngestHandler handles the ingest API endpoints
 type IngestHandler struct {
-	producer *kafka.Producer
+	producer Publisher
+	recent   *expiringSet
 }
 
 // NewIngestHandler creates a new ingest handler
-func NewIngestHandler(producer *kafka.Producer) *IngestHandler {
+func NewIngestHandler(producer Publisher) *IngestHandler {
 	return &IngestHandler{
 		producer: producer,
+		recent:   newExpiringSet(DefaultIdempotencyWindow),
 	}
 }
 
+// WithIdempotencyWindow sets how long submitted reading IDs are remembered for deduplication
+func (h *IngestHandler) WithIdempotencyWindow(window time.Duration) *IngestHandler {
+	h.recent = newExpiringSet(window)
+	return h
+}
+
 // AirQualityDataRequest represents the request body for air quality data
 type AirQualityDataRequest struct {
+	// ID is an optional client-supplied reading ID (UUID) that makes resubmissions idempotent
+	ID        string    `json:"id,omitempty"`
 	Latitude  float64   `json:"latitude" binding:"required"`
 	Longitude float64   `json:"longitude" binding:"required"`
 	Parameter string    `json:"parameter" binding:"required"`
 	Value     float64   `json:"value" binding:"required"`
 	Timestamp time.Time `json:"timestamp" binding:"required"`
+	// Unit of Value, such as µg/m³ or ppb. Defaults to the canonical unit of the parameter.
+	Unit string `json:"unit,omitempty"`
+	// Optional humidity, temperature, altitude, device details and tags
+	models.ReadingMetadata
 }
 
 // PostAirQualityData godoc
 // @Summary Submit air quality data
-// @Description Submit a new air quality data point
+// @Description Submit a new air quality data point. Resubmissions with the same reading ID or Idempotency-Key are acknowledged without being queued again.
 // @Tags data
 // @Accept json
 // @Produce json
 // @Param data body AirQualityDataRequest true "Air quality data"
+// @Param Idempotency-Key header string false "Client key identifying the submission"
+// @Success 200 {object} map[string]interface{}
 // @Success 202 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
 // @Router /api/data [post]
 func (h *IngestHandler) PostAirQualityData(c *gin.Context) {
 	var req AirQualityDataRequest
@@ -51,47 +95,39 @@ func (h *IngestHandler) PostAirQualityData(c *gin.Context) {
 		return
 	}
 
-	// Basic validation
-	if req.Latitude < -90 || req.Latitude > 90 {
+	if err := validateAirQualityDataRequest(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Latitude must be between -90 and 90",
+			"error": err.Error(),
 		})
 		return
 	}
 
-	if req.Longitude < -180 || req.Longitude > 180 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Longitude must be between -180 and 180",
-		})
-		return
-	}
+	// Convert to domain model
+	prov := provenanceFromContext(c)
+	airQualityData := newReadingFromRequest(&req, readingID(&req, c.GetHeader(IdempotencyKeyHeader), prov.SensorID))
+	prov.apply(airQualityData)
 
-	if req.Value < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Value must be non-negative",
+	// Acknowledge resubmissions without queueing them again
+	if h.recent.contains(airQualityData.ID.String(), time.Now()) {
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Duplicate submission ignored",
+			"id":        airQualityData.ID.String(),
+			"duplicate": true,
 		})
 		return
 	}
 
-	// Convert to domain model
-	airQualityData := models.NewAirQualityData(
-		req.Latitude,
-		req.Longitude,
-		req.Parameter,
-		req.Value,
-		req.Timestamp,
-	)
-
 	// Publish to Kafka
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
 	if err := h.producer.ProduceAirQualityData(ctx, airQualityData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(publishErrorStatus(err), gin.H{
 			"error": "Failed to publish data: " + err.Error(),
 		})
 		return
 	}
+	h.recent.remember(airQualityData.ID.String(), time.Now())
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"message": "Data received and queued for processing",
@@ -99,7 +135,145 @@ func (h *IngestHandler) PostAirQualityData(c *gin.Context) {
 	})
 }
 
-// RegisterRoutes registers the ingest routes to the given router
-func (h *IngestHandler) RegisterRoutes(router *gin.Engine) {
-	router.POST("/api/data", h.PostAirQualityData)
+// RegisterRoutes registers the ingest routes to the given router.
+// The middleware, such as SensorAuth, runs before every ingest route.
+func (h *IngestHandler) RegisterRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	group := router.Group("/api/data", middleware...)
+	group.POST("", h.PostAirQualityData)
+	group.POST("/batch", h.PostAirQualityDataBatch)
+	group.POST("/observations", h.PostObservation)
+	group.POST("/upload", h.PostAirQualityDataUpload)
+
+	router.GET("/api/parameters", h.GetParameters)
+}
+
+// GetParameters godoc
+// @Summary List supported parameters
+// @Description List the parameter catalogue with aliases, accepted units and valid ranges
+// @Tags data
+// @Produce json
+// @Success 200 {array} parameters.Definition
+// @Router /api/parameters [get]
+func (h *IngestHandler) GetParameters(c *gin.Context) {
+	c.JSON(http.StatusOK, parameters.Default().Definitions())
+}
+
+// validateAirQualityDataRequest performs the basic range checks on a request
+func validateAirQualityDataRequest(req *AirQualityDataRequest) error {
+	if req.ID != "" {
+		if _, err := uuid.Parse(req.ID); err != nil {
+			return errors.New("ID must be a valid UUID")
+		}
+	}
+
+	if req.Latitude < -90 || req.Latitude > 90 {
+		return errors.New("Latitude must be between -90 and 90")
+	}
+
+	if req.Longitude < -180 || req.Longitude > 180 {
+		return errors.New("Longitude must be between -180 and 180")
+	}
+
+	if req.Value < 0 {
+		return errors.New("Value must be non-negative")
+	}
+
+	if _, err := timepolicy.Default().Classify(req.Timestamp, time.Now()); err != nil {
+		return err
+	}
+
+	if _, err := parameters.Default().Normalize(req.Parameter, req.Value, req.Unit); err != nil {
+		return err
+	}
+
+	return validateReadingMetadata(&req.ReadingMetadata)
+}
+
+// validateReadingMetadata checks the optional metadata of a reading
+func validateReadingMetadata(meta *models.ReadingMetadata) error {
+	if meta.Humidity != nil && (*meta.Humidity < 0 || *meta.Humidity > 100) {
+		return errors.New("Humidity must be between 0 and 100")
+	}
+
+	if meta.Temperature != nil && (*meta.Temperature < -90 || *meta.Temperature > 70) {
+		return errors.New("Temperature must be between -90 and 70")
+	}
+
+	if meta.Altitude != nil && (*meta.Altitude < -500 || *meta.Altitude > 10000) {
+		return errors.New("Altitude must be between -500 and 10000")
+	}
+
+	if len(meta.SensorModel) > MaxMetadataLength || len(meta.FirmwareVersion) > MaxMetadataLength {
+		return fmt.Errorf("Sensor model and firmware version must be at most %d characters", MaxMetadataLength)
+	}
+
+	if len(meta.Tags) > MaxTags {
+		return fmt.Errorf("At most %d tags are allowed", MaxTags)
+	}
+	for key, value := range meta.Tags {
+		if key == "" || len(key) > MaxMetadataLength || len(value) > MaxMetadataLength {
+			return fmt.Errorf("Tag keys must be non-empty and tags at most %d characters", MaxMetadataLength)
+		}
+	}
+
+	return nil
+}
+
+// validateDecodedRequest applies the same checks as PostAirQualityData to a request
+// that was decoded outside of gin binding (batch items, uploads, MQTT payloads)
+func validateDecodedRequest(req *AirQualityDataRequest) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return err
+	}
+	return validateAirQualityDataRequest(req)
+}
+
+// readingID returns the ID of the reading in a request: the client-supplied ID, an ID derived from
+// the idempotency key and sensor, or a new random ID. The request must have been validated.
+func readingID(req *AirQualityDataRequest, idempotencyKey, sensorID string) uuid.UUID {
+	if req.ID != "" {
+		return uuid.MustParse(req.ID)
+	}
+	if idempotencyKey != "" {
+		return uuid.NewSHA1(readingIDNamespace, []byte(sensorID+"\n"+idempotencyKey))
+	}
+	return uuid.New()
+}
+
+// newReadingFromRequest converts a validated request to the domain model,
+// normalizing the parameter name and value to the canonical name and unit
+func newReadingFromRequest(req *AirQualityDataRequest, id uuid.UUID) *models.AirQualityData {
+	normalized, err := parameters.Default().Normalize(req.Parameter, req.Value, req.Unit)
+	if err != nil {
+		// Unreachable for validated requests; keep the reading as reported
+		normalized = &parameters.Normalized{
+			Parameter:     req.Parameter,
+			Value:         req.Value,
+			OriginalValue: req.Value,
+			OriginalUnit:  req.Unit,
+		}
+	}
+
+	data := models.NewAirQualityDataWithID(
+		id,
+		req.Latitude,
+		req.Longitude,
+		normalized.Parameter,
+		normalized.Value,
+		req.Timestamp,
+	)
+	data.Unit = normalized.Unit
+	data.OriginalValue = normalized.OriginalValue
+	data.OriginalUnit = normalized.OriginalUnit
+	data.ReadingMetadata = req.ReadingMetadata
+	data.Lateness, _ = timepolicy.Default().Classify(req.Timestamp, time.Now())
+	return data
+}
+
+// publishErrorStatus maps a publish failure to a response status; a full spool is temporary
+func publishErrorStatus(err error) int {
+	if errors.Is(err, spool.ErrFull) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
 }
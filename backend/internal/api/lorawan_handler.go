@@ -0,0 +1,249 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/lorawan"
+)
+
+const (
+	// WebhookTokenHeader is the header network servers send the shared webhook token in
+	WebhookTokenHeader = "X-Webhook-Token"
+	// LoRaWANSensorPrefix prefixes the device EUI to form the sensor ID of LoRaWAN readings
+	LoRaWANSensorPrefix = "lorawan:"
+	// maxUplinkSize is the maximum size of an uplink webhook body
+	maxUplinkSize = 64 << 10 // 64KB
+)
+
+// Sources of the location of LoRaWAN readings, recorded in the lorawan_location_source tag
+const (
+	locationSourcePayload = "payload"
+	locationSourceNetwork = "network"
+	locationSourceGateway = "gateway"
+)
+
+// errUnknownDevice is returned for uplinks of devices that are not registered or disabled
+var errUnknownDevice = errors.New("unknown device")
+
+// LoRaWANHandler handles uplink webhooks of LoRaWAN network servers. It shares the
+// normalization, deduplication and Kafka producer of an IngestHandler.
+type LoRaWANHandler struct {
+	handler  *IngestHandler
+	decoders *lorawan.Registry
+	sensors  SensorStore
+}
+
+// NewLoRaWANHandler creates a new LoRaWAN webhook handler that decodes payloads with the given registry
+func NewLoRaWANHandler(handler *IngestHandler, decoders *lorawan.Registry) *LoRaWANHandler {
+	return &LoRaWANHandler{
+		handler:  handler,
+		decoders: decoders,
+	}
+}
+
+// WithSensorStore requires devices to be registered as enabled sensors with the ID lorawan:<dev EUI>
+func (h *LoRaWANHandler) WithSensorStore(sensors SensorStore) *LoRaWANHandler {
+	h.sensors = sensors
+	return h
+}
+
+// RegisterRoutes registers the webhook routes to the given router
+func (h *LoRaWANHandler) RegisterRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	group := router.Group("/api/lorawan", middleware...)
+	group.POST("/uplink", h.PostUplink)
+}
+
+// WebhookAuth returns a middleware that checks the shared webhook token
+func WebhookAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := requestToken(c, WebhookTokenHeader)
+		if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid webhook token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// PostUplink godoc
+// @Summary Receive a LoRaWAN uplink
+// @Description Receive an uplink webhook of ChirpStack v4 or The Things Stack v3. The payload is decoded by the decoder registered for the device profile into one reading per parameter that share an observation ID. Devices without a location in the payload or the network server are placed at the gateway with the strongest signal. Other events, such as joins, are acknowledged and ignored.
+// @Tags lorawan
+// @Accept json
+// @Produce json
+// @Param event query string false "ChirpStack event type; events other than up are ignored"
+// @Param profile query string false "Device profile overriding the one in the envelope"
+// @Success 200 {object} ObservationResponse
+// @Success 202 {object} ObservationResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /api/lorawan/uplink [post]
+func (h *LoRaWANHandler) PostUplink(c *gin.Context) {
+	if event := c.Query("event"); event != "" && event != "up" {
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxUplinkSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read request body: " + err.Error(),
+		})
+		return
+	}
+
+	uplink, err := lorawan.ParseUplink(body)
+	if errors.Is(err, lorawan.ErrNotUplink) {
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// MAC-only uplinks on port 0 carry no application payload
+	if len(uplink.Payload) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Uplink without payload ignored"})
+		return
+	}
+
+	response, readings, err := h.decodeUplink(uplink, c.Query("profile"), time.Now().UTC())
+	if errors.Is(err, errUnknownDevice) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Acknowledge webhook retries without queueing the readings again
+	now := time.Now()
+	var pending []*models.AirQualityData
+	for _, data := range readings {
+		if !h.handler.recent.contains(data.ID.String(), now) {
+			pending = append(pending, data)
+		}
+	}
+	if len(pending) == 0 {
+		response.Message = "Duplicate uplink ignored"
+		response.Duplicate = true
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.handler.producer.ProduceAirQualityDataBatch(ctx, pending); err != nil {
+		c.JSON(publishErrorStatus(err), gin.H{
+			"error": "Failed to publish data: " + err.Error(),
+		})
+		return
+	}
+
+	for _, data := range pending {
+		h.handler.recent.remember(data.ID.String(), time.Now())
+	}
+
+	response.Message = "Uplink received and queued for processing"
+	c.JSON(http.StatusAccepted, response)
+}
+
+// decodeUplink decodes the payload of an uplink into an observation of the device. The location is
+// taken from the payload, then from the network server and finally from the gateway with the
+// strongest signal. IDs are derived from the device, frame counter and time of the uplink, so
+// webhook retries yield the same readings.
+func (h *LoRaWANHandler) decodeUplink(uplink *lorawan.Uplink, profile string, receivedAt time.Time) (ObservationResponse, []*models.AirQualityData, error) {
+	sensorID := LoRaWANSensorPrefix + uplink.DevEUI
+	if h.sensors != nil {
+		sensor, err := h.sensors.GetSensor(sensorID)
+		if err != nil {
+			return ObservationResponse{}, nil, fmt.Errorf("%w %q: %v", errUnknownDevice, sensorID, err)
+		}
+		if !sensor.Enabled {
+			return ObservationResponse{}, nil, fmt.Errorf("%w %q: sensor is disabled", errUnknownDevice, sensorID)
+		}
+	}
+
+	if profile == "" {
+		profile = uplink.DeviceProfile
+	}
+	decoder, ok := h.decoders.Lookup(profile)
+	if !ok {
+		return ObservationResponse{}, nil, fmt.Errorf("no decoder registered for device profile %q", profile)
+	}
+	decoded, err := decoder.Decode(uplink.FPort, uplink.Payload)
+	if err != nil {
+		return ObservationResponse{}, nil, fmt.Errorf("failed to decode payload of device profile %q: %w", profile, err)
+	}
+
+	location, source := decoded.Location, locationSourcePayload
+	if location == nil {
+		location, source = uplink.Location, locationSourceNetwork
+	}
+	if location == nil {
+		location, source = uplink.GatewayLocation(), locationSourceGateway
+	}
+	if location == nil {
+		return ObservationResponse{}, nil, errors.New("no location in payload, network server or gateway metadata")
+	}
+
+	req := ObservationRequest{
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+		Timestamp: uplink.ReceivedAt,
+		Values:    make(map[string]float64, len(decoded.Readings)),
+		Units:     make(map[string]string, len(decoded.Readings)),
+		ReadingMetadata: models.ReadingMetadata{
+			Humidity:    decoded.Humidity,
+			Temperature: decoded.Temperature,
+			Tags:        map[string]string{"network": "lorawan", "lorawan_location_source": source},
+		},
+	}
+	if req.Timestamp.IsZero() {
+		req.Timestamp = receivedAt
+	}
+	// A gateway's altitude says nothing about the device
+	if source != locationSourceGateway {
+		req.Altitude = location.Altitude
+	}
+	for _, reading := range decoded.Readings {
+		if _, ok := req.Values[reading.Parameter]; ok {
+			return ObservationResponse{}, nil, fmt.Errorf("payload contains %s more than once", reading.Parameter)
+		}
+		req.Values[reading.Parameter] = reading.Value
+		if reading.Unit != "" {
+			req.Units[reading.Parameter] = reading.Unit
+		}
+	}
+
+	key := fmt.Sprintf("lorawan\n%d\n%s", uplink.FCnt, req.Timestamp.Format(time.RFC3339Nano))
+	observationID, readings, err := parseObservation(&req, key, sensorID)
+	if err != nil {
+		return ObservationResponse{}, nil, err
+	}
+
+	response := ObservationResponse{
+		ObservationID: observationID.String(),
+		IDs:           make(map[string]string, len(readings)),
+	}
+	for _, data := range readings {
+		data.SensorID = sensorID
+		response.IDs[data.Parameter] = data.ID.String()
+	}
+	return response, readings, nil
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/lorawan"
+)
+
+func TestLoRaWANHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	chirpStack := func(profile, data string) string {
+		return `{
+			"time": "` + now + `",
+			"deviceInfo": {"deviceProfileName": "` + profile + `", "devEui": "0101010101010101"},
+			"fPort": 1, "fCnt": 42, "data": "` + data + `",
+			"rxInfo": [{"gatewayId": "gw-1", "rssi": -60, "snr": 9, "location": {"latitude": 41.015, "longitude": 28.979, "altitude": 80}}]
+		}`
+	}
+	thingsStack := `{
+		"end_device_ids": {"device_id": "roof-2", "dev_eui": "70B3D57ED005A8B1"},
+		"uplink_message": {
+			"f_port": 2, "f_cnt": 7, "frm_payload": "AQEA+iAYcmRwEUXYMA==", "received_at": "` + now + `",
+			"rx_metadata": [{"gateway_ids": {"gateway_id": "gw-1"}, "rssi": -70, "location": {"latitude": 40.0, "longitude": 29.0}}]
+		}
+	}`
+
+	decoders := lorawan.NewRegistry()
+	if err := decoders.Register(lorawan.CompactV1, lorawan.DecoderFunc(lorawan.DecodeCompactV1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := decoders.Alias("Air Sensor Rev B", lorawan.CompactV1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		body           string
+		token          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{"Missing Token", "", chirpStack("Air Sensor Rev B", "AQEA+gIBkBAA1xGC"), "", http.StatusUnauthorized, 0},
+		{"ChirpStack Uplink", "?event=up", chirpStack("Air Sensor Rev B", "AQEA+gIBkBAA1xGC"), "secret", http.StatusAccepted, 2},
+		{"Webhook Retry", "?event=up", chirpStack("Air Sensor Rev B", "AQEA+gIBkBAA1xGC"), "secret", http.StatusOK, 2},
+		{"Things Stack Uplink With Profile", "?profile=compact-v1", thingsStack, "secret", http.StatusAccepted, 3},
+		{"Join Event", "?event=join", `{"deviceInfo": {"devEui": "0101010101010101"}}`, "secret", http.StatusOK, 3},
+		{"Status Without Event Parameter", "", `{"deviceInfo": {"devEui": "0101010101010101"}, "margin": 10}`, "secret", http.StatusOK, 3},
+		{"Unknown Profile", "", chirpStack("Unknown", "AQEA+gIBkBAA1xGC"), "secret", http.StatusBadRequest, 3},
+		{"Undecodable Payload", "", chirpStack("Air Sensor Rev B", "AgEA+g=="), "secret", http.StatusBadRequest, 3},
+		{"Invalid Envelope", "", `{"foo": 1}`, "secret", http.StatusBadRequest, 3},
+	}
+
+	publisher := &MockPublisher{}
+	router := gin.New()
+	NewLoRaWANHandler(NewIngestHandler(publisher), decoders).RegisterRoutes(router, WebhookAuth("secret"))
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/lorawan/uplink"+tc.query, strings.NewReader(tc.body))
+			if tc.token != "" {
+				req.Header.Set(WebhookTokenHeader, tc.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if publisher.count() != tc.expectedCount {
+				t.Errorf("Expected %d published readings, got %d", tc.expectedCount, publisher.count())
+			}
+		})
+	}
+
+	// The ChirpStack device has no location of its own and is placed at the gateway
+	first := publisher.readings[0]
+	if first.SensorID != "lorawan:0101010101010101" || first.Latitude != 41.015 || first.Longitude != 28.979 {
+		t.Errorf("Expected reading of the device at the gateway, got %+v", first)
+	}
+	if first.Tags["lorawan_location_source"] != "gateway" || first.Altitude != nil {
+		t.Errorf("Expected gateway location without altitude, got tags %v and altitude %v", first.Tags, first.Altitude)
+	}
+	if first.Humidity == nil || *first.Humidity != 65 || first.ObservationID == nil || *first.ObservationID != *publisher.readings[1].ObservationID {
+		t.Errorf("Expected readings of one observation with humidity, got %+v", first)
+	}
+
+	// The Things Stack device reports its GPS fix in the payload
+	last := publisher.readings[2]
+	if last.Latitude != 41.015 || last.Longitude != 28.979 || last.Tags["lorawan_location_source"] != "payload" {
+		t.Errorf("Expected reading at the GPS fix, got %+v", last)
+	}
+}
+
+func TestLoRaWANHandlerSensorStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := NewMockSensorStore()
+	store.CreateSensor(&models.Sensor{ID: "lorawan:0101010101010101", Enabled: false})
+
+	publisher := &MockPublisher{}
+	router := gin.New()
+	NewLoRaWANHandler(NewIngestHandler(publisher), lorawan.Default()).WithSensorStore(store).RegisterRoutes(router)
+
+	body := `{
+		"time": "` + time.Now().UTC().Format(time.RFC3339) + `",
+		"deviceInfo": {"deviceProfileName": "compact-v1", "devEui": "0101010101010101"},
+		"fPort": 1, "fCnt": 1, "data": "AQEA+iAYcmRwEUXYMA=="
+	}`
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/lorawan/uplink", strings.NewReader(body)))
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a disabled device, got %d", w.Code)
+	}
+
+	store.SetSensorEnabled("lorawan:0101010101010101", true)
+	w := send()
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response ObservationResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.IDs["PM2.5"] != publisher.readings[0].ID.String() {
+		t.Errorf("Expected the reading ID in the response, got %+v", response)
+	}
+}
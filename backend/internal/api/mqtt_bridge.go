@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/logger"
+)
+
+// MQTTConfig holds the configuration of the MQTT bridge
+type MQTTConfig struct {
+	BrokerURL    string
+	ClientID     string
+	Username     string
+	Password     string
+	TopicPattern string
+	QoS          byte
+	// Locations maps sensor IDs from the topic to coordinates for payloads without a location
+	Locations map[string][2]float64
+}
+
+// MQTTBridge subscribes to sensor topics on an MQTT broker and publishes the readings to Kafka
+type MQTTBridge struct {
+	producer Publisher
+	sensors  SensorStore
+	config   MQTTConfig
+	pattern  *TopicPattern
+	client   mqtt.Client
+}
+
+// mqttPayload represents a JSON payload published by a sensor.
+// All fields are optional and fall back to the topic, the configured location or the receive time.
+type mqttPayload struct {
+	ID        string     `json:"id"`
+	Latitude  *float64   `json:"latitude"`
+	Longitude *float64   `json:"longitude"`
+	Parameter string     `json:"parameter"`
+	Value     *float64   `json:"value"`
+	Unit      string     `json:"unit"`
+	Timestamp *time.Time `json:"timestamp"`
+	models.ReadingMetadata
+}
+
+// NewMQTTBridge creates a new MQTT bridge. When sensors is not nil, the {id} topic segment
+// must name a registered, enabled sensor.
+func NewMQTTBridge(producer Publisher, sensors SensorStore, config MQTTConfig) (*MQTTBridge, error) {
+	pattern, err := ParseTopicPattern(config.TopicPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MQTTBridge{
+		producer: producer,
+		sensors:  sensors,
+		config:   config,
+		pattern:  pattern,
+	}, nil
+}
+
+// Start connects to the broker and subscribes to the topic pattern.
+// The subscription is restored automatically after a reconnect.
+func (b *MQTTBridge) Start(ctx context.Context) error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.config.BrokerURL).
+		SetClientID(b.config.ClientID).
+		SetUsername(b.config.Username).
+		SetPassword(b.config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetCleanSession(false)
+
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		filter := b.pattern.Filter()
+		token := client.Subscribe(filter, b.config.QoS, func(_ mqtt.Client, msg mqtt.Message) {
+			b.handleMessage(ctx, msg.Topic(), msg.Payload())
+		})
+		if token.Wait() && token.Error() != nil {
+			logger.Error("Failed to subscribe to MQTT topic %s: %v", filter, token.Error())
+			return
+		}
+		logger.Info("Subscribed to MQTT topic %s", filter)
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		logger.Warn("MQTT connection lost: %v", err)
+	})
+
+	b.client = mqtt.NewClient(opts)
+	token := b.client.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		// SetConnectRetry keeps trying in the background
+		logger.Warn("MQTT broker %s not reachable yet, retrying in background", b.config.BrokerURL)
+		return nil
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+
+	return nil
+}
+
+// Close disconnects from the broker
+func (b *MQTTBridge) Close() {
+	if b.client != nil {
+		b.client.Disconnect(250)
+	}
+}
+
+// handleMessage converts an MQTT message and publishes it to Kafka
+func (b *MQTTBridge) handleMessage(ctx context.Context, topic string, payload []byte) {
+	data, err := b.decodeMessage(topic, payload, time.Now().UTC())
+	if err != nil {
+		logger.Warn("Rejected MQTT message on %s: %v", topic, err)
+		return
+	}
+
+	produceCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := b.producer.ProduceAirQualityData(produceCtx, data); err != nil {
+		logger.Error("Failed to publish MQTT message from %s: %v", topic, err)
+		return
+	}
+
+	logger.Debug("Queued MQTT reading %s from %s", data.ID, topic)
+}
+
+// decodeMessage maps a topic and a JSON or plain numeric payload to a validated reading
+func (b *MQTTBridge) decodeMessage(topic string, payload []byte, receivedAt time.Time) (*models.AirQualityData, error) {
+	values, ok := b.pattern.Match(topic)
+	if !ok {
+		return nil, fmt.Errorf("topic does not match pattern %q", b.config.TopicPattern)
+	}
+
+	sensorID := values["id"]
+	if b.sensors != nil {
+		sensor, err := b.sensors.GetSensor(sensorID)
+		if err != nil {
+			return nil, fmt.Errorf("unknown sensor %q: %w", sensorID, err)
+		}
+		if !sensor.Enabled {
+			return nil, fmt.Errorf("sensor %q is disabled", sensorID)
+		}
+	}
+
+	var p mqttPayload
+	trimmed := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), &p); err != nil {
+			return nil, fmt.Errorf("invalid JSON payload: %w", err)
+		}
+	} else {
+		value, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil, fmt.Errorf("payload is neither JSON nor a number: %q", trimmed)
+		}
+		p.Value = &value
+	}
+
+	req := AirQualityDataRequest{
+		ID:              p.ID,
+		Parameter:       p.Parameter,
+		Unit:            p.Unit,
+		Timestamp:       receivedAt,
+		ReadingMetadata: p.ReadingMetadata,
+	}
+	if req.Parameter == "" {
+		req.Parameter = values["parameter"]
+	}
+	if p.Value != nil {
+		req.Value = *p.Value
+	}
+	if p.Timestamp != nil {
+		req.Timestamp = *p.Timestamp
+	}
+
+	if p.Latitude != nil && p.Longitude != nil {
+		req.Latitude = *p.Latitude
+		req.Longitude = *p.Longitude
+	} else if location, ok := b.config.Locations[sensorID]; ok {
+		req.Latitude = location[0]
+		req.Longitude = location[1]
+	} else {
+		return nil, fmt.Errorf("no location in payload and none configured for sensor %q", sensorID)
+	}
+
+	if err := validateDecodedRequest(&req); err != nil {
+		return nil, err
+	}
+
+	data := newReadingFromRequest(&req, readingID(&req, "", sensorID))
+	data.SensorID = sensorID
+
+	return data, nil
+}
+
+// TopicPattern matches MQTT topics against a pattern with named segments such as sensors/{id}/{parameter}
+type TopicPattern struct {
+	segments []string
+}
+
+// ParseTopicPattern parses a topic pattern. Segments may be literals, {name} placeholders,
+// the single-level wildcard + or a trailing multi-level wildcard #.
+func ParseTopicPattern(pattern string) (*TopicPattern, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("topic pattern must not be empty")
+	}
+
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if segment == "#" && i != len(segments)-1 {
+			return nil, fmt.Errorf("invalid topic pattern %q: # must be the last segment", pattern)
+		}
+		if strings.ContainsAny(segment, "{}") && !isPlaceholder(segment) {
+			return nil, fmt.Errorf("invalid topic pattern %q: placeholder must span a whole segment", pattern)
+		}
+	}
+
+	return &TopicPattern{segments: segments}, nil
+}
+
+// Filter returns the MQTT subscription filter for the pattern
+func (p *TopicPattern) Filter() string {
+	filter := make([]string, len(p.segments))
+	for i, segment := range p.segments {
+		if isPlaceholder(segment) {
+			filter[i] = "+"
+		} else {
+			filter[i] = segment
+		}
+	}
+	return strings.Join(filter, "/")
+}
+
+// Match checks a topic against the pattern and returns the placeholder values
+func (p *TopicPattern) Match(topic string) (map[string]string, bool) {
+	levels := strings.Split(topic, "/")
+	values := make(map[string]string)
+
+	for i, segment := range p.segments {
+		if segment == "#" {
+			return values, true
+		}
+		if i >= len(levels) {
+			return nil, false
+		}
+		switch {
+		case isPlaceholder(segment):
+			values[segment[1:len(segment)-1]] = levels[i]
+		case segment == "+":
+		case segment != levels[i]:
+			return nil, false
+		}
+	}
+
+	if len(levels) != len(p.segments) {
+		return nil, false
+	}
+	return values, true
+}
+
+// isPlaceholder reports whether a pattern segment is a {name} placeholder
+func isPlaceholder(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// ParseSensorLocations parses a list of sensor locations in the form id:lat:lon,id:lat:lon
+func ParseSensorLocations(value string) (map[string][2]float64, error) {
+	locations := make(map[string][2]float64)
+	if strings.TrimSpace(value) == "" {
+		return locations, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid sensor location %q, expected id:lat:lon", entry)
+		}
+		lat, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latitude in sensor location %q: %w", entry, err)
+		}
+		lon, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid longitude in sensor location %q: %w", entry, err)
+		}
+		locations[parts[0]] = [2]float64{lat, lon}
+	}
+
+	return locations, nil
+}
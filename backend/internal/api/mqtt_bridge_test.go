@@ -0,0 +1,81 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopicPattern(t *testing.T) {
+	pattern, err := ParseTopicPattern("sensors/{id}/{parameter}")
+	if err != nil {
+		t.Fatalf("Failed to parse pattern: %v", err)
+	}
+
+	if filter := pattern.Filter(); filter != "sensors/+/+" {
+		t.Errorf("Expected filter sensors/+/+, got %s", filter)
+	}
+
+	values, ok := pattern.Match("sensors/station-7/PM2.5")
+	if !ok {
+		t.Fatalf("Expected topic to match")
+	}
+	if values["id"] != "station-7" || values["parameter"] != "PM2.5" {
+		t.Errorf("Unexpected placeholder values: %v", values)
+	}
+
+	for _, topic := range []string{"sensors/station-7", "sensors/station-7/PM2.5/raw", "devices/station-7/PM2.5"} {
+		if _, ok := pattern.Match(topic); ok {
+			t.Errorf("Expected topic %s not to match", topic)
+		}
+	}
+
+	if _, err := ParseTopicPattern("sensors/#/{id}"); err == nil {
+		t.Errorf("Expected error for # before the last segment")
+	}
+}
+
+func TestMQTTBridgeDecodeMessage(t *testing.T) {
+	bridge, err := NewMQTTBridge(nil, nil, MQTTConfig{
+		TopicPattern: "sensors/{id}/{parameter}",
+		Locations:    map[string][2]float64{"station-7": {41.015, 28.979}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create bridge: %v", err)
+	}
+
+	receivedAt := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	// Plain payload uses the topic parameter, the configured location and the receive time
+	data, err := bridge.decodeMessage("sensors/station-7/PM2.5", []byte(" 25.5\n"), receivedAt)
+	if err != nil {
+		t.Fatalf("Failed to decode plain payload: %v", err)
+	}
+	if data.Parameter != "PM2.5" || data.Value != 25.5 || data.Latitude != 41.015 || !data.Timestamp.Equal(receivedAt) || data.SensorID != "station-7" {
+		t.Errorf("Unexpected reading from plain payload: %+v", data)
+	}
+
+	// JSON payload fields take precedence
+	data, err = bridge.decodeMessage("sensors/unknown/PM10",
+		[]byte(`{"latitude": 40.0, "longitude": 29.0, "value": 40.0, "timestamp": "2025-05-02T13:00:00Z"}`), receivedAt)
+	if err != nil {
+		t.Fatalf("Failed to decode JSON payload: %v", err)
+	}
+	if data.Parameter != "PM10" || data.Latitude != 40.0 || data.Timestamp.Hour() != 13 || data.Timestamp.Minute() != 0 {
+		t.Errorf("Unexpected reading from JSON payload: %+v", data)
+	}
+
+	rejected := []struct {
+		topic   string
+		payload string
+	}{
+		{"sensors/unknown/PM2.5", "25.5"},
+		{"sensors/station-7/PM2.5", "-3"},
+		{"sensors/station-7/PM2.5", "n/a"},
+		{"other/station-7/PM2.5", "25.5"},
+	}
+	for _, tc := range rejected {
+		if _, err := bridge.decodeMessage(tc.topic, []byte(tc.payload), receivedAt); err == nil {
+			t.Errorf("Expected %s with payload %q to be rejected", tc.topic, tc.payload)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/user/airpollution/internal/models"
+)
+
+// MaxObservationParameters is the maximum number of parameters in a single observation
+const MaxObservationParameters = 32
+
+// ObservationRequest represents several parameters measured at the same location and instant
+type ObservationRequest struct {
+	// ID is an optional client-supplied observation ID (UUID) that makes resubmissions idempotent
+	ID        string    `json:"id,omitempty"`
+	Latitude  float64   `json:"latitude" binding:"required"`
+	Longitude float64   `json:"longitude" binding:"required"`
+	Timestamp time.Time `json:"timestamp" binding:"required"`
+	// Values maps each parameter to its measured value
+	Values map[string]float64 `json:"values" binding:"required"`
+	// Units optionally maps a parameter of Values to the unit of its value
+	Units map[string]string `json:"units,omitempty"`
+	// Optional humidity, temperature, altitude, device details and tags shared by all readings
+	models.ReadingMetadata
+}
+
+// ObservationResponse represents the response body for an observation
+type ObservationResponse struct {
+	Message       string            `json:"message"`
+	ObservationID string            `json:"observation_id"`
+	IDs           map[string]string `json:"ids"`
+	Duplicate     bool              `json:"duplicate,omitempty"`
+}
+
+// PostObservation godoc
+// @Summary Submit a multi-parameter observation
+// @Description Submit several parameters measured at the same location and time. The observation is fanned out into one reading per parameter that share the observation ID. The observation is rejected if any of its values is invalid.
+// @Tags data
+// @Accept json
+// @Produce json
+// @Param data body ObservationRequest true "Observation"
+// @Param Idempotency-Key header string false "Client key identifying the submission"
+// @Success 200 {object} ObservationResponse
+// @Success 202 {object} ObservationResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /api/data/observations [post]
+func (h *IngestHandler) PostObservation(c *gin.Context) {
+	var req ObservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	prov := provenanceFromContext(c)
+	observationID, readings, err := parseObservation(&req, c.GetHeader(IdempotencyKeyHeader), prov.SensorID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response := ObservationResponse{
+		ObservationID: observationID.String(),
+		IDs:           make(map[string]string, len(readings)),
+	}
+
+	// Only queue the readings that were not submitted before
+	now := time.Now()
+	var pending []*models.AirQualityData
+	for _, data := range readings {
+		prov.apply(data)
+		response.IDs[data.Parameter] = data.ID.String()
+		if !h.recent.contains(data.ID.String(), now) {
+			pending = append(pending, data)
+		}
+	}
+
+	// Acknowledge resubmissions without queueing them again
+	if len(pending) == 0 {
+		response.Message = "Duplicate submission ignored"
+		response.Duplicate = true
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Each new reading counts against the rate limit of the sender
+	if allowed, retryAfter := allowReadings(c, len(pending)); !allowed {
+		respondRateLimited(c, retryAfter)
+		return
+	}
+
+	// Publish the readings to Kafka in one write
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.producer.ProduceAirQualityDataBatch(ctx, pending); err != nil {
+		c.JSON(publishErrorStatus(err), gin.H{
+			"error": "Failed to publish data: " + err.Error(),
+		})
+		return
+	}
+
+	for _, data := range pending {
+		h.recent.remember(data.ID.String(), time.Now())
+	}
+
+	response.Message = "Observation received and queued for processing"
+	c.JSON(http.StatusAccepted, response)
+}
+
+// parseObservation validates an observation and fans it out into one reading per parameter, in
+// parameter order. Reading IDs are derived from the observation ID and the canonical parameter, so
+// a resubmitted observation yields the same reading IDs.
+func parseObservation(req *ObservationRequest, idempotencyKey, sensorID string) (uuid.UUID, []*models.AirQualityData, error) {
+	if len(req.Values) == 0 {
+		return uuid.Nil, nil, errors.New("Observation must contain at least one value")
+	}
+	if len(req.Values) > MaxObservationParameters {
+		return uuid.Nil, nil, fmt.Errorf("Observation exceeds maximum of %d values", MaxObservationParameters)
+	}
+	for parameter := range req.Units {
+		if _, ok := req.Values[parameter]; !ok {
+			return uuid.Nil, nil, fmt.Errorf("Unit given for %s, which has no value", parameter)
+		}
+	}
+
+	var observationID uuid.UUID
+	switch {
+	case req.ID != "":
+		id, err := uuid.Parse(req.ID)
+		if err != nil {
+			return uuid.Nil, nil, errors.New("ID must be a valid UUID")
+		}
+		observationID = id
+	case idempotencyKey != "":
+		observationID = uuid.NewSHA1(readingIDNamespace, []byte(sensorID+"\nobservation\n"+idempotencyKey))
+	default:
+		observationID = uuid.New()
+	}
+
+	names := make([]string, 0, len(req.Values))
+	for parameter := range req.Values {
+		names = append(names, parameter)
+	}
+	sort.Strings(names)
+
+	readings := make([]*models.AirQualityData, 0, len(names))
+	seen := make(map[string]string, len(names))
+	for _, parameter := range names {
+		reading := AirQualityDataRequest{
+			Latitude:        req.Latitude,
+			Longitude:       req.Longitude,
+			Parameter:       parameter,
+			Value:           req.Values[parameter],
+			Timestamp:       req.Timestamp,
+			Unit:            req.Units[parameter],
+			ReadingMetadata: req.ReadingMetadata,
+		}
+		if err := validateAirQualityDataRequest(&reading); err != nil {
+			return uuid.Nil, nil, fmt.Errorf("%s: %w", parameter, err)
+		}
+
+		data := newReadingFromRequest(&reading, uuid.Nil)
+		if other, ok := seen[data.Parameter]; ok {
+			return uuid.Nil, nil, fmt.Errorf("%s and %s are the same parameter", other, parameter)
+		}
+		seen[data.Parameter] = parameter
+
+		data.ID = uuid.NewSHA1(observationID, []byte(data.Parameter))
+		data.ObservationID = &observationID
+		readings = append(readings, data)
+	}
+
+	return observationID, readings, nil
+}
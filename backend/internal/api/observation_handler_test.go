@@ -0,0 +1,116 @@
+package api
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseObservation(t *testing.T) {
+	req := ObservationRequest{
+		Latitude:  41.015,
+		Longitude: 28.979,
+		Timestamp: time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC),
+		Values:    map[string]float64{"pm25": 25.0, "PM10": 40.0, "NO2": 10.0, "O3": 0.0},
+		Units:     map[string]string{"NO2": "ppb"},
+	}
+
+	observationID, readings, err := parseObservation(&req, "", "station-7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(readings) != 4 {
+		t.Fatalf("Expected 4 readings, got %d", len(readings))
+	}
+
+	expectedOrder := []string{"NO2", "O3", "PM10", "PM2.5"}
+	ids := make(map[uuid.UUID]bool)
+	for i, data := range readings {
+		if data.Parameter != expectedOrder[i] {
+			t.Errorf("Expected reading %d to be %s, got %s", i, expectedOrder[i], data.Parameter)
+		}
+		if data.ObservationID == nil || *data.ObservationID != observationID {
+			t.Errorf("Expected %s to share observation ID %s", data.Parameter, observationID)
+		}
+		if !data.Timestamp.Equal(req.Timestamp) || data.Latitude != req.Latitude {
+			t.Errorf("Expected %s to share the location and time of the observation", data.Parameter)
+		}
+		ids[data.ID] = true
+	}
+	if len(ids) != 4 {
+		t.Errorf("Expected distinct reading IDs, got %d", len(ids))
+	}
+
+	if math.Abs(readings[0].Value-18.816) > 0.001 || readings[0].OriginalUnit != "ppb" {
+		t.Errorf("Expected NO2 to be converted from ppb, got %f", readings[0].Value)
+	}
+}
+
+func TestParseObservationIdempotent(t *testing.T) {
+	req := ObservationRequest{
+		Latitude:  41.015,
+		Longitude: 28.979,
+		Timestamp: time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC),
+		Values:    map[string]float64{"PM2.5": 25.0, "PM10": 40.0},
+	}
+
+	firstID, first, err := parseObservation(&req, "retry-key", "station-7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	secondID, second, err := parseObservation(&req, "retry-key", "station-7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if firstID != secondID {
+		t.Errorf("Expected the same observation ID for the same idempotency key")
+	}
+	for i := range first {
+		if first[i].ID != second[i].ID {
+			t.Errorf("Expected the same reading ID for %s", first[i].Parameter)
+		}
+	}
+
+	otherID, _, _ := parseObservation(&req, "retry-key", "station-8")
+	if otherID == firstID {
+		t.Errorf("Expected a different observation ID for another sensor")
+	}
+}
+
+func TestParseObservationInvalid(t *testing.T) {
+	base := func() ObservationRequest {
+		return ObservationRequest{
+			Latitude:  41.015,
+			Longitude: 28.979,
+			Timestamp: time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC),
+			Values:    map[string]float64{"PM2.5": 25.0},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(req *ObservationRequest)
+	}{
+		{"No Values", func(req *ObservationRequest) { req.Values = map[string]float64{} }},
+		{"Invalid ID", func(req *ObservationRequest) { req.ID = "abc" }},
+		{"Unknown Parameter", func(req *ObservationRequest) { req.Values["benzene"] = 1.0 }},
+		{"Negative Value", func(req *ObservationRequest) { req.Values["PM10"] = -1.0 }},
+		{"Same Parameter Twice", func(req *ObservationRequest) { req.Values["pm25"] = 26.0 }},
+		{"Unit Without Value", func(req *ObservationRequest) { req.Units = map[string]string{"NO2": "ppb"} }},
+		{"Latitude Out Of Range", func(req *ObservationRequest) { req.Latitude = 91 }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := base()
+			tc.modify(&req)
+			if _, _, err := parseObservation(&req, "", ""); err == nil {
+				t.Errorf("Expected validation error but got nil")
+			}
+		})
+	}
+}
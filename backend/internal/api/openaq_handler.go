@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/openaq"
+)
+
+const (
+	// DefaultOpenAQNetwork is the source network tagged on imported readings when none is given
+	DefaultOpenAQNetwork = "openaq"
+	// maxOpenAQDocumentSize is the maximum size of an OpenAQ document sent to the import endpoint
+	maxOpenAQDocumentSize = 64 << 20 // 64MB
+)
+
+// OpenAQImportOptions control how OpenAQ measurements are mapped to readings
+type OpenAQImportOptions struct {
+	// Network is the source network tagged on every reading
+	Network string
+	// Latitude and Longitude are used for results without coordinates, such as v3 sensor measurements
+	Latitude  *float64
+	Longitude *float64
+	// LocationID is used for results without a location ID
+	LocationID string
+}
+
+// OpenAQHandler handles the OpenAQ import endpoint
+type OpenAQHandler struct {
+	producer Publisher
+}
+
+// NewOpenAQHandler creates a new OpenAQ import handler
+func NewOpenAQHandler(producer Publisher) *OpenAQHandler {
+	return &OpenAQHandler{
+		producer: producer,
+	}
+}
+
+// PostOpenAQImport godoc
+// @Summary Import OpenAQ measurements
+// @Description Import an OpenAQ v2 or v3 measurements document. Readings are published to Kafka like any other submission and tagged with the source network.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param network query string false "Source network tagged on the readings" default(openaq)
+// @Param latitude query number false "Latitude of results without coordinates"
+// @Param longitude query number false "Longitude of results without coordinates"
+// @Param location_id query string false "OpenAQ location ID of results without one"
+// @Success 202 {object} UploadSummary
+// @Failure 400 {object} UploadSummary
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} UploadSummary
+// @Failure 503 {object} UploadSummary
+// @Router /api/admin/imports/openaq [post]
+func (h *OpenAQHandler) PostOpenAQImport(c *gin.Context) {
+	summary := UploadSummary{
+		Format: "openaq",
+		Errors: []UploadRowError{},
+	}
+
+	opts, err := openAQOptionsFromQuery(c)
+	if err != nil {
+		summary.Error = err.Error()
+		c.JSON(http.StatusBadRequest, summary)
+		return
+	}
+
+	measurements, err := openaq.Parse(http.MaxBytesReader(c.Writer, c.Request.Body, maxOpenAQDocumentSize))
+	if err != nil {
+		summary.Error = err.Error()
+		c.JSON(http.StatusBadRequest, summary)
+		return
+	}
+
+	summary, err = ImportOpenAQ(c.Request.Context(), h.producer, measurements, opts)
+	if err != nil {
+		summary.Error = "Failed to publish data: " + err.Error()
+		c.JSON(publishErrorStatus(err), summary)
+		return
+	}
+
+	status := http.StatusAccepted
+	if summary.RowsAccepted == 0 {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, summary)
+}
+
+// RegisterRoutes registers the import routes to the given router
+func (h *OpenAQHandler) RegisterRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	group := router.Group("/api/admin/imports", middleware...)
+	group.POST("/openaq", h.PostOpenAQImport)
+}
+
+// openAQOptionsFromQuery reads the import options from the query string
+func openAQOptionsFromQuery(c *gin.Context) (OpenAQImportOptions, error) {
+	opts := OpenAQImportOptions{
+		Network:    c.DefaultQuery("network", DefaultOpenAQNetwork),
+		LocationID: c.Query("location_id"),
+	}
+
+	for name, target := range map[string]**float64{"latitude": &opts.Latitude, "longitude": &opts.Longitude} {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*target = &value
+	}
+
+	return opts, nil
+}
+
+// ImportOpenAQ validates OpenAQ measurements like uploaded rows and publishes the valid ones in chunks.
+// Results are numbered from 1 in the summary. Reading IDs are derived from the location, parameter and
+// timestamp, so importing the same document twice does not duplicate readings.
+func ImportOpenAQ(ctx context.Context, publisher Publisher, measurements []openaq.Measurement, opts OpenAQImportOptions) (UploadSummary, error) {
+	summary := UploadSummary{
+		Format: "openaq",
+		Errors: []UploadRowError{},
+	}
+
+	if opts.Network == "" {
+		opts.Network = DefaultOpenAQNetwork
+	}
+	if len(opts.Network) > MaxMetadataLength {
+		return summary, fmt.Errorf("network must be at most %d characters", MaxMetadataLength)
+	}
+
+	chunk := make([]*models.AirQualityData, 0, uploadChunkSize)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		publishCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := publisher.ProduceAirQualityDataBatch(publishCtx, chunk); err != nil {
+			return err
+		}
+		summary.RowsAccepted += len(chunk)
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for i := range measurements {
+		summary.RowsRead++
+		data, err := readingFromOpenAQ(&measurements[i], opts)
+		if err != nil {
+			summary.RowsRejected++
+			if len(summary.Errors) < maxUploadErrors {
+				summary.Errors = append(summary.Errors, UploadRowError{Row: i + 1, Error: err.Error()})
+			} else {
+				summary.ErrorsTruncated = true
+			}
+			continue
+		}
+
+		chunk = append(chunk, data)
+		if len(chunk) >= uploadChunkSize {
+			if err := flush(); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	return summary, flush()
+}
+
+// readingFromOpenAQ maps an OpenAQ measurement onto a validated reading. Readings of a known OpenAQ
+// location get the sensor ID openaq:<location ID>, so re-imports are deduplicated on the natural key.
+func readingFromOpenAQ(m *openaq.Measurement, opts OpenAQImportOptions) (*models.AirQualityData, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	latitude, longitude := m.Latitude, m.Longitude
+	if latitude == nil || longitude == nil {
+		latitude, longitude = opts.Latitude, opts.Longitude
+	}
+	if latitude == nil || longitude == nil {
+		return nil, fmt.Errorf("result has no coordinates, set latitude and longitude for the import")
+	}
+
+	locationID := m.LocationID
+	if locationID == "" {
+		locationID = opts.LocationID
+	}
+
+	req := &AirQualityDataRequest{
+		Latitude:  *latitude,
+		Longitude: *longitude,
+		Parameter: m.Parameter,
+		Value:     m.Value,
+		Timestamp: m.Timestamp,
+		Unit:      m.Unit,
+	}
+	req.Tags = map[string]string{"network": opts.Network}
+	if locationID != "" {
+		req.Tags["openaq_location_id"] = locationID
+	}
+	if err := validateDecodedRequest(req); err != nil {
+		return nil, err
+	}
+
+	sensorID := ""
+	key := fmt.Sprintf("openaq\n%s\n%s\n%f,%f", m.Parameter, m.Timestamp.Format(time.RFC3339), *latitude, *longitude)
+	if locationID != "" {
+		sensorID = "openaq:" + locationID
+		key = fmt.Sprintf("openaq\n%s\n%s", m.Parameter, m.Timestamp.Format(time.RFC3339))
+	}
+
+	data := newReadingFromRequest(req, readingID(req, key, sensorID))
+	data.SensorID = sensorID
+	return data, nil
+}
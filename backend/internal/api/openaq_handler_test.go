@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/services/openaq"
+)
+
+func TestImportOpenAQ(t *testing.T) {
+	timestamp := time.Now().Add(-time.Hour).Truncate(time.Hour)
+	latitude, longitude := 41.043, 29.009
+
+	measurements := []openaq.Measurement{
+		{Version: 2, LocationID: "8118", Parameter: "pm25", Unit: "µg/m³", Value: 12.5, Timestamp: timestamp, Latitude: &latitude, Longitude: &longitude},
+		{Version: 3, Parameter: "o3", Unit: "ppm", Value: 0.031, Timestamp: timestamp},
+		{Version: 2, LocationID: "8118", Parameter: "bc", Unit: "µg/m³", Value: 1.2, Timestamp: timestamp, Latitude: &latitude, Longitude: &longitude},
+	}
+
+	publisher := &MockPublisher{}
+	opts := OpenAQImportOptions{Network: "airnow", Latitude: &latitude, Longitude: &longitude, LocationID: "2178"}
+	summary, err := ImportOpenAQ(context.Background(), publisher, measurements, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if summary.RowsRead != 3 || summary.RowsAccepted != 2 || summary.RowsRejected != 1 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Row != 3 {
+		t.Errorf("Expected the unknown parameter in row 3 to be rejected, got %+v", summary.Errors)
+	}
+
+	pm25, o3 := publisher.readings[0], publisher.readings[1]
+	if pm25.Parameter != "PM2.5" || pm25.SensorID != "openaq:8118" || pm25.Tags["network"] != "airnow" {
+		t.Errorf("Unexpected PM2.5 reading %+v", pm25)
+	}
+	if o3.Parameter != "O3" || o3.Unit != "µg/m³" || o3.OriginalUnit != "ppm" || o3.SensorID != "openaq:2178" {
+		t.Errorf("Expected the v3 reading to be normalized and use the fallback location, got %+v", o3)
+	}
+
+	// Importing the same measurements again yields the same reading IDs
+	again := &MockPublisher{}
+	if _, err := ImportOpenAQ(context.Background(), again, measurements, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again.readings[0].ID != pm25.ID || again.readings[1].ID != o3.ID {
+		t.Errorf("Expected stable reading IDs across imports")
+	}
+}
+
+func TestImportOpenAQRequiresCoordinates(t *testing.T) {
+	measurements := []openaq.Measurement{
+		{Version: 3, Parameter: "pm10", Unit: "µg/m³", Value: 30, Timestamp: time.Now().Add(-time.Hour)},
+	}
+
+	summary, err := ImportOpenAQ(context.Background(), &MockPublisher{}, measurements, OpenAQImportOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.RowsAccepted != 0 || summary.RowsRejected != 1 {
+		t.Errorf("Expected the reading without coordinates to be rejected, got %+v", summary)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/timepolicy"
+)
+
+// OrderClassifier tags readings that are older than the latest published reading of their sensor and
+// parameter as out of order, and publishes them to the wrapped publisher. Late readings stay late.
+type OrderClassifier struct {
+	next    Publisher
+	tracker *timepolicy.OrderTracker
+}
+
+// NewOrderClassifier creates a publisher that classifies out-of-order readings
+func NewOrderClassifier(next Publisher) *OrderClassifier {
+	return &OrderClassifier{next: next, tracker: timepolicy.NewOrderTracker()}
+}
+
+// ProduceAirQualityData classifies and publishes a reading. The reading only becomes the latest of
+// its sensor once it is published, so a reading that failed to publish does not make the readings
+// after it out of order.
+func (o *OrderClassifier) ProduceAirQualityData(ctx context.Context, data *models.AirQualityData) error {
+	o.classify(data, nil)
+	if err := o.next.ProduceAirQualityData(ctx, data); err != nil {
+		return err
+	}
+	o.advance(data)
+	return nil
+}
+
+// ProduceAirQualityDataBatch classifies the readings of a batch in order and publishes them. The
+// readings of the batch only become the latest of their sensors once the batch is published.
+func (o *OrderClassifier) ProduceAirQualityDataBatch(ctx context.Context, data []*models.AirQualityData) error {
+	// Readings are also out of order when they are older than a reading before them in the batch
+	batch := timepolicy.NewOrderTracker()
+	for _, d := range data {
+		o.classify(d, batch)
+		if d.Lateness == models.LatenessOnTime {
+			batch.Advance(d.SensorID, d.Parameter, d.Timestamp)
+		}
+	}
+	if err := o.next.ProduceAirQualityDataBatch(ctx, data); err != nil {
+		return err
+	}
+	for _, d := range data {
+		o.advance(d)
+	}
+	return nil
+}
+
+// classify tags an on-time reading as out of order when its sensor already published a newer one,
+// or sent one before it in the same batch
+func (o *OrderClassifier) classify(data *models.AirQualityData, batch *timepolicy.OrderTracker) {
+	if data.Lateness != models.LatenessOnTime {
+		return
+	}
+	if o.tracker.OutOfOrder(data.SensorID, data.Parameter, data.Timestamp) ||
+		(batch != nil && batch.OutOfOrder(data.SensorID, data.Parameter, data.Timestamp)) {
+		data.Lateness = models.LatenessOutOfOrder
+	}
+}
+
+// advance records a published on-time reading as the latest of its sensor
+func (o *OrderClassifier) advance(data *models.AirQualityData) {
+	if data.Lateness == models.LatenessOnTime {
+		o.tracker.Advance(data.SensorID, data.Parameter, data.Timestamp)
+	}
+}
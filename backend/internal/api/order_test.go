@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+func TestOrderClassifierTagsOutOfOrderReadings(t *testing.T) {
+	publisher := &MockPublisher{}
+	classifier := NewOrderClassifier(publisher)
+	now := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	reading := func(sensorID, parameter string, timestamp time.Time, lateness string) *models.AirQualityData {
+		return &models.AirQualityData{SensorID: sensorID, Parameter: parameter, Timestamp: timestamp, Lateness: lateness}
+	}
+	readings := []*models.AirQualityData{
+		reading("station-7", "PM2.5", now, models.LatenessOnTime),
+		reading("station-7", "PM2.5", now.Add(-time.Minute), models.LatenessOnTime),
+		reading("station-7", "PM10", now.Add(-time.Minute), models.LatenessOnTime),
+		reading("station-8", "PM2.5", now.Add(-time.Minute), models.LatenessOnTime),
+		reading("", "PM2.5", now.Add(-time.Hour), models.LatenessOnTime),
+		reading("station-7", "PM2.5", now.Add(-25*time.Hour), models.LatenessLate),
+		reading("station-7", "PM2.5", now, models.LatenessOnTime),
+	}
+	expected := []string{
+		models.LatenessOnTime,
+		models.LatenessOutOfOrder,
+		models.LatenessOnTime,
+		models.LatenessOnTime,
+		models.LatenessOnTime,
+		models.LatenessLate,
+		models.LatenessOnTime,
+	}
+
+	if err := classifier.ProduceAirQualityData(context.Background(), readings[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := classifier.ProduceAirQualityDataBatch(context.Background(), readings[1:]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i, r := range readings {
+		if r.Lateness != expected[i] {
+			t.Errorf("Expected reading %d to be %s, got %s", i, expected[i], r.Lateness)
+		}
+	}
+	if publisher.count() != len(readings) {
+		t.Errorf("Expected %d published readings, got %d", len(readings), publisher.count())
+	}
+}
+
+// failingPublisher fails every publish
+type failingPublisher struct{}
+
+func (failingPublisher) ProduceAirQualityData(ctx context.Context, data *models.AirQualityData) error {
+	return errors.New("kafka unavailable")
+}
+
+func (failingPublisher) ProduceAirQualityDataBatch(ctx context.Context, data []*models.AirQualityData) error {
+	return errors.New("kafka unavailable")
+}
+
+func TestOrderClassifierIgnoresUnpublishedReadings(t *testing.T) {
+	classifier := NewOrderClassifier(failingPublisher{})
+	now := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	failed := &models.AirQualityData{SensorID: "station-7", Parameter: "PM2.5", Timestamp: now, Lateness: models.LatenessOnTime}
+	if err := classifier.ProduceAirQualityData(context.Background(), failed); err == nil {
+		t.Fatalf("Expected the publish to fail")
+	}
+	failedBatch := []*models.AirQualityData{
+		{SensorID: "station-7", Parameter: "NO2", Timestamp: now, Lateness: models.LatenessOnTime},
+	}
+	if err := classifier.ProduceAirQualityDataBatch(context.Background(), failedBatch); err == nil {
+		t.Fatalf("Expected the batch publish to fail")
+	}
+
+	// Readings older than the unpublished ones are still on time
+	publisher := &MockPublisher{}
+	classifier.next = publisher
+	older := []*models.AirQualityData{
+		{SensorID: "station-7", Parameter: "PM2.5", Timestamp: now.Add(-time.Minute), Lateness: models.LatenessOnTime},
+		{SensorID: "station-7", Parameter: "NO2", Timestamp: now.Add(-time.Minute), Lateness: models.LatenessOnTime},
+	}
+	if err := classifier.ProduceAirQualityDataBatch(context.Background(), older); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, r := range older {
+		if r.Lateness != models.LatenessOnTime {
+			t.Errorf("Expected reading %d to be %s, got %s", i, models.LatenessOnTime, r.Lateness)
+		}
+	}
+}
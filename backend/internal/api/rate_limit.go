@@ -0,0 +1,141 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/ratelimit"
+)
+
+// rateLimitContextKey is the gin context key of the bucket a request was admitted to
+const rateLimitContextKey = "rate_limit"
+
+// readingQuota is the bucket a request was admitted to, so that handlers charge the readings it carries
+type readingQuota struct {
+	limiter *ratelimit.Limiter
+	key     string
+	class   string
+	// covered is the number of readings paid for by the token of the request itself
+	covered int
+}
+
+// RateLimit returns a middleware that throttles clients with a token bucket per sensor, or per
+// client IP for anonymous requests. It must run after SensorAuth. A request takes one token,
+// which pays for its first reading; handlers of requests with several readings take a token for
+// each of the others with allowReadings. Throttled requests get 429 with a Retry-After header.
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, class := rateLimitKey(sensorFromContext(c), c.ClientIP())
+
+		allowed, retryAfter := limiter.Allow(key, class)
+		if !allowed {
+			respondRateLimited(c, retryAfter)
+			return
+		}
+
+		c.Set(rateLimitContextKey, &readingQuota{limiter: limiter, key: key, class: class, covered: 1})
+		c.Next()
+	}
+}
+
+// allowReadings takes a token for each of n readings of a request that the request has not paid
+// for yet. Without a rate limit it always allows. When throttled, it returns how long to wait.
+func allowReadings(c *gin.Context, n int) (bool, time.Duration) {
+	value, ok := c.Get(rateLimitContextKey)
+	if !ok {
+		return true, 0
+	}
+	quota := value.(*readingQuota)
+
+	free := n
+	if free > quota.covered {
+		free = quota.covered
+	}
+	quota.covered -= free
+	return quota.limiter.AllowN(quota.key, quota.class, n-free)
+}
+
+// respondRateLimited aborts a throttled request with 429 and a Retry-After header
+func respondRateLimited(c *gin.Context, retryAfter time.Duration) {
+	seconds := retryAfterSeconds(retryAfter)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Rate limit exceeded",
+		"retry_after": seconds,
+	})
+}
+
+// rateLimitKey returns the bucket key and class of a sensor, or of an anonymous client IP
+func rateLimitKey(sensor *models.Sensor, clientIP string) (string, string) {
+	if sensor != nil {
+		class := sensor.Class
+		if class == "" {
+			class = ratelimit.DefaultClass
+		}
+		return "sensor:" + sensor.ID, class
+	}
+	return "ip:" + clientIP, ratelimit.AnonymousClass
+}
+
+// retryAfterSeconds rounds a wait time up to whole seconds for the Retry-After header
+func retryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// QuotaHandler handles the rate limit status endpoint
+type QuotaHandler struct {
+	limiter *ratelimit.Limiter
+}
+
+// QuotaResponse represents the configured limits and the buckets of recently seen clients
+type QuotaResponse struct {
+	Limits  map[string]ratelimit.Limit `json:"limits"`
+	Clients []ratelimit.Status         `json:"clients"`
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(limiter *ratelimit.Limiter) *QuotaHandler {
+	return &QuotaHandler{
+		limiter: limiter,
+	}
+}
+
+// GetQuotas godoc
+// @Summary Get rate limit quotas
+// @Description List the rate limits per class and the token buckets of recently seen sensors and client IPs, most throttled first
+// @Tags sensors
+// @Produce json
+// @Param throttled query bool false "Only list clients that have been throttled"
+// @Success 200 {object} QuotaResponse
+// @Router /api/admin/quotas [get]
+func (h *QuotaHandler) GetQuotas(c *gin.Context) {
+	clients := h.limiter.Status()
+	if c.Query("throttled") == "true" {
+		throttled := clients[:0]
+		for _, client := range clients {
+			if client.Throttled > 0 {
+				throttled = append(throttled, client)
+			}
+		}
+		clients = throttled
+	}
+
+	c.JSON(http.StatusOK, QuotaResponse{
+		Limits:  h.limiter.Limits(),
+		Clients: clients,
+	})
+}
+
+// RegisterRoutes registers the quota routes to the given router
+func (h *QuotaHandler) RegisterRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	group := router.Group("/api/admin/quotas", middleware...)
+	group.GET("", h.GetQuotas)
+}
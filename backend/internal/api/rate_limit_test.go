@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := NewMockSensorStore()
+	defaultKey, _ := GenerateAPIKey()
+	referenceKey, _ := GenerateAPIKey()
+	store.CreateSensor(models.NewSensor("station-7", "Station 7", HashAPIKey(defaultKey)))
+	reference := models.NewSensor("reference-1", "Reference 1", HashAPIKey(referenceKey))
+	reference.Class = "reference"
+	store.CreateSensor(reference)
+
+	limiter := ratelimit.New(map[string]ratelimit.Limit{
+		ratelimit.DefaultClass:   {Rate: 1, Burst: 2},
+		"reference":              {Rate: 1, Burst: 5},
+		ratelimit.AnonymousClass: {Rate: 1, Burst: 1},
+	})
+
+	router := gin.New()
+	router.POST("/api/data", SensorAuth(store, false), RateLimit(limiter), func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+	NewQuotaHandler(limiter).RegisterRoutes(router)
+
+	send := func(apiKey, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/data", nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		requests int
+		accepted int
+	}{
+		{"Default Class", defaultKey, 5, 2},
+		{"Reference Class", referenceKey, 6, 5},
+		{"Anonymous By IP", "", 3, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			accepted := 0
+			var last *httptest.ResponseRecorder
+			for i := 0; i < tc.requests; i++ {
+				last = send(tc.apiKey, "192.0.2.1:1234")
+				if last.Code == http.StatusAccepted {
+					accepted++
+				}
+			}
+
+			if accepted != tc.accepted {
+				t.Errorf("Expected %d accepted requests, got %d", tc.accepted, accepted)
+			}
+			if last.Code != http.StatusTooManyRequests {
+				t.Errorf("Expected status 429, got %d", last.Code)
+			}
+			if last.Header().Get("Retry-After") != "1" {
+				t.Errorf("Expected Retry-After 1, got %q", last.Header().Get("Retry-After"))
+			}
+		})
+	}
+
+	// Anonymous clients are keyed by IP
+	if w := send("", "192.0.2.2:1234"); w.Code != http.StatusAccepted {
+		t.Errorf("Expected another IP to be accepted, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/quotas?throttled=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response QuotaResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode quotas: %v", err)
+	}
+	if len(response.Limits) != 3 {
+		t.Errorf("Expected 3 limits, got %d", len(response.Limits))
+	}
+	if len(response.Clients) != 3 {
+		t.Fatalf("Expected 3 throttled clients, got %d", len(response.Clients))
+	}
+	if response.Clients[0].Key != "sensor:station-7" || response.Clients[0].Throttled != 3 {
+		t.Errorf("Expected station-7 to be the most throttled, got %+v", response.Clients[0])
+	}
+}
+
+func TestRateLimitChargesEachReadingOfABatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := ratelimit.New(map[string]ratelimit.Limit{ratelimit.AnonymousClass: {Rate: 0.001, Burst: 5}})
+	router := gin.New()
+	NewIngestHandler(&MockPublisher{}).RegisterRoutes(router, SensorAuth(NewMockSensorStore(), false), RateLimit(limiter))
+
+	send := func(minute int) *httptest.ResponseRecorder {
+		var items []string
+		for i := 0; i < 4; i++ {
+			items = append(items, fmt.Sprintf(`{"latitude": 41.015, "longitude": 28.979, "parameter": "PM2.5", "value": 25.0, "timestamp": "2025-05-02T13:%02d:%02dZ"}`, minute, i))
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/data/batch", strings.NewReader("["+strings.Join(items, ",")+"]"))
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The first batch takes 4 of the 5 tokens, so the second does not fit although it is only the second request
+	if w := send(1); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected the first batch to be accepted, got %d %s", w.Code, w.Body.String())
+	}
+	w := send(2)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second batch to be throttled, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a Retry-After header")
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/db"
+	"github.com/user/airpollution/internal/models"
+)
+
+const (
+	// APIKeyHeader is the header sensors use to send their API key
+	APIKeyHeader = "X-API-Key"
+	// apiKeyPrefix makes sensor API keys recognizable in logs and secret scanners
+	apiKeyPrefix = "aqk_"
+	// sensorContextKey is the gin context key of the authenticated sensor
+	sensorContextKey = "sensor"
+)
+
+// SensorStore is the persistence used by the sensor registry
+type SensorStore interface {
+	CreateSensor(sensor *models.Sensor) error
+	GetSensor(id string) (*models.Sensor, error)
+	GetSensorByAPIKeyHash(apiKeyHash string) (*models.Sensor, error)
+	ListSensors() ([]models.Sensor, error)
+	UpdateSensorAPIKey(id, apiKeyHash string) error
+	UpdateSensorSigningSecret(id, secret string, requireSignature bool) error
+	SetSensorEnabled(id string, enabled bool) error
+	SetSensorClass(id, class string) error
+}
+
+// GenerateAPIKey creates a new random sensor API key
+func GenerateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// GenerateSigningSecret creates a new random HMAC signing secret
+func GenerateSigningSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate signing secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashAPIKey returns the hash under which an API key is stored
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// SensorAuth returns a middleware that authenticates sensors by API key.
+// When required is false, requests without a key are let through anonymously,
+// but a key that is present must still be valid.
+func SensorAuth(store SensorStore, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := requestToken(c, APIKeyHeader)
+		if apiKey == "" {
+			if required {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": "Missing API key",
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		sensor, err := store.GetSensorByAPIKeyHash(HashAPIKey(apiKey))
+		if errors.Is(err, db.ErrSensorNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid API key",
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to authenticate sensor: " + err.Error(),
+			})
+			return
+		}
+
+		if !sensor.Enabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Sensor is disabled",
+			})
+			return
+		}
+
+		c.Set(sensorContextKey, sensor)
+		c.Next()
+	}
+}
+
+// AdminAuth returns a middleware that checks the admin token
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := requestToken(c, "X-Admin-Token")
+		if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid admin token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requestToken reads a token from the given header or from a bearer Authorization header
+func requestToken(c *gin.Context, header string) string {
+	if token := strings.TrimSpace(c.GetHeader(header)); token != "" {
+		return token
+	}
+
+	authorization := c.GetHeader("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+
+	return ""
+}
+
+// sensorFromContext returns the authenticated sensor, or nil for anonymous requests
+func sensorFromContext(c *gin.Context) *models.Sensor {
+	if value, ok := c.Get(sensorContextKey); ok {
+		if sensor, ok := value.(*models.Sensor); ok {
+			return sensor
+		}
+	}
+	return nil
+}
+
+// sensorIDFromContext returns the ID of the authenticated sensor, or an empty string for anonymous requests
+func sensorIDFromContext(c *gin.Context) string {
+	if sensor := sensorFromContext(c); sensor != nil {
+		return sensor.ID
+	}
+	return ""
+}
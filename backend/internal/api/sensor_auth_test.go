@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/db"
+	"github.com/user/airpollution/internal/models"
+)
+
+// MockSensorStore implements an in-memory sensor store for testing
+type MockSensorStore struct {
+	sensors map[string]*models.Sensor
+}
+
+// NewMockSensorStore creates a new mock sensor store
+func NewMockSensorStore() *MockSensorStore {
+	return &MockSensorStore{
+		sensors: make(map[string]*models.Sensor),
+	}
+}
+
+func (m *MockSensorStore) CreateSensor(sensor *models.Sensor) error {
+	m.sensors[sensor.ID] = sensor
+	return nil
+}
+
+func (m *MockSensorStore) GetSensor(id string) (*models.Sensor, error) {
+	if sensor, ok := m.sensors[id]; ok {
+		return sensor, nil
+	}
+	return nil, db.ErrSensorNotFound
+}
+
+func (m *MockSensorStore) GetSensorByAPIKeyHash(apiKeyHash string) (*models.Sensor, error) {
+	for _, sensor := range m.sensors {
+		if sensor.APIKeyHash == apiKeyHash {
+			return sensor, nil
+		}
+	}
+	return nil, db.ErrSensorNotFound
+}
+
+func (m *MockSensorStore) ListSensors() ([]models.Sensor, error) {
+	results := make([]models.Sensor, 0, len(m.sensors))
+	for _, sensor := range m.sensors {
+		results = append(results, *sensor)
+	}
+	return results, nil
+}
+
+func (m *MockSensorStore) UpdateSensorAPIKey(id, apiKeyHash string) error {
+	sensor, ok := m.sensors[id]
+	if !ok {
+		return db.ErrSensorNotFound
+	}
+	sensor.APIKeyHash = apiKeyHash
+	return nil
+}
+
+func (m *MockSensorStore) UpdateSensorSigningSecret(id, secret string, requireSignature bool) error {
+	sensor, ok := m.sensors[id]
+	if !ok {
+		return db.ErrSensorNotFound
+	}
+	sensor.SigningSecret = secret
+	sensor.RequireSignature = requireSignature
+	return nil
+}
+
+func (m *MockSensorStore) SetSensorEnabled(id string, enabled bool) error {
+	sensor, ok := m.sensors[id]
+	if !ok {
+		return db.ErrSensorNotFound
+	}
+	sensor.Enabled = enabled
+	return nil
+}
+
+func (m *MockSensorStore) SetSensorClass(id, class string) error {
+	sensor, ok := m.sensors[id]
+	if !ok {
+		return db.ErrSensorNotFound
+	}
+	sensor.Class = class
+	return nil
+}
+
+func TestSensorAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := NewMockSensorStore()
+	activeKey, _ := GenerateAPIKey()
+	disabledKey, _ := GenerateAPIKey()
+	store.CreateSensor(models.NewSensor("station-7", "Station 7", HashAPIKey(activeKey)))
+	disabled := models.NewSensor("station-8", "Station 8", HashAPIKey(disabledKey))
+	disabled.Enabled = false
+	store.CreateSensor(disabled)
+
+	newRouter := func(required bool) *gin.Engine {
+		router := gin.New()
+		router.POST("/api/data", SensorAuth(store, required), func(c *gin.Context) {
+			c.String(http.StatusAccepted, sensorIDFromContext(c))
+		})
+		return router
+	}
+
+	tests := []struct {
+		name           string
+		required       bool
+		header         string
+		value          string
+		expectedStatus int
+		expectedSensor string
+	}{
+		{"Valid Key", true, APIKeyHeader, activeKey, http.StatusAccepted, "station-7"},
+		{"Valid Bearer Token", true, "Authorization", "Bearer " + activeKey, http.StatusAccepted, "station-7"},
+		{"Missing Key", true, "", "", http.StatusUnauthorized, ""},
+		{"Invalid Key", true, APIKeyHeader, "aqk_invalid", http.StatusUnauthorized, ""},
+		{"Disabled Sensor", true, APIKeyHeader, disabledKey, http.StatusForbidden, ""},
+		{"Anonymous Allowed", false, "", "", http.StatusAccepted, ""},
+		{"Invalid Key When Optional", false, APIKeyHeader, "aqk_invalid", http.StatusUnauthorized, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/data", nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			w := httptest.NewRecorder()
+			newRouter(tc.required).ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if tc.expectedStatus == http.StatusAccepted && w.Body.String() != tc.expectedSensor {
+				t.Errorf("Expected sensor %q, got %q", tc.expectedSensor, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/user/airpollution/internal/db"
+	"github.com/user/airpollution/internal/models"
+)
+
+// maxSensorCacheSize is the most lookups a sensor cache holds per key. When it is full, expired
+// lookups are dropped, and all lookups if none has expired, so unknown keys cannot grow it unbounded.
+const maxSensorCacheSize = 10000
+
+// SensorCache is a SensorStore that remembers sensor lookups for a short time, so that sensors are
+// not looked up in the database on every request. Unknown sensors and API keys are remembered too.
+// Writes through the cache forget all lookups, so changes made through it apply right away, while
+// changes made by other instances apply once the lookups expire.
+type SensorCache struct {
+	SensorStore
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	byID      map[string]cachedSensor
+	byKeyHash map[string]cachedSensor
+	// generation counts resets, so that a lookup that raced with a write is not remembered
+	generation uint64
+}
+
+// cachedSensor is a remembered lookup; sensor is nil for an unknown sensor
+type cachedSensor struct {
+	sensor  *models.Sensor
+	expires time.Time
+}
+
+// NewSensorCache creates a cache that remembers the lookups of store for ttl
+func NewSensorCache(store SensorStore, ttl time.Duration) *SensorCache {
+	return &SensorCache{
+		SensorStore: store,
+		ttl:         ttl,
+		now:         time.Now,
+		byID:        make(map[string]cachedSensor),
+		byKeyHash:   make(map[string]cachedSensor),
+	}
+}
+
+// GetSensor gets a sensor by ID
+func (c *SensorCache) GetSensor(id string) (*models.Sensor, error) {
+	return c.lookup(c.byID, id, c.SensorStore.GetSensor)
+}
+
+// GetSensorByAPIKeyHash gets the sensor that owns the given API key hash
+func (c *SensorCache) GetSensorByAPIKeyHash(apiKeyHash string) (*models.Sensor, error) {
+	return c.lookup(c.byKeyHash, apiKeyHash, c.SensorStore.GetSensorByAPIKeyHash)
+}
+
+// CreateSensor creates a sensor and forgets the cached lookups
+func (c *SensorCache) CreateSensor(sensor *models.Sensor) error {
+	defer c.reset()
+	return c.SensorStore.CreateSensor(sensor)
+}
+
+// UpdateSensorAPIKey replaces the API key of a sensor and forgets the cached lookups
+func (c *SensorCache) UpdateSensorAPIKey(id, apiKeyHash string) error {
+	defer c.reset()
+	return c.SensorStore.UpdateSensorAPIKey(id, apiKeyHash)
+}
+
+// UpdateSensorSigningSecret replaces the signing secret of a sensor and forgets the cached lookups
+func (c *SensorCache) UpdateSensorSigningSecret(id, secret string, requireSignature bool) error {
+	defer c.reset()
+	return c.SensorStore.UpdateSensorSigningSecret(id, secret, requireSignature)
+}
+
+// SetSensorEnabled enables or disables a sensor and forgets the cached lookups
+func (c *SensorCache) SetSensorEnabled(id string, enabled bool) error {
+	defer c.reset()
+	return c.SensorStore.SetSensorEnabled(id, enabled)
+}
+
+// SetSensorClass changes the class of a sensor and forgets the cached lookups
+func (c *SensorCache) SetSensorClass(id, class string) error {
+	defer c.reset()
+	return c.SensorStore.SetSensorClass(id, class)
+}
+
+// lookup returns the remembered lookup of key, or looks it up with get and remembers the result.
+// Errors other than an unknown sensor, and lookups that raced with a write, are not remembered.
+func (c *SensorCache) lookup(cache map[string]cachedSensor, key string, get func(string) (*models.Sensor, error)) (*models.Sensor, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := cache[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return copySensor(entry.sensor)
+	}
+
+	sensor, err := get(key)
+	if err != nil && !errors.Is(err, db.ErrSensorNotFound) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return copySensor(sensor)
+	}
+	if len(cache) >= maxSensorCacheSize {
+		for k, e := range cache {
+			if !now.Before(e.expires) {
+				delete(cache, k)
+			}
+		}
+		if len(cache) >= maxSensorCacheSize {
+			clear(cache)
+		}
+	}
+	cache[key] = cachedSensor{sensor: sensor, expires: now.Add(c.ttl)}
+
+	return copySensor(sensor)
+}
+
+// reset forgets all cached lookups
+func (c *SensorCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.byID)
+	clear(c.byKeyHash)
+	c.generation++
+}
+
+// copySensor returns a copy of a cached sensor, so that callers cannot change the cache,
+// or db.ErrSensorNotFound for an unknown sensor
+func copySensor(sensor *models.Sensor) (*models.Sensor, error) {
+	if sensor == nil {
+		return nil, db.ErrSensorNotFound
+	}
+	copied := *sensor
+	return &copied, nil
+}
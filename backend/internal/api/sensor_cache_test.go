@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/db"
+	"github.com/user/airpollution/internal/models"
+)
+
+// countingSensorStore counts the lookups that reach the store
+type countingSensorStore struct {
+	*MockSensorStore
+	lookups int
+}
+
+func (s *countingSensorStore) GetSensor(id string) (*models.Sensor, error) {
+	s.lookups++
+	return s.MockSensorStore.GetSensor(id)
+}
+
+func (s *countingSensorStore) GetSensorByAPIKeyHash(apiKeyHash string) (*models.Sensor, error) {
+	s.lookups++
+	return s.MockSensorStore.GetSensorByAPIKeyHash(apiKeyHash)
+}
+
+func TestSensorCache(t *testing.T) {
+	store := &countingSensorStore{MockSensorStore: NewMockSensorStore()}
+	store.CreateSensor(models.NewSensor("station-7", "Station 7", HashAPIKey("aqk_station7")))
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewSensorCache(store, 30*time.Second)
+	cache.now = func() time.Time { return now }
+
+	// Repeated lookups are answered from the cache
+	for i := 0; i < 3; i++ {
+		sensor, err := cache.GetSensorByAPIKeyHash(HashAPIKey("aqk_station7"))
+		if err != nil || sensor.ID != "station-7" {
+			t.Fatalf("Expected sensor station-7, got %v, %v", sensor, err)
+		}
+		sensor.Enabled = false
+	}
+	if store.lookups != 1 {
+		t.Errorf("Expected 1 lookup, got %d", store.lookups)
+	}
+
+	// Callers get copies of the cached sensor
+	if sensor, _ := cache.GetSensorByAPIKeyHash(HashAPIKey("aqk_station7")); !sensor.Enabled {
+		t.Errorf("Expected the cached sensor to be unchanged by callers")
+	}
+
+	// Unknown keys are remembered too
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetSensorByAPIKeyHash(HashAPIKey("aqk_unknown")); !errors.Is(err, db.ErrSensorNotFound) {
+			t.Errorf("Expected ErrSensorNotFound, got %v", err)
+		}
+	}
+	if store.lookups != 2 {
+		t.Errorf("Expected 2 lookups, got %d", store.lookups)
+	}
+
+	// Lookups expire after the ttl
+	now = now.Add(31 * time.Second)
+	cache.GetSensorByAPIKeyHash(HashAPIKey("aqk_station7"))
+	if store.lookups != 3 {
+		t.Errorf("Expected 3 lookups after expiry, got %d", store.lookups)
+	}
+
+	// Writes through the cache apply right away
+	if err := cache.SetSensorEnabled("station-7", false); err != nil {
+		t.Fatalf("Failed to disable sensor: %v", err)
+	}
+	if sensor, _ := cache.GetSensorByAPIKeyHash(HashAPIKey("aqk_station7")); sensor.Enabled {
+		t.Errorf("Expected the disabled sensor after the write")
+	}
+	if err := cache.CreateSensor(models.NewSensor("station-8", "Station 8", HashAPIKey("aqk_unknown"))); err != nil {
+		t.Fatalf("Failed to create sensor: %v", err)
+	}
+	if sensor, err := cache.GetSensorByAPIKeyHash(HashAPIKey("aqk_unknown")); err != nil || sensor.ID != "station-8" {
+		t.Errorf("Expected the created sensor station-8, got %v, %v", sensor, err)
+	}
+}
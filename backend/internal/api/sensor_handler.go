@@ -0,0 +1,392 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/user/airpollution/internal/db"
+	"github.com/user/airpollution/internal/models"
+)
+
+// SensorHandler handles the sensor registry admin endpoints
+type SensorHandler struct {
+	store SensorStore
+}
+
+// NewSensorHandler creates a new sensor handler
+func NewSensorHandler(store SensorStore) *SensorHandler {
+	return &SensorHandler{
+		store: store,
+	}
+}
+
+// CreateSensorRequest represents the request body for registering a sensor
+type CreateSensorRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name" binding:"required"`
+	// RequireSignature issues a signing secret and rejects unsigned submissions
+	RequireSignature bool `json:"require_signature"`
+	// Class selects the rate limit of the sensor. Defaults to "default".
+	Class string `json:"class"`
+}
+
+// SetSensorClassRequest represents the request body for changing the rate limit class of a sensor
+type SetSensorClassRequest struct {
+	Class string `json:"class" binding:"required"`
+}
+
+// RotateSigningSecretRequest represents the request body for issuing a signing secret
+type RotateSigningSecretRequest struct {
+	RequireSignature bool `json:"require_signature"`
+}
+
+// SensorKeyResponse represents a sensor together with its newly issued credentials.
+// Credentials are only returned once and cannot be retrieved later.
+type SensorKeyResponse struct {
+	Sensor        *models.Sensor `json:"sensor"`
+	APIKey        string         `json:"api_key,omitempty"`
+	SigningSecret string         `json:"signing_secret,omitempty"`
+}
+
+// CreateSensor godoc
+// @Summary Register a sensor
+// @Description Register a new sensor and issue its API key
+// @Tags sensors
+// @Accept json
+// @Produce json
+// @Param sensor body CreateSensorRequest true "Sensor"
+// @Success 201 {object} SensorKeyResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/sensors [post]
+func (h *SensorHandler) CreateSensor(c *gin.Context) {
+	var req CreateSensorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	id := strings.TrimSpace(req.ID)
+	if id == "" {
+		id = uuid.New().String()
+	}
+	if strings.ContainsAny(id, "/+#") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Sensor ID must not contain /, + or #",
+		})
+		return
+	}
+
+	class := strings.TrimSpace(req.Class)
+	if class == "" {
+		class = models.DefaultSensorClass
+	}
+	if err := validateSensorClass(class); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if _, err := h.store.GetSensor(id); err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Sensor already exists",
+		})
+		return
+	} else if !errors.Is(err, db.ErrSensorNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create sensor: " + err.Error(),
+		})
+		return
+	}
+
+	apiKey, err := GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	sensor := models.NewSensor(id, req.Name, HashAPIKey(apiKey))
+	sensor.Class = class
+	if req.RequireSignature {
+		secret, err := GenerateSigningSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		sensor.SigningSecret = secret
+		sensor.RequireSignature = true
+	}
+
+	if err := h.store.CreateSensor(sensor); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create sensor: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, SensorKeyResponse{
+		Sensor:        sensor,
+		APIKey:        apiKey,
+		SigningSecret: sensor.SigningSecret,
+	})
+}
+
+// ListSensors godoc
+// @Summary List sensors
+// @Tags sensors
+// @Produce json
+// @Success 200 {array} models.Sensor
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/sensors [get]
+func (h *SensorHandler) ListSensors(c *gin.Context) {
+	sensors, err := h.store.ListSensors()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list sensors: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, sensors)
+}
+
+// GetSensor godoc
+// @Summary Get a sensor
+// @Tags sensors
+// @Produce json
+// @Param id path string true "Sensor ID"
+// @Success 200 {object} models.Sensor
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/sensors/{id} [get]
+func (h *SensorHandler) GetSensor(c *gin.Context) {
+	sensor, err := h.store.GetSensor(c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sensor)
+}
+
+// RotateSensorKey godoc
+// @Summary Rotate a sensor API key
+// @Description Issue a new API key for a sensor. The previous key stops working immediately.
+// @Tags sensors
+// @Produce json
+// @Param id path string true "Sensor ID"
+// @Success 200 {object} SensorKeyResponse
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/sensors/{id}/rotate-key [post]
+func (h *SensorHandler) RotateSensorKey(c *gin.Context) {
+	apiKey, err := GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.store.UpdateSensorAPIKey(id, HashAPIKey(apiKey)); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	sensor, err := h.store.GetSensor(id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SensorKeyResponse{
+		Sensor: sensor,
+		APIKey: apiKey,
+	})
+}
+
+// RotateSigningSecret godoc
+// @Summary Rotate a sensor signing secret
+// @Description Issue a new HMAC signing secret for a sensor. The previous secret stops working immediately.
+// @Tags sensors
+// @Accept json
+// @Produce json
+// @Param id path string true "Sensor ID"
+// @Param options body RotateSigningSecretRequest false "Signing options"
+// @Success 200 {object} SensorKeyResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/sensors/{id}/rotate-secret [post]
+func (h *SensorHandler) RotateSigningSecret(c *gin.Context) {
+	var req RotateSigningSecretRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	secret, err := GenerateSigningSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.store.UpdateSensorSigningSecret(id, secret, req.RequireSignature); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	sensor, err := h.store.GetSensor(id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SensorKeyResponse{
+		Sensor:        sensor,
+		SigningSecret: secret,
+	})
+}
+
+// DisableSensor godoc
+// @Summary Disable a sensor
+// @Description Reject all further readings from a sensor
+// @Tags sensors
+// @Produce json
+// @Param id path string true "Sensor ID"
+// @Success 200 {object} models.Sensor
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/sensors/{id}/disable [post]
+func (h *SensorHandler) DisableSensor(c *gin.Context) {
+	h.setEnabled(c, false)
+}
+
+// EnableSensor godoc
+// @Summary Enable a sensor
+// @Tags sensors
+// @Produce json
+// @Param id path string true "Sensor ID"
+// @Success 200 {object} models.Sensor
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/sensors/{id}/enable [post]
+func (h *SensorHandler) EnableSensor(c *gin.Context) {
+	h.setEnabled(c, true)
+}
+
+// SetSensorClass godoc
+// @Summary Change a sensor class
+// @Description Change the class that selects the rate limit of a sensor
+// @Tags sensors
+// @Accept json
+// @Produce json
+// @Param id path string true "Sensor ID"
+// @Param class body SetSensorClassRequest true "Class"
+// @Success 200 {object} models.Sensor
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/sensors/{id}/class [post]
+func (h *SensorHandler) SetSensorClass(c *gin.Context) {
+	var req SetSensorClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	class := strings.TrimSpace(req.Class)
+	if err := validateSensorClass(class); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.store.SetSensorClass(id, class); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	sensor, err := h.store.GetSensor(id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sensor)
+}
+
+// validateSensorClass checks that a class name is usable as a rate limit class
+func validateSensorClass(class string) error {
+	if class == "" || len(class) > 64 || strings.ContainsAny(class, ":, ") {
+		return errors.New("Class must be 1 to 64 characters without colons, commas or spaces")
+	}
+	return nil
+}
+
+// setEnabled updates the enabled flag of the sensor in the path
+func (h *SensorHandler) setEnabled(c *gin.Context, enabled bool) {
+	id := c.Param("id")
+	if err := h.store.SetSensorEnabled(id, enabled); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	sensor, err := h.store.GetSensor(id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sensor)
+}
+
+// respondError maps store errors to HTTP responses
+func (h *SensorHandler) respondError(c *gin.Context, err error) {
+	if errors.Is(err, db.ErrSensorNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Sensor not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": err.Error(),
+	})
+}
+
+// RegisterRoutes registers the sensor admin routes to the given router
+func (h *SensorHandler) RegisterRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	group := router.Group("/api/admin/sensors", middleware...)
+	group.POST("", h.CreateSensor)
+	group.GET("", h.ListSensors)
+	group.GET("/:id", h.GetSensor)
+	group.POST("/:id/rotate-key", h.RotateSensorKey)
+	group.POST("/:id/rotate-secret", h.RotateSigningSecret)
+	group.POST("/:id/disable", h.DisableSensor)
+	group.POST("/:id/enable", h.EnableSensor)
+	group.POST("/:id/class", h.SetSensorClass)
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/sensorthings"
+)
+
+// SensorThingsPath is the root of the SensorThings API
+const SensorThingsPath = "/sta/v1.1"
+
+// errEntityNotFound is returned for unknown entity sets and missing entities
+var errEntityNotFound = errors.New("entity not found")
+
+// SensorThingsStore reads the entities exposed by the SensorThings API
+type SensorThingsStore interface {
+	ListThings(id string, q *sensorthings.Query) ([]sensorthings.ThingRecord, int64, error)
+	ListLocations(id string, q *sensorthings.Query) ([]sensorthings.ThingRecord, int64, error)
+	ListDatastreams(thingID, id string, q *sensorthings.Query) ([]sensorthings.DatastreamRecord, int64, error)
+	ListObservations(datastreamID, id string, q *sensorthings.Query) ([]models.AirQualityData, int64, error)
+}
+
+// SensorThingsHandler serves a read-only OGC SensorThings API over the stored readings
+type SensorThingsHandler struct {
+	store   SensorThingsStore
+	baseURL string
+}
+
+// NewSensorThingsHandler creates a new SensorThings handler
+func NewSensorThingsHandler(store SensorThingsStore) *SensorThingsHandler {
+	return &SensorThingsHandler{
+		store: store,
+	}
+}
+
+// WithBaseURL sets the public URL of the SensorThings root used in links, such as
+// https://example.org/sta/v1.1. By default it is derived from the request.
+func (h *SensorThingsHandler) WithBaseURL(baseURL string) *SensorThingsHandler {
+	h.baseURL = baseURL
+	return h
+}
+
+// RegisterRoutes registers the SensorThings routes to the given router
+func (h *SensorThingsHandler) RegisterRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	group := router.Group(SensorThingsPath, middleware...)
+	group.GET("", h.GetResource)
+	group.GET("/*path", h.GetResource)
+}
+
+// collectionResult is a page of entities before conversion
+type collectionResult struct {
+	value interface{}
+	size  int
+	count int64
+}
+
+// GetResource godoc
+// @Summary SensorThings API
+// @Description Read-only OGC SensorThings API 1.1 with Things, Locations, Datastreams and Observations. Collections support $filter, $top, $skip, $orderby and $count.
+// @Tags sensorthings
+// @Produce json
+// @Param path path string true "Resource path, such as Things('station-7')/Datastreams"
+// @Param $filter query string false "Filter, such as result gt 35 and phenomenonTime ge 2025-05-01T00:00:00Z"
+// @Param $top query int false "Page size, at most 1000" default(100)
+// @Param $skip query int false "Number of entities to skip"
+// @Param $orderby query string false "Sort order, such as phenomenonTime desc"
+// @Param $count query bool false "Include the total number of entities"
+// @Success 200 {object} sensorthings.Collection
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /sta/v1.1/{path} [get]
+func (h *SensorThingsHandler) GetResource(c *gin.Context) {
+	base := h.base(c)
+
+	segments, err := sensorthings.ParsePath(c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(segments) == 0 {
+		c.JSON(http.StatusOK, serviceRoot(base))
+		return
+	}
+
+	q, err := sensorthings.ParseQuery(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	first := segments[0]
+	switch {
+	case len(segments) == 1 && !first.HasID:
+		h.respondCollection(c, base, q, func(q *sensorthings.Query) (collectionResult, error) {
+			return h.list(base, first.Name, "", q)
+		})
+	case len(segments) == 1:
+		h.respondEntity(c, base, first.Name, first.ID)
+	case len(segments) == 2 && first.HasID && !segments[1].HasID:
+		h.respondNavigation(c, base, first, segments[1].Name, q)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported resource path"})
+	}
+}
+
+// list reads a page of an entity set, optionally restricted to one entity
+func (h *SensorThingsHandler) list(base, set, id string, q *sensorthings.Query) (collectionResult, error) {
+	switch set {
+	case sensorthings.Things:
+		records, count, err := h.store.ListThings(id, q)
+		value := make([]sensorthings.Thing, len(records))
+		for i, r := range records {
+			value[i] = sensorthings.NewThing(base, r)
+		}
+		return collectionResult{value: value, size: len(value), count: count}, err
+	case sensorthings.Locations:
+		records, count, err := h.store.ListLocations(id, q)
+		value := make([]sensorthings.Location, len(records))
+		for i, r := range records {
+			value[i] = sensorthings.NewLocation(base, r)
+		}
+		return collectionResult{value: value, size: len(value), count: count}, err
+	case sensorthings.Datastreams:
+		records, count, err := h.store.ListDatastreams("", id, q)
+		value := make([]sensorthings.Datastream, len(records))
+		for i, r := range records {
+			value[i] = sensorthings.NewDatastream(base, r)
+		}
+		return collectionResult{value: value, size: len(value), count: count}, err
+	case sensorthings.Observations:
+		records, count, err := h.store.ListObservations("", id, q)
+		value := make([]sensorthings.Observation, len(records))
+		for i := range records {
+			value[i] = sensorthings.NewObservation(base, &records[i])
+		}
+		return collectionResult{value: value, size: len(value), count: count}, err
+	default:
+		return collectionResult{}, errEntityNotFound
+	}
+}
+
+// respondEntity writes a single entity, or 404 when it does not exist
+func (h *SensorThingsHandler) respondEntity(c *gin.Context, base, set, id string) {
+	result, err := h.list(base, set, id, &sensorthings.Query{Top: 1})
+	if err == nil && result.size == 0 {
+		err = errEntityNotFound
+	}
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// The single entity is the first element of the page
+	switch value := result.value.(type) {
+	case []sensorthings.Thing:
+		c.JSON(http.StatusOK, value[0])
+	case []sensorthings.Location:
+		c.JSON(http.StatusOK, value[0])
+	case []sensorthings.Datastream:
+		c.JSON(http.StatusOK, value[0])
+	case []sensorthings.Observation:
+		c.JSON(http.StatusOK, value[0])
+	}
+}
+
+// respondNavigation writes the entities related to an entity, such as Things('station-7')/Datastreams
+func (h *SensorThingsHandler) respondNavigation(c *gin.Context, base string, parent sensorthings.Segment, property string, q *sensorthings.Query) {
+	switch parent.Name + "/" + property {
+	case "Things/Locations":
+		h.respondCollection(c, base, q, func(q *sensorthings.Query) (collectionResult, error) {
+			return h.list(base, sensorthings.Locations, parent.ID, q)
+		})
+	case "Locations/Things":
+		h.respondCollection(c, base, q, func(q *sensorthings.Query) (collectionResult, error) {
+			return h.list(base, sensorthings.Things, parent.ID, q)
+		})
+	case "Things/Datastreams":
+		h.respondCollection(c, base, q, func(q *sensorthings.Query) (collectionResult, error) {
+			records, count, err := h.store.ListDatastreams(parent.ID, "", q)
+			value := make([]sensorthings.Datastream, len(records))
+			for i, r := range records {
+				value[i] = sensorthings.NewDatastream(base, r)
+			}
+			return collectionResult{value: value, size: len(value), count: count}, err
+		})
+	case "Datastreams/Observations":
+		h.respondCollection(c, base, q, func(q *sensorthings.Query) (collectionResult, error) {
+			records, count, err := h.store.ListObservations(parent.ID, "", q)
+			value := make([]sensorthings.Observation, len(records))
+			for i := range records {
+				value[i] = sensorthings.NewObservation(base, &records[i])
+			}
+			return collectionResult{value: value, size: len(value), count: count}, err
+		})
+	case "Datastreams/Thing":
+		datastreams, _, err := h.store.ListDatastreams("", parent.ID, &sensorthings.Query{Top: 1})
+		if err == nil && len(datastreams) == 0 {
+			err = errEntityNotFound
+		}
+		if err != nil {
+			h.respondError(c, err)
+			return
+		}
+		h.respondEntity(c, base, sensorthings.Things, datastreams[0].ThingID)
+	case "Observations/Datastream":
+		observations, _, err := h.store.ListObservations("", parent.ID, &sensorthings.Query{Top: 1})
+		if err == nil && len(observations) == 0 {
+			err = errEntityNotFound
+		}
+		if err != nil {
+			h.respondError(c, err)
+			return
+		}
+		h.respondEntity(c, base, sensorthings.Datastreams, sensorthings.DatastreamID(observations[0].SensorID, observations[0].Parameter))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported resource path"})
+	}
+}
+
+// respondCollection writes a page of entities. One entity more than requested is read to
+// decide whether there is a next page.
+func (h *SensorThingsHandler) respondCollection(c *gin.Context, base string, q *sensorthings.Query, list func(*sensorthings.Query) (collectionResult, error)) {
+	page := *q
+	if q.Top > 0 {
+		page.Top = q.Top + 1
+	}
+
+	result, err := list(&page)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	collection := sensorthings.Collection{Value: result.value}
+	if q.Count {
+		collection.Count = &result.count
+	}
+	if q.Top > 0 && result.size > q.Top {
+		collection.Value = truncate(result.value, q.Top)
+		collection.NextLink = nextLink(base, c, q)
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// truncate shortens a page of converted entities to n entities
+func truncate(value interface{}, n int) interface{} {
+	switch v := value.(type) {
+	case []sensorthings.Thing:
+		return v[:n]
+	case []sensorthings.Location:
+		return v[:n]
+	case []sensorthings.Datastream:
+		return v[:n]
+	case []sensorthings.Observation:
+		return v[:n]
+	default:
+		return value
+	}
+}
+
+// nextLink returns the URL of the page after the current one
+func nextLink(base string, c *gin.Context, q *sensorthings.Query) string {
+	values := c.Request.URL.Query()
+	values.Set("$top", strconv.Itoa(q.Top))
+	values.Set("$skip", strconv.Itoa(q.Skip+q.Top))
+	return base + c.Param("path") + "?" + values.Encode()
+}
+
+// respondError maps a store error to a response
+func (h *SensorThingsHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errEntityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entity not found"})
+	case errors.Is(err, sensorthings.ErrInvalidQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read entities: " + err.Error()})
+	}
+}
+
+// base returns the public URL of the SensorThings root
+func (h *SensorThingsHandler) base(c *gin.Context) string {
+	if h.baseURL != "" {
+		return h.baseURL
+	}
+
+	scheme := "http"
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	} else if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return (&url.URL{Scheme: scheme, Host: c.Request.Host, Path: SensorThingsPath}).String()
+}
+
+// serviceRoot lists the entity sets and the conformance classes of the API
+func serviceRoot(base string) gin.H {
+	sets := []gin.H{}
+	for _, name := range []string{sensorthings.Things, sensorthings.Locations, sensorthings.Datastreams, sensorthings.Observations} {
+		sets = append(sets, gin.H{"name": name, "url": fmt.Sprintf("%s/%s", base, name)})
+	}
+	return gin.H{
+		"value": sets,
+		"serverSettings": gin.H{
+			"conformance": []string{
+				"http://www.opengis.net/spec/iot_sensing/1.1/req/datamodel",
+				"http://www.opengis.net/spec/iot_sensing/1.1/req/resource-path/resource-path-to-entities",
+				"http://www.opengis.net/spec/iot_sensing/1.1/req/request-data",
+			},
+		},
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/sensorthings"
+)
+
+// MockSensorThingsStore serves fixed entities and records the last query
+type MockSensorThingsStore struct {
+	things       []sensorthings.ThingRecord
+	observations []models.AirQualityData
+	lastQuery    *sensorthings.Query
+}
+
+func (m *MockSensorThingsStore) ListThings(id string, q *sensorthings.Query) ([]sensorthings.ThingRecord, int64, error) {
+	m.lastQuery = q
+	var results []sensorthings.ThingRecord
+	for _, thing := range m.things {
+		if id == "" || thing.ID == id {
+			results = append(results, thing)
+		}
+	}
+	return page(results, q), int64(len(results)), nil
+}
+
+func (m *MockSensorThingsStore) ListLocations(id string, q *sensorthings.Query) ([]sensorthings.ThingRecord, int64, error) {
+	return m.ListThings(id, q)
+}
+
+func (m *MockSensorThingsStore) ListDatastreams(thingID, id string, q *sensorthings.Query) ([]sensorthings.DatastreamRecord, int64, error) {
+	m.lastQuery = q
+	var results []sensorthings.DatastreamRecord
+	for _, data := range m.observations {
+		record := sensorthings.DatastreamRecord{ThingID: data.SensorID, Parameter: data.Parameter, Start: data.Timestamp, End: data.Timestamp}
+		if (thingID == "" || record.ThingID == thingID) && (id == "" || record.ID() == id) {
+			results = append(results, record)
+		}
+	}
+	return page(results, q), int64(len(results)), nil
+}
+
+func (m *MockSensorThingsStore) ListObservations(datastreamID, id string, q *sensorthings.Query) ([]models.AirQualityData, int64, error) {
+	m.lastQuery = q
+	var results []models.AirQualityData
+	for _, data := range m.observations {
+		if (datastreamID == "" || sensorthings.DatastreamID(data.SensorID, data.Parameter) == datastreamID) && (id == "" || data.ID.String() == id) {
+			results = append(results, data)
+		}
+	}
+	return page(results, q), int64(len(results)), nil
+}
+
+// page applies $skip and $top to a slice of records
+func page[T any](records []T, q *sensorthings.Query) []T {
+	if q.Skip >= len(records) {
+		return []T{}
+	}
+	records = records[q.Skip:]
+	if q.Top < len(records) {
+		records = records[:q.Top]
+	}
+	return records
+}
+
+func TestSensorThingsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	timestamp := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+	reading := models.AirQualityData{ID: uuid.New(), SensorID: "station-7", Parameter: "PM2.5", Value: 25.5, Unit: "µg/m³", Timestamp: timestamp}
+	store := &MockSensorThingsStore{
+		things: []sensorthings.ThingRecord{
+			{ID: "station-7", Name: "Station 7", Class: "default", Latitude: 41.015, Longitude: 28.979, LastSeen: timestamp},
+			{ID: "station-8", Name: "Station 8", Latitude: 41.1, Longitude: 29.0, LastSeen: timestamp},
+		},
+		observations: []models.AirQualityData{reading},
+	}
+
+	router := gin.New()
+	NewSensorThingsHandler(store).WithBaseURL("https://example.org/sta/v1.1").RegisterRoutes(router)
+
+	get := func(path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	// Service root
+	if w, body := get("/sta/v1.1"); w.Code != http.StatusOK || len(body["value"].([]interface{})) != 4 {
+		t.Errorf("Expected service root with 4 entity sets, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Paged collection with a next link
+	w, body := get("/sta/v1.1/Things?$top=1&$count=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(body["value"].([]interface{})) != 1 || body["@iot.count"] != 2.0 {
+		t.Errorf("Expected one thing and a count of 2, got %s", w.Body.String())
+	}
+	if body["@iot.nextLink"] != "https://example.org/sta/v1.1/Things?%24count=true&%24skip=1&%24top=1" {
+		t.Errorf("Unexpected next link %v", body["@iot.nextLink"])
+	}
+
+	// Last page has no next link
+	if _, body := get("/sta/v1.1/Things?$top=1&$skip=1"); body["@iot.nextLink"] != nil {
+		t.Errorf("Expected no next link on the last page, got %v", body["@iot.nextLink"])
+	}
+
+	// Single entity
+	w, body = get("/sta/v1.1/Things('station-7')")
+	if w.Code != http.StatusOK || body["@iot.id"] != "station-7" || body["Datastreams@iot.navigationLink"] != "https://example.org/sta/v1.1/Things('station-7')/Datastreams" {
+		t.Errorf("Unexpected thing %s", w.Body.String())
+	}
+
+	// Location as GeoJSON with longitude first
+	w, body = get("/sta/v1.1/Things('station-7')/Locations")
+	location := body["value"].([]interface{})[0].(map[string]interface{})["location"].(map[string]interface{})
+	if coordinates := location["coordinates"].([]interface{}); coordinates[0] != 28.979 || coordinates[1] != 41.015 {
+		t.Errorf("Expected [longitude, latitude], got %v", coordinates)
+	}
+
+	// Navigation from a datastream to its observations and from an observation to its datastream
+	w, body = get("/sta/v1.1/Datastreams('station-7:PM2.5')/Observations?$filter=result%20gt%2020")
+	if w.Code != http.StatusOK || len(body["value"].([]interface{})) != 1 {
+		t.Errorf("Expected one observation, got %d %s", w.Code, w.Body.String())
+	}
+	if store.lastQuery.Filter == nil {
+		t.Errorf("Expected the filter to be passed to the store")
+	}
+
+	w, body = get("/sta/v1.1/Observations('" + reading.ID.String() + "')/Datastream")
+	if w.Code != http.StatusOK || body["@iot.id"] != "station-7:PM2.5" {
+		t.Errorf("Unexpected datastream %s", w.Body.String())
+	}
+	if unit := body["unitOfMeasurement"].(map[string]interface{}); unit["symbol"] != "µg/m³" {
+		t.Errorf("Expected the canonical unit, got %v", unit)
+	}
+
+	// Errors
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/sta/v1.1/Things('missing')", http.StatusNotFound},
+		{"/sta/v1.1/Sensors", http.StatusNotFound},
+		{"/sta/v1.1/Things?$expand=Datastreams", http.StatusBadRequest},
+		{"/sta/v1.1/Things?$filter=name%20eq", http.StatusBadRequest},
+		{"/sta/v1.1/Things('station-7')/Datastreams('x')/Observations", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		if w, _ := get(tc.path); w.Code != tc.status {
+			t.Errorf("Expected status %d for %s, got %d", tc.status, tc.path, w.Code)
+		}
+	}
+}
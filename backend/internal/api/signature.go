@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of "<timestamp>.<body>", optionally prefixed with "sha256="
+	SignatureHeader = "X-Signature"
+	// SignatureTimestampHeader carries the Unix time in seconds at which the request was signed
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	// maxSignedBodySize bounds the body that is buffered to verify a signature
+	maxSignedBodySize = 10 << 20 // 10MB
+	// signatureStatusContextKey is the gin context key of the signature verification outcome
+	signatureStatusContextKey = "signature_status"
+)
+
+// SignRequest computes the signature a sensor sends for a body signed at the given time
+func SignRequest(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureVerification returns a middleware that verifies HMAC-signed submissions.
+// It must run after SensorAuth. Signed requests are checked against the sensor's signing secret
+// and rejected when the timestamp is more than maxSkew away or the signature was already used.
+// Unsigned requests are only rejected when the sensor requires signatures.
+func SignatureVerification(maxSkew time.Duration) gin.HandlerFunc {
+	replays := newExpiringSet(2 * maxSkew)
+
+	return func(c *gin.Context) {
+		sensor := sensorFromContext(c)
+		// Hex decoding ignores case, so the signature is lowercased to key replays on the MAC itself
+		signature := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c.GetHeader(SignatureHeader)), "sha256="))
+		timestampHeader := strings.TrimSpace(c.GetHeader(SignatureTimestampHeader))
+
+		if signature == "" && timestampHeader == "" {
+			if sensor != nil && sensor.RequireSignature {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": "Sensor requires signed submissions",
+				})
+				return
+			}
+			if sensor != nil {
+				c.Set(signatureStatusContextKey, models.SignatureUnsigned)
+			}
+			c.Next()
+			return
+		}
+
+		if sensor == nil || sensor.SigningSecret == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Signed submissions require a sensor with a signing secret",
+			})
+			return
+		}
+
+		body, err := readSignedBody(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if err := verifySignature(sensor.SigningSecret, signature, timestampHeader, body, time.Now(), maxSkew); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if !replays.add(sensor.ID+":"+signature, time.Now()) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Signature has already been used",
+			})
+			return
+		}
+
+		c.Set(signatureStatusContextKey, models.SignatureVerified)
+		c.Next()
+	}
+}
+
+// readSignedBody buffers the request body and restores it for the handler
+func readSignedBody(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) > maxSignedBodySize {
+		return nil, fmt.Errorf("signed request body must not exceed %d bytes", maxSignedBodySize)
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// verifySignature checks the timestamp window and the HMAC of a signed body
+func verifySignature(secret, signature, timestampHeader string, body []byte, now time.Time, maxSkew time.Duration) error {
+	if signature == "" || timestampHeader == "" {
+		return errors.New("Both " + SignatureHeader + " and " + SignatureTimestampHeader + " are required")
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return errors.New("Invalid signature timestamp")
+	}
+
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return errors.New("Signature timestamp is outside the allowed window")
+	}
+
+	expected, _ := hex.DecodeString(SignRequest(secret, timestamp, body))
+	provided, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, provided) {
+		return errors.New("Invalid signature")
+	}
+
+	return nil
+}
+
+// provenance describes who submitted a reading and how the submission was verified
+type provenance struct {
+	SensorID        string
+	SignatureStatus string
+}
+
+// provenanceFromContext collects the provenance recorded by the authentication middleware
+func provenanceFromContext(c *gin.Context) provenance {
+	return provenance{
+		SensorID:        sensorIDFromContext(c),
+		SignatureStatus: c.GetString(signatureStatusContextKey),
+	}
+}
+
+// apply stamps a reading with the provenance
+func (p provenance) apply(data *models.AirQualityData) {
+	data.SensorID = p.SensorID
+	data.SignatureStatus = p.SignatureStatus
+}
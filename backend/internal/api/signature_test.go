@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := "shared-secret"
+	body := []byte(`{"parameter": "PM2.5", "value": 25.0}`)
+	now := time.Unix(1746193500, 0)
+	signature := SignRequest(secret, now.Unix(), body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		expected  bool
+	}{
+		{"Valid", secret, signature, strconv.FormatInt(now.Unix(), 10), body, true},
+		{"Wrong Secret", "other-secret", signature, strconv.FormatInt(now.Unix(), 10), body, false},
+		{"Tampered Body", secret, signature, strconv.FormatInt(now.Unix(), 10), []byte(`{"parameter": "PM2.5", "value": 5.0}`), false},
+		{"Tampered Timestamp", secret, signature, strconv.FormatInt(now.Unix()+1, 10), body, false},
+		{"Outside Window", secret, SignRequest(secret, now.Unix()-600, body), strconv.FormatInt(now.Unix()-600, 10), body, false},
+		{"Missing Timestamp", secret, signature, "", body, false},
+		{"Malformed Signature", secret, "not-hex", strconv.FormatInt(now.Unix(), 10), body, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifySignature(tc.secret, tc.signature, tc.timestamp, tc.body, now, 5*time.Minute)
+			if tc.expected && err != nil {
+				t.Errorf("Expected valid signature but got error: %v", err)
+			}
+			if !tc.expected && err == nil {
+				t.Errorf("Expected signature to be rejected")
+			}
+		})
+	}
+}
+
+func TestSignatureVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := NewMockSensorStore()
+	apiKey, _ := GenerateAPIKey()
+	sensor := models.NewSensor("station-7", "Station 7", HashAPIKey(apiKey))
+	sensor.SigningSecret = "shared-secret"
+	store.CreateSensor(sensor)
+
+	router := gin.New()
+	router.POST("/api/data", SensorAuth(store, true), SignatureVerification(5*time.Minute), func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		c.String(http.StatusAccepted, provenanceFromContext(c).SignatureStatus+"|"+string(body))
+	})
+
+	send := func(body, signature, timestamp string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/data", strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, apiKey)
+		if signature != "" {
+			req.Header.Set(SignatureHeader, signature)
+			req.Header.Set(SignatureTimestampHeader, timestamp)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"value": 25.0}`
+	now := time.Now().Unix()
+	signature := "sha256=" + SignRequest("shared-secret", now, []byte(body))
+
+	// Signed request is verified and the body is still readable by the handler
+	w := send(body, signature, strconv.FormatInt(now, 10))
+	if w.Code != http.StatusAccepted || w.Body.String() != "verified|"+body {
+		t.Errorf("Expected verified request, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Replaying the same signature is rejected
+	if w := send(body, signature, strconv.FormatInt(now, 10)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected replay to be rejected, got %d", w.Code)
+	}
+
+	// Replaying the signature in upper case is rejected too
+	if w := send(body, strings.ToUpper(signature[len("sha256="):]), strconv.FormatInt(now, 10)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected replay in upper case to be rejected, got %d", w.Code)
+	}
+
+	// Unsigned request is accepted and recorded as unsigned
+	if w := send(body, "", ""); w.Code != http.StatusAccepted || !strings.HasPrefix(w.Body.String(), "unsigned|") {
+		t.Errorf("Expected unsigned request to be accepted, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Unsigned request is rejected once the sensor requires signatures
+	sensor.RequireSignature = true
+	if w := send(body, "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned request to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/airpollution/internal/models"
+)
+
+const (
+	// uploadChunkSize is the number of rows buffered before they are published to Kafka
+	uploadChunkSize = 500
+	// maxUploadErrors is the maximum number of row-level errors reported in an upload response
+	maxUploadErrors = 100
+	// maxNDJSONLineSize is the maximum length of a single NDJSON line
+	maxNDJSONLineSize = 1 << 20 // 1MB
+)
+
+// errRateLimited stops an upload whose sender ran out of rate limit tokens
+var errRateLimited = errors.New("rate limit exceeded")
+
+// UploadRowError represents a row that could not be ingested
+type UploadRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// UploadSummary represents the response body for a bulk upload
+type UploadSummary struct {
+	Format          string           `json:"format"`
+	RowsRead        int              `json:"rows_read"`
+	RowsAccepted    int              `json:"rows_accepted"`
+	RowsRejected    int              `json:"rows_rejected"`
+	Errors          []UploadRowError `json:"errors"`
+	ErrorsTruncated bool             `json:"errors_truncated,omitempty"`
+	Error           string           `json:"error,omitempty"`
+}
+
+// CSVColumnMapping maps the fields of an AirQualityDataRequest to CSV header names
+type CSVColumnMapping struct {
+	ID        string
+	Unit      string
+	Latitude  string
+	Longitude string
+	Parameter string
+	Value     string
+	Timestamp string
+	// Optional metadata columns
+	Humidity        string
+	Temperature     string
+	Altitude        string
+	SensorModel     string
+	FirmwareVersion string
+}
+
+// rowReader reads air quality requests one row at a time.
+// Next returns io.EOF when there are no more rows; other errors are row-level and reading may continue.
+type rowReader interface {
+	Next() (*AirQualityDataRequest, int, error)
+}
+
+// PostAirQualityDataUpload godoc
+// @Summary Bulk upload air quality data
+// @Description Stream CSV or newline-delimited JSON readings to Kafka. CSV columns are mapped with the *_column query parameters.
+// @Tags data
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "Upload format (csv or ndjson), defaults to the Content-Type"
+// @Param id_column query string false "Optional CSV column holding a client-supplied reading ID" default(id)
+// @Param latitude_column query string false "CSV column holding the latitude" default(latitude)
+// @Param longitude_column query string false "CSV column holding the longitude" default(longitude)
+// @Param parameter_column query string false "CSV column holding the parameter" default(parameter)
+// @Param value_column query string false "CSV column holding the value" default(value)
+// @Param timestamp_column query string false "CSV column holding the timestamp" default(timestamp)
+// @Param unit_column query string false "Optional CSV column holding the unit" default(unit)
+// @Param parameter query string false "Fixed parameter for CSV files without a parameter column"
+// @Param unit query string false "Fixed unit for CSV files without a unit column"
+// @Param timestamp_format query string false "Go time layout of the CSV timestamps" default(2006-01-02T15:04:05Z07:00)
+// @Param delimiter query string false "CSV field delimiter" default(,)
+// @Param Idempotency-Key header string false "Client key identifying the upload, used to derive stable reading IDs"
+// @Success 202 {object} UploadSummary
+// @Failure 400 {object} UploadSummary
+// @Failure 429 {object} map[string]interface{}
+// @Failure 500 {object} UploadSummary
+// @Router /api/data/upload [post]
+func (h *IngestHandler) PostAirQualityDataUpload(c *gin.Context) {
+	format := uploadFormat(c)
+	summary := UploadSummary{
+		Format: format,
+		Errors: []UploadRowError{},
+	}
+
+	var reader rowReader
+	var err error
+	switch format {
+	case "csv":
+		reader, err = newCSVRowReader(c.Request.Body, csvMappingFromQuery(c), c.Query("parameter"), c.Query("unit"),
+			c.DefaultQuery("timestamp_format", time.RFC3339), c.DefaultQuery("delimiter", ","))
+	case "ndjson":
+		reader = newNDJSONRowReader(c.Request.Body)
+	default:
+		err = fmt.Errorf("unsupported upload format %q, expected csv or ndjson", format)
+	}
+	if err != nil {
+		summary.Error = err.Error()
+		c.JSON(http.StatusBadRequest, summary)
+		return
+	}
+
+	// Publish rows in bounded chunks so memory use does not grow with the upload size
+	// Uploads are not tracked in the in-memory idempotency window because they can be arbitrarily large;
+	// stable reading IDs and the database upsert make re-uploads harmless instead
+	prov := provenanceFromContext(c)
+	idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+	chunk := make([]*models.AirQualityData, 0, uploadChunkSize)
+	var retryAfter time.Duration
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		// Each row counts against the rate limit of the sender; once it is used up the upload stops
+		var allowed bool
+		if allowed, retryAfter = allowReadings(c, len(chunk)); !allowed {
+			return errRateLimited
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+		if err := h.producer.ProduceAirQualityDataBatch(ctx, chunk); err != nil {
+			return err
+		}
+		summary.RowsAccepted += len(chunk)
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for {
+		req, row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			err = validateDecodedRequest(req)
+		}
+
+		summary.RowsRead++
+		if err != nil {
+			summary.RowsRejected++
+			if len(summary.Errors) < maxUploadErrors {
+				summary.Errors = append(summary.Errors, UploadRowError{Row: row, Error: err.Error()})
+			} else {
+				summary.ErrorsTruncated = true
+			}
+			continue
+		}
+
+		rowKey := ""
+		if idempotencyKey != "" {
+			rowKey = fmt.Sprintf("%s:%d", idempotencyKey, row)
+		}
+		data := newReadingFromRequest(req, readingID(req, rowKey, prov.SensorID))
+		prov.apply(data)
+		chunk = append(chunk, data)
+
+		if len(chunk) >= uploadChunkSize {
+			if err := flush(); err != nil {
+				respondUploadError(c, summary, err, retryAfter)
+				return
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		respondUploadError(c, summary, err, retryAfter)
+		return
+	}
+
+	status := http.StatusAccepted
+	if summary.RowsAccepted == 0 {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, summary)
+}
+
+// respondUploadError reports an upload that stopped early, with the rows accepted until then
+func respondUploadError(c *gin.Context, summary UploadSummary, err error, retryAfter time.Duration) {
+	if errors.Is(err, errRateLimited) {
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+		summary.Error = "Rate limit exceeded; upload the remaining rows later"
+		c.JSON(http.StatusTooManyRequests, summary)
+		return
+	}
+	summary.Error = "Failed to publish data: " + err.Error()
+	c.JSON(http.StatusInternalServerError, summary)
+}
+
+// uploadFormat determines the upload format from the format query parameter or the Content-Type
+func uploadFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return "csv"
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/json-seq":
+		return "ndjson"
+	default:
+		return mediaType
+	}
+}
+
+// csvMappingFromQuery reads the CSV column mapping from the query string
+func csvMappingFromQuery(c *gin.Context) CSVColumnMapping {
+	return CSVColumnMapping{
+		ID:        c.DefaultQuery("id_column", "id"),
+		Unit:      c.DefaultQuery("unit_column", "unit"),
+		Latitude:  c.DefaultQuery("latitude_column", "latitude"),
+		Longitude: c.DefaultQuery("longitude_column", "longitude"),
+		Parameter: c.DefaultQuery("parameter_column", "parameter"),
+		Value:     c.DefaultQuery("value_column", "value"),
+		Timestamp: c.DefaultQuery("timestamp_column", "timestamp"),
+
+		Humidity:        c.DefaultQuery("humidity_column", "humidity"),
+		Temperature:     c.DefaultQuery("temperature_column", "temperature"),
+		Altitude:        c.DefaultQuery("altitude_column", "altitude"),
+		SensorModel:     c.DefaultQuery("sensor_model_column", "sensor_model"),
+		FirmwareVersion: c.DefaultQuery("firmware_version_column", "firmware_version"),
+	}
+}
+
+// csvRowReader reads requests from CSV with a header row
+type csvRowReader struct {
+	reader          *csv.Reader
+	index           map[string]int
+	mapping         CSVColumnMapping
+	fixedParameter  string
+	fixedUnit       string
+	timestampLayout string
+	row             int
+	done            bool
+}
+
+// newCSVRowReader creates a CSV row reader and resolves the column mapping against the header row
+func newCSVRowReader(r io.Reader, mapping CSVColumnMapping, fixedParameter, fixedUnit, timestampLayout, delimiter string) (*csvRowReader, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+	if delimiter != "" {
+		if delimiter == `\t` {
+			delimiter = "\t"
+		}
+		runes := []rune(delimiter)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("delimiter must be a single character")
+		}
+		reader.Comma = runes[0]
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+
+	required := []string{mapping.Latitude, mapping.Longitude, mapping.Value, mapping.Timestamp}
+	if fixedParameter == "" {
+		required = append(required, mapping.Parameter)
+	}
+	for _, column := range required {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("CSV header is missing column %q", column)
+		}
+	}
+
+	return &csvRowReader{
+		reader:          reader,
+		index:           index,
+		mapping:         mapping,
+		fixedParameter:  fixedParameter,
+		fixedUnit:       fixedUnit,
+		timestampLayout: timestampLayout,
+		row:             1,
+	}, nil
+}
+
+// Next reads the next CSV record. Rows are numbered from 1 including the header.
+func (r *csvRowReader) Next() (*AirQualityDataRequest, int, error) {
+	if r.done {
+		return nil, r.row, io.EOF
+	}
+
+	record, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, r.row, io.EOF
+	}
+	r.row++
+	if err != nil {
+		var parseErr *csv.ParseError
+		if !errors.As(err, &parseErr) {
+			// The request body failed; report it once and stop reading
+			r.done = true
+			return nil, r.row, fmt.Errorf("failed to read upload: %w", err)
+		}
+		return nil, r.row, fmt.Errorf("malformed CSV row: %w", err)
+	}
+
+	field := func(column string) string {
+		i, ok := r.index[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var req AirQualityDataRequest
+	if req.Latitude, err = strconv.ParseFloat(field(r.mapping.Latitude), 64); err != nil {
+		return nil, r.row, fmt.Errorf("invalid latitude: %w", err)
+	}
+	if req.Longitude, err = strconv.ParseFloat(field(r.mapping.Longitude), 64); err != nil {
+		return nil, r.row, fmt.Errorf("invalid longitude: %w", err)
+	}
+	if req.Value, err = strconv.ParseFloat(field(r.mapping.Value), 64); err != nil {
+		return nil, r.row, fmt.Errorf("invalid value: %w", err)
+	}
+	if req.Timestamp, err = time.Parse(r.timestampLayout, field(r.mapping.Timestamp)); err != nil {
+		return nil, r.row, fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	// The ID and unit columns are optional
+	req.ID = field(r.mapping.ID)
+	req.Unit = r.fixedUnit
+	if req.Unit == "" {
+		req.Unit = field(r.mapping.Unit)
+	}
+
+	req.Parameter = r.fixedParameter
+	if req.Parameter == "" {
+		req.Parameter = field(r.mapping.Parameter)
+	}
+
+	// Metadata columns are optional and may be empty
+	optionalFloat := func(column, name string) (*float64, error) {
+		raw := field(column)
+		if raw == "" {
+			return nil, nil
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return &value, nil
+	}
+	if req.Humidity, err = optionalFloat(r.mapping.Humidity, "humidity"); err != nil {
+		return nil, r.row, err
+	}
+	if req.Temperature, err = optionalFloat(r.mapping.Temperature, "temperature"); err != nil {
+		return nil, r.row, err
+	}
+	if req.Altitude, err = optionalFloat(r.mapping.Altitude, "altitude"); err != nil {
+		return nil, r.row, err
+	}
+	req.SensorModel = field(r.mapping.SensorModel)
+	req.FirmwareVersion = field(r.mapping.FirmwareVersion)
+
+	return &req, r.row, nil
+}
+
+// ndjsonRowReader reads requests from newline-delimited JSON
+type ndjsonRowReader struct {
+	scanner *bufio.Scanner
+	row     int
+	done    bool
+}
+
+// newNDJSONRowReader creates an NDJSON row reader
+func newNDJSONRowReader(r io.Reader) *ndjsonRowReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+	return &ndjsonRowReader{scanner: scanner}
+}
+
+// Next reads the next non-empty line. Rows are numbered from 1.
+func (r *ndjsonRowReader) Next() (*AirQualityDataRequest, int, error) {
+	if r.done {
+		return nil, r.row, io.EOF
+	}
+
+	for r.scanner.Scan() {
+		r.row++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req AirQualityDataRequest
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			return nil, r.row, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return &req, r.row, nil
+	}
+
+	// The scanner cannot continue after an error, so report it once and stop reading
+	r.done = true
+	if err := r.scanner.Err(); err != nil {
+		r.row++
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, r.row, fmt.Errorf("line exceeds %d bytes", maxNDJSONLineSize)
+		}
+		return nil, r.row, fmt.Errorf("failed to read upload: %w", err)
+	}
+	return nil, r.row, io.EOF
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSVRowReader(t *testing.T) {
+	input := "time;lat;lon;pm\n" +
+		"2025-05-02 13:45;41.015;28.979;25.5\n" +
+		"2025-05-02 13:46;not-a-number;28.979;26.0\n" +
+		"2025-05-02 13:47;41.015;28.979;27.0\n"
+
+	mapping := CSVColumnMapping{
+		Latitude:  "lat",
+		Longitude: "lon",
+		Parameter: "parameter",
+		Value:     "pm",
+		Timestamp: "time",
+	}
+
+	reader, err := newCSVRowReader(strings.NewReader(input), mapping, "PM2.5", "", "2006-01-02 15:04", ";")
+	if err != nil {
+		t.Fatalf("Failed to create CSV reader: %v", err)
+	}
+
+	req, row, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Expected first row to parse, got %v", err)
+	}
+	if row != 2 || req.Parameter != "PM2.5" || req.Value != 25.5 || req.Latitude != 41.015 {
+		t.Errorf("Unexpected first row %d: %+v", row, req)
+	}
+
+	if _, row, err = reader.Next(); err == nil || row != 3 {
+		t.Errorf("Expected an error on row 3, got row %d err %v", row, err)
+	}
+
+	if _, row, err = reader.Next(); err != nil || row != 4 {
+		t.Errorf("Expected row 4 to parse, got row %d err %v", row, err)
+	}
+
+	if _, _, err = reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestCSVRowReaderMetadataColumns(t *testing.T) {
+	input := "latitude,longitude,parameter,value,timestamp,humidity,temperature,sensor_model\n" +
+		"41.015,28.979,PM2.5,25.5,2025-05-02T13:45:00Z,65,21.5,SDS011\n" +
+		"41.015,28.979,PM2.5,26.0,2025-05-02T13:46:00Z,,,\n" +
+		"41.015,28.979,PM2.5,27.0,2025-05-02T13:47:00Z,humid,,\n"
+
+	mapping := CSVColumnMapping{
+		Latitude:    "latitude",
+		Longitude:   "longitude",
+		Parameter:   "parameter",
+		Value:       "value",
+		Timestamp:   "timestamp",
+		Humidity:    "humidity",
+		Temperature: "temperature",
+		Altitude:    "altitude",
+		SensorModel: "sensor_model",
+	}
+
+	reader, err := newCSVRowReader(strings.NewReader(input), mapping, "", "", "2006-01-02T15:04:05Z07:00", ",")
+	if err != nil {
+		t.Fatalf("Failed to create CSV reader: %v", err)
+	}
+
+	req, _, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Expected first row to parse, got %v", err)
+	}
+	if req.Humidity == nil || *req.Humidity != 65 || req.Temperature == nil || *req.Temperature != 21.5 {
+		t.Errorf("Expected humidity and temperature, got %+v", req.ReadingMetadata)
+	}
+	if req.Altitude != nil || req.SensorModel != "SDS011" {
+		t.Errorf("Expected no altitude and sensor model SDS011, got %+v", req.ReadingMetadata)
+	}
+
+	req, _, err = reader.Next()
+	if err != nil {
+		t.Fatalf("Expected second row to parse, got %v", err)
+	}
+	if req.Humidity != nil || req.Temperature != nil {
+		t.Errorf("Expected empty metadata cells to be omitted, got %+v", req.ReadingMetadata)
+	}
+
+	if _, _, err = reader.Next(); err == nil {
+		t.Errorf("Expected an error for an invalid humidity")
+	}
+}
+
+func TestCSVRowReaderMissingColumn(t *testing.T) {
+	mapping := CSVColumnMapping{
+		Latitude:  "latitude",
+		Longitude: "longitude",
+		Parameter: "parameter",
+		Value:     "value",
+		Timestamp: "timestamp",
+	}
+
+	_, err := newCSVRowReader(strings.NewReader("latitude,longitude,value,timestamp\n"), mapping, "", "", "", ",")
+	if err == nil {
+		t.Errorf("Expected error for missing parameter column")
+	}
+}
+
+func TestNDJSONRowReader(t *testing.T) {
+	input := `{"latitude": 41.015, "longitude": 28.979, "parameter": "PM2.5", "value": 25.0, "timestamp": "2025-05-02T13:45:00Z"}
+
+{"latitude": 41.015, "longitude":
+{"latitude": 41.015, "longitude": 28.979, "parameter": "NO2", "value": 30.0, "timestamp": "2025-05-02T13:46:00Z"}
+`
+	reader := newNDJSONRowReader(strings.NewReader(input))
+
+	req, row, err := reader.Next()
+	if err != nil || row != 1 || req.Parameter != "PM2.5" {
+		t.Errorf("Unexpected first row %d: %+v, %v", row, req, err)
+	}
+
+	if _, row, err = reader.Next(); err == nil || row != 3 {
+		t.Errorf("Expected an error on row 3, got row %d err %v", row, err)
+	}
+
+	req, row, err = reader.Next()
+	if err != nil || row != 4 || req.Parameter != "NO2" {
+		t.Errorf("Unexpected row %d: %+v, %v", row, req, err)
+	}
+
+	if _, _, err = reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
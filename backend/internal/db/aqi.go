@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+// UpsertAirQualityIndex stores the current index of a location on a scale. An index observed
+// before the stored one does not replace it, so that late readings do not roll the index back.
+func (db *DB) UpsertAirQualityIndex(index *models.AirQualityIndex) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pollutants, err := json.Marshal(index.Pollutants)
+	if err != nil {
+		return fmt.Errorf("failed to encode pollutant indices: %w", err)
+	}
+
+	_, err = db.pool.Exec(ctx, `
+		INSERT INTO current_aqi (location, scale, latitude, longitude, value, category, color, dominant_pollutant,
+			pollutants, observed_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (location, scale) DO UPDATE SET
+			latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude,
+			value = EXCLUDED.value,
+			category = EXCLUDED.category,
+			color = EXCLUDED.color,
+			dominant_pollutant = EXCLUDED.dominant_pollutant,
+			pollutants = EXCLUDED.pollutants,
+			observed_at = EXCLUDED.observed_at,
+			updated_at = EXCLUDED.updated_at
+		WHERE current_aqi.observed_at <= EXCLUDED.observed_at
+	`, index.Location, index.Scale, index.Latitude, index.Longitude, index.Value, index.Category, index.Color,
+		index.DominantPollutant, string(pollutants), index.ObservedAt, index.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert air quality index: %w", err)
+	}
+
+	return nil
+}
+
+// GetCurrentAirQualityIndices gets the current indices, optionally of one scale and one location.
+// Empty filters match everything.
+func (db *DB) GetCurrentAirQualityIndices(scale, location string) ([]models.AirQualityIndex, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := db.pool.Query(ctx, `
+		SELECT location, scale, latitude, longitude, value, category, color, dominant_pollutant,
+			pollutants, observed_at, updated_at
+		FROM current_aqi
+		WHERE ($1 = '' OR scale = $1) AND ($2 = '' OR location = $2)
+		ORDER BY location, scale
+	`, scale, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query air quality indices: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.AirQualityIndex{}
+	for rows.Next() {
+		var index models.AirQualityIndex
+		var pollutants []byte
+		if err := rows.Scan(&index.Location, &index.Scale, &index.Latitude, &index.Longitude, &index.Value,
+			&index.Category, &index.Color, &index.DominantPollutant, &pollutants, &index.ObservedAt, &index.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(pollutants, &index.Pollutants); err != nil {
+			return nil, fmt.Errorf("failed to decode pollutant indices: %w", err)
+		}
+		results = append(results, index)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
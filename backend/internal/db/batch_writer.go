@@ -0,0 +1,453 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/airpollution/internal/models"
+)
+
+const (
+	// DefaultMaxBatchSize is the number of rows at which a batch is flushed
+	DefaultMaxBatchSize = 500
+	// DefaultFlushInterval is how long the first row of a batch waits for more rows
+	DefaultFlushInterval = 100 * time.Millisecond
+	// DefaultFlushTimeout bounds a single flush, including the fallback to row by row inserts
+	DefaultFlushTimeout = 10 * time.Second
+)
+
+// ErrWriterClosed is returned for rows written after the batch writer was closed
+var ErrWriterClosed = errors.New("batch writer is closed")
+
+// BatchWriterConfig configures a BatchWriter. Zero values select the defaults.
+type BatchWriterConfig struct {
+	// MaxBatchSize is the number of rows at which a batch is flushed
+	MaxBatchSize int
+	// FlushInterval is how long the first row of a batch waits for more rows
+	FlushInterval time.Duration
+	// QueueSize is the number of rows that may wait for a flush before writers block, twice
+	// MaxBatchSize by default
+	QueueSize int
+	// FlushTimeout bounds a single flush, including the fallback to row by row inserts
+	FlushTimeout time.Duration
+}
+
+// BatchWriter buffers readings and the anomalies of readings and writes them with COPY. A batch is flushed when it
+// reaches the size threshold or its first row has waited for the flush interval. Writers block
+// until their row has been flushed, and while the queue is full, which holds back their callers
+// when the database falls behind. When a COPY fails, the rows of the batch are inserted one by one
+// so that a single bad row only fails its own writer. The anomalies of a reading are one row, so
+// that they are stored together.
+type BatchWriter struct {
+	readings  *batcher[*models.AirQualityData, bool]
+	anomalies *batcher[[]*models.Anomaly, struct{}]
+}
+
+// NewBatchWriter starts a batch writer for the database
+func NewBatchWriter(db *DB, config BatchWriterConfig) *BatchWriter {
+	return &BatchWriter{
+		readings: newBatcher(config, "readings", db.CopyAirQualityData, db.InsertAirQualityDataContext),
+		anomalies: newBatcher(config, "anomalies", db.CopyAnomalies, func(ctx context.Context, anomalies []*models.Anomaly) (struct{}, error) {
+			return struct{}{}, db.InsertAnomaliesContext(ctx, anomalies)
+		}),
+	}
+}
+
+// WriteAirQualityData queues a reading and waits until it has been written. Like
+// InsertAirQualityData, it reports whether the reading was newly inserted and copies the ID of
+// the stored row back to data.
+func (w *BatchWriter) WriteAirQualityData(ctx context.Context, data *models.AirQualityData) (bool, error) {
+	return w.readings.write(ctx, data)
+}
+
+// WriteAnomalies queues the anomalies of a reading and waits until they have been written. The
+// anomalies are written in one transaction, and those already stored are skipped.
+func (w *BatchWriter) WriteAnomalies(ctx context.Context, anomalies []*models.Anomaly) error {
+	if len(anomalies) == 0 {
+		return nil
+	}
+	_, err := w.anomalies.write(ctx, anomalies)
+	return err
+}
+
+// Close flushes the queued rows and stops the writer. Rows written after Close fail with ErrWriterClosed.
+func (w *BatchWriter) Close() {
+	w.readings.close()
+	w.anomalies.close()
+}
+
+// batchRequest is a row waiting for a flush and, once done is closed, its result
+type batchRequest[T any, R any] struct {
+	row    T
+	result R
+	err    error
+	done   chan struct{}
+}
+
+// batcher collects rows into batches and flushes them on a single goroutine
+type batcher[T any, R any] struct {
+	config  BatchWriterConfig
+	name    string
+	flush   func(ctx context.Context, rows []T) ([]R, error)
+	single  func(ctx context.Context, row T) (R, error)
+	queue   chan *batchRequest[T, R]
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newBatcher starts a batcher that flushes batches with flush and falls back to single on errors
+func newBatcher[T any, R any](config BatchWriterConfig, name string, flush func(context.Context, []T) ([]R, error), single func(context.Context, T) (R, error)) *batcher[T, R] {
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultFlushInterval
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 2 * config.MaxBatchSize
+	}
+	if config.FlushTimeout <= 0 {
+		config.FlushTimeout = DefaultFlushTimeout
+	}
+
+	b := &batcher[T, R]{
+		config:  config,
+		name:    name,
+		flush:   flush,
+		single:  single,
+		queue:   make(chan *batchRequest[T, R], config.QueueSize),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// write queues a row and waits for its result
+func (b *batcher[T, R]) write(ctx context.Context, row T) (R, error) {
+	var zero R
+	req := &batchRequest[T, R]{row: row, done: make(chan struct{})}
+
+	select {
+	case b.queue <- req:
+	case <-b.stop:
+		return zero, ErrWriterClosed
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+
+	select {
+	case <-req.done:
+		return req.result, req.err
+	case <-b.stopped:
+		// The row was queued after the final flush
+		select {
+		case <-req.done:
+			return req.result, req.err
+		default:
+			return zero, ErrWriterClosed
+		}
+	case <-ctx.Done():
+		// The row may still be written
+		return zero, ctx.Err()
+	}
+}
+
+// close stops accepting rows, flushes the queued ones and waits for the flush
+func (b *batcher[T, R]) close() {
+	close(b.stop)
+	<-b.stopped
+}
+
+// run collects rows until the batch is full, the first row has waited for the flush interval or
+// the batcher is closed
+func (b *batcher[T, R]) run() {
+	defer close(b.stopped)
+
+	batch := make([]*batchRequest[T, R], 0, b.config.MaxBatchSize)
+	timer := time.NewTimer(b.config.FlushInterval)
+	timer.Stop()
+
+	for {
+		select {
+		case req := <-b.queue:
+			batch = append(batch, req)
+			if len(batch) == 1 {
+				timer.Reset(b.config.FlushInterval)
+			}
+			if len(batch) >= b.config.MaxBatchSize {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				b.flushBatch(batch)
+				batch = batch[:0]
+			}
+		case <-timer.C:
+			b.flushBatch(batch)
+			batch = batch[:0]
+		case <-b.stop:
+			timer.Stop()
+			// Flush what was queued before the writer was closed
+			for {
+				select {
+				case req := <-b.queue:
+					batch = append(batch, req)
+					if len(batch) >= b.config.MaxBatchSize {
+						b.flushBatch(batch)
+						batch = batch[:0]
+					}
+				default:
+					b.flushBatch(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flushBatch writes a batch and hands each writer its result
+func (b *batcher[T, R]) flushBatch(batch []*batchRequest[T, R]) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.FlushTimeout)
+	defer cancel()
+
+	rows := make([]T, len(batch))
+	for i, req := range batch {
+		rows[i] = req.row
+	}
+
+	results, err := b.flush(ctx, rows)
+	if err == nil {
+		for i, req := range batch {
+			req.result = results[i]
+			close(req.done)
+		}
+		return
+	}
+
+	// Find the failing rows by inserting them one by one, within the same flush timeout. Once it
+	// has passed, the remaining rows fail without being tried, and their writers retry them.
+	log.Printf("Failed to copy batch of %d %s, inserting them one by one: %v", len(batch), b.name, err)
+	for _, req := range batch {
+		if ctx.Err() != nil {
+			req.err = fmt.Errorf("flushing %s: %w", b.name, ctx.Err())
+		} else {
+			req.result, req.err = b.single(ctx, req.row)
+		}
+		close(req.done)
+	}
+}
+
+// readingColumns are the columns of air_quality_data written by CopyAirQualityData
+var readingColumns = []string{"id", "sensor_id", "latitude", "longitude", "parameter", "value", "timestamp",
+	"unit", "original_value", "original_unit", "signature_status", "observation_id",
+	"humidity", "temperature", "altitude", "sensor_model", "firmware_version", "tags", "lateness"}
+
+// CopyAirQualityData upserts readings like InsertAirQualityData, but copies them to a staging table
+// and upserts them with two statements in one transaction. Readings that share a key within the
+// batch are written in separate rounds, in order, so that the result matches inserting them one by one.
+func (db *DB) CopyAirQualityData(ctx context.Context, data []*models.AirQualityData) ([]bool, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMP TABLE air_quality_staging ON COMMIT DROP AS
+		SELECT 0 AS seq, * FROM air_quality_data WITH NO DATA
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	inserted := make([]bool, len(data))
+	ids := make([]uuid.UUID, len(data))
+	remaining := make([]int, len(data))
+	for i := range data {
+		remaining[i] = i
+		ids[i] = data[i].ID
+	}
+	for first := true; len(remaining) > 0; first = false {
+		// Take the first reading of every key into this round
+		var round, later []int
+		seen := make(map[string]bool, len(remaining))
+		for _, i := range remaining {
+			key := readingKey(data[i])
+			if seen[key] {
+				later = append(later, i)
+				continue
+			}
+			seen[key] = true
+			round = append(round, i)
+		}
+
+		if !first {
+			if _, err := tx.Exec(ctx, "TRUNCATE air_quality_staging"); err != nil {
+				return nil, fmt.Errorf("failed to clear staging table: %w", err)
+			}
+		}
+		if err := copyReadingsRound(ctx, tx, data, round, ids, inserted); err != nil {
+			return nil, err
+		}
+		remaining = later
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit air quality data: %w", err)
+	}
+
+	// Copy back the IDs of the stored rows only once they are committed
+	for i := range data {
+		data[i].ID = ids[i]
+	}
+	return inserted, nil
+}
+
+// readingKey returns the key a reading is deduplicated on: its natural key, or its ID when anonymous
+func readingKey(data *models.AirQualityData) string {
+	micros := data.Timestamp.Truncate(time.Microsecond).UnixMicro()
+	if data.SensorID != "" {
+		return fmt.Sprintf("s\n%s\n%s\n%d", data.SensorID, data.Parameter, micros)
+	}
+	return fmt.Sprintf("a\n%s\n%d", data.ID, micros)
+}
+
+// copyReadingsRound upserts readings with distinct keys through the staging table and records the
+// stored IDs and which readings were inserted
+func copyReadingsRound(ctx context.Context, tx pgx.Tx, data []*models.AirQualityData, round []int, ids []uuid.UUID, inserted []bool) error {
+	rows := make([][]interface{}, len(round))
+	for n, i := range round {
+		d := data[i]
+		tags, err := encodeTags(d.Tags)
+		if err != nil {
+			return err
+		}
+		rows[n] = []interface{}{i, d.ID, nullIfEmpty(d.SensorID), d.Latitude, d.Longitude, d.Parameter, d.Value, d.Timestamp,
+			nullIfEmpty(d.Unit), d.OriginalValue, nullIfEmpty(d.OriginalUnit), nullIfEmpty(d.SignatureStatus), d.ObservationID,
+			d.Humidity, d.Temperature, d.Altitude, nullIfEmpty(d.SensorModel), nullIfEmpty(d.FirmwareVersion), tags, nullIfEmpty(d.Lateness)}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"air_quality_staging"}, append([]string{"seq"}, readingColumns...), pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy air quality data: %w", err)
+	}
+
+	// Sensor readings conflict on the natural key and anonymous readings on their ID
+	columns := strings.Join(readingColumns, ", ")
+	for _, upsert := range []struct{ filter, conflictTarget, join string }{
+		{"sensor_id IS NOT NULL", "(sensor_id, parameter, timestamp)",
+			"s.sensor_id = u.sensor_id AND s.parameter = u.parameter AND s.timestamp = u.timestamp"},
+		{"sensor_id IS NULL", "(id, timestamp)", "s.id = u.id AND s.timestamp = u.timestamp"},
+	} {
+		results, err := tx.Query(ctx, `
+			WITH upserted AS (
+				INSERT INTO air_quality_data (`+columns+`)
+				SELECT `+columns+` FROM air_quality_staging
+				WHERE `+upsert.filter+`
+				ON CONFLICT `+upsert.conflictTarget+` DO UPDATE SET
+					latitude = EXCLUDED.latitude,
+					longitude = EXCLUDED.longitude,
+					value = EXCLUDED.value,
+					unit = EXCLUDED.unit,
+					original_value = EXCLUDED.original_value,
+					original_unit = EXCLUDED.original_unit,
+					signature_status = EXCLUDED.signature_status,
+					observation_id = EXCLUDED.observation_id,
+					humidity = EXCLUDED.humidity,
+					temperature = EXCLUDED.temperature,
+					altitude = EXCLUDED.altitude,
+					sensor_model = EXCLUDED.sensor_model,
+					firmware_version = EXCLUDED.firmware_version,
+					tags = EXCLUDED.tags,
+					lateness = EXCLUDED.lateness
+				RETURNING id, sensor_id, parameter, timestamp, (xmax = 0) AS inserted
+			)
+			SELECT s.seq, u.id, u.inserted FROM upserted u JOIN air_quality_staging s ON `+upsert.join)
+		if err != nil {
+			return fmt.Errorf("failed to upsert air quality data: %w", err)
+		}
+
+		for results.Next() {
+			var seq int
+			var id uuid.UUID
+			var wasInserted bool
+			if err := results.Scan(&seq, &id, &wasInserted); err != nil {
+				results.Close()
+				return fmt.Errorf("failed to read upsert result: %w", err)
+			}
+			ids[seq] = id
+			inserted[seq] = wasInserted
+		}
+		results.Close()
+		if err := results.Err(); err != nil {
+			return fmt.Errorf("failed to upsert air quality data: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// anomalyColumns are the columns of anomalies written by CopyAnomalies
+var anomalyColumns = []string{"id", "type", "parameter", "value", "latitude", "longitude", "detected_at",
+	"air_quality_data_id", "air_quality_data_timestamp", "averaging_period"}
+
+// CopyAnomalies inserts the anomalies of readings like InsertAnomalies, but copies them to a
+// staging table and inserts them with one statement, skipping those already stored
+func (db *DB) CopyAnomalies(ctx context.Context, groups [][]*models.Anomaly) ([]struct{}, error) {
+	var rows [][]interface{}
+	for _, anomalies := range groups {
+		for _, a := range anomalies {
+			rows = append(rows, []interface{}{a.ID, a.Type, a.Parameter, a.Value, a.Latitude, a.Longitude, a.DetectedAt,
+				a.AirQualityDataID, a.AirQualityDataTimestamp, nullIfEmpty(a.AveragingPeriod)})
+		}
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMP TABLE anomalies_staging ON COMMIT DROP AS
+		SELECT * FROM anomalies WITH NO DATA
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"anomalies_staging"}, anomalyColumns, pgx.CopyFromRows(rows)); err != nil {
+		return nil, fmt.Errorf("failed to copy anomalies: %w", err)
+	}
+
+	columns := strings.Join(anomalyColumns, ", ")
+	_, err = tx.Exec(ctx, `
+		INSERT INTO anomalies (`+columns+`)
+		SELECT DISTINCT ON (id) `+columns+` FROM anomalies_staging s
+		WHERE NOT EXISTS (SELECT 1 FROM anomalies a WHERE a.id = s.id)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert anomalies: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit anomalies: %w", err)
+	}
+	return make([]struct{}, len(groups)), nil
+}
+
+// nullIfEmpty returns nil for an empty string, so that COPY stores NULL like the NULLIF of single inserts
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/airpollution/internal/models"
+)
+
+// recordingFlusher records the batches it flushes and fails batches containing a bad row
+type recordingFlusher struct {
+	mu      sync.Mutex
+	batches [][]int
+	singles []int
+	release chan struct{}
+}
+
+func (f *recordingFlusher) flush(ctx context.Context, rows []int) ([]int, error) {
+	if f.release != nil {
+		<-f.release
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, append([]int{}, rows...))
+	for _, row := range rows {
+		if row < 0 {
+			return nil, errors.New("bad row in batch")
+		}
+	}
+	results := make([]int, len(rows))
+	for i, row := range rows {
+		results[i] = row * 10
+	}
+	return results, nil
+}
+
+func (f *recordingFlusher) single(ctx context.Context, row int) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.singles = append(f.singles, row)
+	if row < 0 {
+		return 0, errors.New("bad row")
+	}
+	return row * 10, nil
+}
+
+func (f *recordingFlusher) batchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sizes := make([]int, len(f.batches))
+	for i, batch := range f.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+// writeAll writes rows concurrently and returns their results and errors by row index
+func writeAll(b *batcher[int, int], rows []int) ([]int, []error) {
+	results := make([]int, len(rows))
+	errs := make([]error, len(rows))
+	var wg sync.WaitGroup
+	for i, row := range rows {
+		wg.Add(1)
+		go func(i, row int) {
+			defer wg.Done()
+			results[i], errs[i] = b.write(context.Background(), row)
+		}(i, row)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+func TestBatcherFlushesFullBatches(t *testing.T) {
+	f := &recordingFlusher{}
+	b := newBatcher(BatchWriterConfig{MaxBatchSize: 5, FlushInterval: time.Hour, QueueSize: 10}, "rows", f.flush, f.single)
+	defer b.close()
+
+	results, errs := writeAll(b, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	for i := range results {
+		if errs[i] != nil || results[i] != (i+1)*10 {
+			t.Errorf("Expected result %d, got %d, %v", (i+1)*10, results[i], errs[i])
+		}
+	}
+	if sizes := f.batchSizes(); len(sizes) != 2 || sizes[0] != 5 || sizes[1] != 5 {
+		t.Errorf("Expected two batches of 5, got %v", sizes)
+	}
+}
+
+func TestBatcherFlushesAfterInterval(t *testing.T) {
+	f := &recordingFlusher{}
+	b := newBatcher(BatchWriterConfig{MaxBatchSize: 100, FlushInterval: 20 * time.Millisecond, QueueSize: 100}, "rows", f.flush, f.single)
+	defer b.close()
+
+	start := time.Now()
+	if result, err := b.write(context.Background(), 4); err != nil || result != 40 {
+		t.Fatalf("Expected result 40, got %d, %v", result, err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the row to wait for the flush interval, flushed after %v", elapsed)
+	}
+}
+
+func TestBatcherFallsBackToSingleRows(t *testing.T) {
+	f := &recordingFlusher{}
+	b := newBatcher(BatchWriterConfig{MaxBatchSize: 3, FlushInterval: time.Hour, QueueSize: 3}, "rows", f.flush, f.single)
+	defer b.close()
+
+	results, errs := writeAll(b, []int{1, -1, 2})
+	for i, row := range []int{1, -1, 2} {
+		if row < 0 && errs[i] == nil {
+			t.Errorf("Expected the bad row to fail")
+		}
+		if row >= 0 && (errs[i] != nil || results[i] != row*10) {
+			t.Errorf("Expected row %d to be written after the batch failed, got %d, %v", row, results[i], errs[i])
+		}
+	}
+	if len(f.singles) != 3 {
+		t.Errorf("Expected all rows of the failed batch to be retried one by one, got %v", f.singles)
+	}
+}
+
+func TestBatcherFallbackStopsAtFlushTimeout(t *testing.T) {
+	var mu sync.Mutex
+	tried := 0
+	failing := func(ctx context.Context, rows []int) ([]int, error) {
+		return nil, errors.New("copy failed")
+	}
+	slow := func(ctx context.Context, row int) (int, error) {
+		mu.Lock()
+		tried++
+		mu.Unlock()
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	b := newBatcher(BatchWriterConfig{MaxBatchSize: 10, FlushInterval: time.Hour, QueueSize: 10, FlushTimeout: 20 * time.Millisecond}, "rows", failing, slow)
+	defer b.close()
+
+	start := time.Now()
+	_, errs := writeAll(b, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Expected the fallback to stop at the flush timeout, took %v", elapsed)
+	}
+	for i, err := range errs {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected row %d to fail with the flush timeout, got %v", i, err)
+		}
+	}
+	if tried != 1 {
+		t.Errorf("Expected only the first row to be tried before the timeout, tried %d", tried)
+	}
+}
+
+func TestBatcherBackpressureAndClose(t *testing.T) {
+	f := &recordingFlusher{release: make(chan struct{})}
+	b := newBatcher(BatchWriterConfig{MaxBatchSize: 1, FlushInterval: time.Hour, QueueSize: 1}, "rows", f.flush, f.single)
+
+	// The first row blocks in the flush and the second fills the queue
+	var wg sync.WaitGroup
+	for _, row := range []int{1, 2} {
+		wg.Add(1)
+		go func(row int) {
+			defer wg.Done()
+			b.write(context.Background(), row)
+		}(row)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := b.write(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a full queue to block the writer, got %v", err)
+	}
+
+	// Closing flushes the queued rows
+	close(f.release)
+	b.close()
+	wg.Wait()
+	if sizes := f.batchSizes(); len(sizes) != 2 {
+		t.Errorf("Expected the queued rows to be flushed on close, got batches %v", sizes)
+	}
+	if _, err := b.write(context.Background(), 4); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("Expected ErrWriterClosed after close, got %v", err)
+	}
+}
+
+func TestReadingKey(t *testing.T) {
+	timestamp := time.Date(2025, 5, 2, 13, 45, 0, 123456789, time.UTC)
+	sensor := &models.AirQualityData{ID: uuid.New(), SensorID: "station-7", Parameter: "PM2.5", Timestamp: timestamp}
+	resubmitted := &models.AirQualityData{ID: uuid.New(), SensorID: "station-7", Parameter: "PM2.5", Timestamp: timestamp.Add(100)}
+	anonymous := &models.AirQualityData{ID: sensor.ID, Parameter: "PM2.5", Timestamp: timestamp}
+
+	if readingKey(sensor) != readingKey(resubmitted) {
+		t.Errorf("Expected sensor readings to share the natural key at microsecond precision")
+	}
+	if readingKey(sensor) == readingKey(anonymous) {
+		t.Errorf("Expected anonymous readings to be keyed by ID")
+	}
+}
@@ -1,10 +1,13 @@
 package db
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/user/airpollution/internal/models"
 )
 
@@ -33,10 +36,22 @@ func TestDB_Operations(t *testing.T) {
 	}
 
 	// Test insert
-	err = db.InsertAirQualityData(testData)
+	inserted, err := db.InsertAirQualityData(testData)
 	if err != nil {
 		t.Fatalf("Failed to insert data: %v", err)
 	}
+	if !inserted {
+		t.Errorf("Expected first insert to be reported as inserted")
+	}
+
+	// Test replay is harmless
+	inserted, err = db.InsertAirQualityData(testData)
+	if err != nil {
+		t.Fatalf("Failed to upsert data: %v", err)
+	}
+	if inserted {
+		t.Errorf("Expected replayed insert to be reported as duplicate")
+	}
 
 	// Test query
 	results, err := db.GetRecentDataForParameter(testData.Parameter, testData.Latitude, testData.Longitude, 24)
@@ -72,9 +87,14 @@ func NewMockDB() *MockDB {
 	}
 }
 
-func (m *MockDB) InsertAirQualityData(data *models.AirQualityData) error {
+func (m *MockDB) InsertAirQualityData(data *models.AirQualityData) (bool, error) {
+	for _, existing := range m.data {
+		if existing.ID == data.ID && existing.Timestamp.Equal(data.Timestamp) {
+			return false, nil
+		}
+	}
 	m.data = append(m.data, *data)
-	return nil
+	return true, nil
 }
 
 func (m *MockDB) InsertAnomaly(anomaly *models.Anomaly) error {
@@ -110,11 +130,20 @@ func TestMockDB_Operations(t *testing.T) {
 	}
 
 	// Test insert
-	err := mockDB.InsertAirQualityData(testData)
+	_, err := mockDB.InsertAirQualityData(testData)
 	if err != nil {
 		t.Fatalf("Failed to insert data: %v", err)
 	}
 
+	// Test replay
+	inserted, err := mockDB.InsertAirQualityData(testData)
+	if err != nil {
+		t.Fatalf("Failed to insert data: %v", err)
+	}
+	if inserted {
+		t.Errorf("Expected replayed insert to be reported as duplicate")
+	}
+
 	// Test query
 	results, err := mockDB.GetRecentDataForParameter(testData.Parameter, testData.Latitude, testData.Longitude, 24)
 	if err != nil {
@@ -126,3 +155,47 @@ func TestMockDB_Operations(t *testing.T) {
 		t.Errorf("Expected 1 result, got %d", len(results))
 	}
 }
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Check Violation", &pgconn.PgError{Code: "23514"}, true},
+		{"Wrapped Numeric Out Of Range", fmt.Errorf("failed to insert: %w", &pgconn.PgError{Code: "22003"}), true},
+		{"Undefined Table", &pgconn.PgError{Code: "42P01"}, false},
+		{"Connection Error", errors.New("connection refused"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if IsPermanent(tc.err) != tc.expected {
+				t.Errorf("Expected IsPermanent %v, got %v", tc.expected, !tc.expected)
+			}
+		})
+	}
+}
+
+func TestIsReadingIDConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Primary Key", &pgconn.PgError{Code: "23505", ConstraintName: "air_quality_data_pkey"}, true},
+		{"Chunk Primary Key", fmt.Errorf("failed to insert: %w", &pgconn.PgError{Code: "23505", ConstraintName: "3_5_air_quality_data_pkey"}), true},
+		{"Natural Key", &pgconn.PgError{Code: "23505", ConstraintName: "_hyper_1_2_chunk_idx_air_quality_natural_key"}, false},
+		{"Check Violation", &pgconn.PgError{Code: "23514", ConstraintName: "air_quality_data_pkey"}, false},
+		{"Connection Error", errors.New("connection refused"), false},
+		{"No Error", nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if isReadingIDConflict(tc.err) != tc.expected {
+				t.Errorf("Expected isReadingIDConflict %v, got %v", tc.expected, !tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/airpollution/internal/models"
+)
+
+// ErrSensorNotFound is returned when a sensor does not exist
+var ErrSensorNotFound = errors.New("sensor not found")
+
+// CreateSensor inserts a new sensor
+func (db *DB) CreateSensor(sensor *models.Sensor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := db.pool.Exec(ctx, `
+		INSERT INTO sensors (id, name, api_key_hash, enabled, class, signing_secret, require_signature, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+	`, sensor.ID, sensor.Name, sensor.APIKeyHash, sensor.Enabled, sensor.Class, sensor.SigningSecret, sensor.RequireSignature,
+		sensor.CreatedAt, sensor.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert sensor: %w", err)
+	}
+
+	return nil
+}
+
+// GetSensor gets a sensor by ID
+func (db *DB) GetSensor(id string) (*models.Sensor, error) {
+	return db.querySensor(`
+		SELECT id, name, api_key_hash, enabled, class, COALESCE(signing_secret, ''), require_signature, created_at, updated_at
+		FROM sensors
+		WHERE id = $1
+	`, id)
+}
+
+// GetSensorByAPIKeyHash gets the sensor that owns the given API key hash
+func (db *DB) GetSensorByAPIKeyHash(apiKeyHash string) (*models.Sensor, error) {
+	return db.querySensor(`
+		SELECT id, name, api_key_hash, enabled, class, COALESCE(signing_secret, ''), require_signature, created_at, updated_at
+		FROM sensors
+		WHERE api_key_hash = $1
+	`, apiKeyHash)
+}
+
+// ListSensors gets all registered sensors
+func (db *DB) ListSensors() ([]models.Sensor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := db.pool.Query(ctx, `
+		SELECT id, name, api_key_hash, enabled, class, COALESCE(signing_secret, ''), require_signature, created_at, updated_at
+		FROM sensors
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.Sensor{}
+	for rows.Next() {
+		var sensor models.Sensor
+		if err := rows.Scan(&sensor.ID, &sensor.Name, &sensor.APIKeyHash, &sensor.Enabled,
+			&sensor.Class, &sensor.SigningSecret, &sensor.RequireSignature, &sensor.CreatedAt, &sensor.UpdatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, sensor)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// UpdateSensorAPIKey replaces the API key hash of a sensor
+func (db *DB) UpdateSensorAPIKey(id, apiKeyHash string) error {
+	return db.updateSensor(`
+		UPDATE sensors SET api_key_hash = $2, updated_at = NOW() WHERE id = $1
+	`, id, apiKeyHash)
+}
+
+// UpdateSensorSigningSecret replaces the HMAC signing secret of a sensor and whether signatures are required
+func (db *DB) UpdateSensorSigningSecret(id, secret string, requireSignature bool) error {
+	return db.updateSensor(`
+		UPDATE sensors SET signing_secret = NULLIF($2, ''), require_signature = $3, updated_at = NOW() WHERE id = $1
+	`, id, secret, requireSignature)
+}
+
+// SetSensorEnabled enables or disables a sensor
+func (db *DB) SetSensorEnabled(id string, enabled bool) error {
+	return db.updateSensor(`
+		UPDATE sensors SET enabled = $2, updated_at = NOW() WHERE id = $1
+	`, id, enabled)
+}
+
+// SetSensorClass changes the rate limit class of a sensor
+func (db *DB) SetSensorClass(id, class string) error {
+	return db.updateSensor(`
+		UPDATE sensors SET class = $2, updated_at = NOW() WHERE id = $1
+	`, id, class)
+}
+
+// querySensor runs a query that returns at most one sensor
+func (db *DB) querySensor(query string, args ...interface{}) (*models.Sensor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var sensor models.Sensor
+	err := db.pool.QueryRow(ctx, query, args...).Scan(&sensor.ID, &sensor.Name, &sensor.APIKeyHash,
+		&sensor.Enabled, &sensor.Class, &sensor.SigningSecret, &sensor.RequireSignature, &sensor.CreatedAt, &sensor.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSensorNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor: %w", err)
+	}
+
+	return &sensor, nil
+}
+
+// updateSensor runs an update on a single sensor and reports ErrSensorNotFound if no row matched
+func (db *DB) updateSensor(query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tag, err := db.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update sensor: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSensorNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/sensorthings"
+)
+
+// SensorThings entities are views over air_quality_data: a Thing and its Location are a sensor ID with
+// readings, a Datastream is a sensor ID and parameter, and an Observation is a reading. Anonymous
+// readings have no Thing and are not exposed.
+
+const thingsBase = `
+	SELECT d.sensor_id AS id, COALESCE(s.name, d.sensor_id) AS name, COALESCE(s.class, '') AS class,
+		d.latitude, d.longitude, d.timestamp AS last_seen
+	FROM (
+		SELECT DISTINCT ON (sensor_id) sensor_id, latitude, longitude, timestamp
+		FROM air_quality_data
+		WHERE sensor_id IS NOT NULL
+		ORDER BY sensor_id, timestamp DESC
+	) d
+	LEFT JOIN sensors s ON s.id = d.sensor_id`
+
+const datastreamsBase = `
+	SELECT sensor_id || ':' || parameter AS id, sensor_id || ' ' || parameter AS name, sensor_id AS thing_id, parameter,
+		MIN(timestamp) AS phenomenon_start, MAX(timestamp) AS phenomenon_end
+	FROM air_quality_data
+	WHERE sensor_id IS NOT NULL
+	GROUP BY sensor_id, parameter`
+
+const observationsBase = `
+	SELECT id, id::text AS iot_id, sensor_id, sensor_id || ':' || parameter AS datastream_id, parameter, value, timestamp,
+		COALESCE(unit, '') AS unit, COALESCE(original_value, 0) AS original_value, COALESCE(original_unit, '') AS original_unit,
+		observation_id, humidity, temperature, altitude, COALESCE(sensor_model, '') AS sensor_model,
+		COALESCE(firmware_version, '') AS firmware_version, tags, COALESCE(lateness, '') AS lateness
+	FROM air_quality_data
+	WHERE sensor_id IS NOT NULL`
+
+// thingProperties are the filterable and sortable properties of Things
+var thingProperties = map[string]sensorthings.Column{
+	"@iot.id":          {SQL: "id", Type: sensorthings.ColumnText},
+	"id":               {SQL: "id", Type: sensorthings.ColumnText},
+	"name":             {SQL: "name", Type: sensorthings.ColumnText},
+	"properties/class": {SQL: "class", Type: sensorthings.ColumnText},
+}
+
+// locationProperties are the filterable and sortable properties of Locations
+var locationProperties = map[string]sensorthings.Column{
+	"@iot.id": {SQL: "id", Type: sensorthings.ColumnText},
+	"id":      {SQL: "id", Type: sensorthings.ColumnText},
+	"name":    {SQL: "name", Type: sensorthings.ColumnText},
+}
+
+// datastreamProperties are the filterable and sortable properties of Datastreams
+var datastreamProperties = map[string]sensorthings.Column{
+	"@iot.id":               {SQL: "id", Type: sensorthings.ColumnText},
+	"id":                    {SQL: "id", Type: sensorthings.ColumnText},
+	"name":                  {SQL: "name", Type: sensorthings.ColumnText},
+	"properties/parameter":  {SQL: "parameter", Type: sensorthings.ColumnText},
+	"ObservedProperty/name": {SQL: "parameter", Type: sensorthings.ColumnText},
+	"Thing/@iot.id":         {SQL: "thing_id", Type: sensorthings.ColumnText},
+}
+
+// observationProperties are the filterable and sortable properties of Observations
+var observationProperties = map[string]sensorthings.Column{
+	"@iot.id":             {SQL: "iot_id", Type: sensorthings.ColumnText},
+	"id":                  {SQL: "iot_id", Type: sensorthings.ColumnText},
+	"phenomenonTime":      {SQL: "timestamp", Type: sensorthings.ColumnTime},
+	"resultTime":          {SQL: "timestamp", Type: sensorthings.ColumnTime},
+	"result":              {SQL: "value", Type: sensorthings.ColumnNumber},
+	"Datastream/@iot.id":  {SQL: "datastream_id", Type: sensorthings.ColumnText},
+	"parameters/lateness": {SQL: "lateness", Type: sensorthings.ColumnText},
+}
+
+// entityQuery is a SensorThings collection query over a base query that exposes the entity's columns
+type entityQuery struct {
+	base         string
+	columns      string
+	properties   map[string]sensorthings.Column
+	conditions   []string
+	args         []interface{}
+	defaultOrder string
+}
+
+// where adds a fixed condition with one argument, written with a %d placeholder for the argument number
+func (e *entityQuery) where(condition string, arg interface{}) {
+	e.args = append(e.args, arg)
+	e.conditions = append(e.conditions, fmt.Sprintf(condition, len(e.args)))
+}
+
+// run counts the matching entities if requested and scans the requested page
+func (db *DB) run(e *entityQuery, q *sensorthings.Query, scan func(pgx.Rows) error) (int64, error) {
+	conditions, args := e.conditions, e.args
+	if q.Filter != nil {
+		condition, filterArgs, err := sensorthings.CompileFilter(q.Filter, e.properties, append([]interface{}{}, args...))
+		if err != nil {
+			return 0, err
+		}
+		conditions = append(append([]string{}, conditions...), condition)
+		args = filterArgs
+	}
+
+	order, err := sensorthings.CompileOrderBy(q.OrderBy, e.properties)
+	if err != nil {
+		return 0, err
+	}
+	if order == "" {
+		order = e.defaultOrder
+	} else {
+		order += ", " + e.defaultOrder
+	}
+
+	from := "FROM (" + e.base + ") e"
+	if len(conditions) > 0 {
+		from += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var count int64
+	if q.Count {
+		if err := db.pool.QueryRow(ctx, "SELECT COUNT(*) "+from, args...).Scan(&count); err != nil {
+			return 0, fmt.Errorf("failed to count entities: %w", err)
+		}
+	}
+
+	pageArgs := append(append([]interface{}{}, args...), q.Top, q.Skip)
+	rows, err := db.pool.Query(ctx, fmt.Sprintf("SELECT %s %s ORDER BY %s LIMIT $%d OFFSET $%d",
+		e.columns, from, order, len(pageArgs)-1, len(pageArgs)), pageArgs...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query entities: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return 0, err
+		}
+	}
+	return count, rows.Err()
+}
+
+// ListThings lists sensors with readings; a non-empty id restricts the result to that sensor.
+// The count is only set when the query requests it.
+func (db *DB) ListThings(id string, q *sensorthings.Query) ([]sensorthings.ThingRecord, int64, error) {
+	return db.listThings(id, q, thingProperties)
+}
+
+// ListLocations lists the last reported locations of sensors; a location has the ID of its sensor
+func (db *DB) ListLocations(id string, q *sensorthings.Query) ([]sensorthings.ThingRecord, int64, error) {
+	return db.listThings(id, q, locationProperties)
+}
+
+// listThings lists sensors with readings, filtered and sorted by the given properties
+func (db *DB) listThings(id string, q *sensorthings.Query, properties map[string]sensorthings.Column) ([]sensorthings.ThingRecord, int64, error) {
+	e := &entityQuery{
+		base:         thingsBase,
+		columns:      "id, name, class, latitude, longitude, last_seen",
+		properties:   properties,
+		defaultOrder: "id",
+	}
+	if id != "" {
+		e.where("id = $%d", id)
+	}
+
+	results := []sensorthings.ThingRecord{}
+	count, err := db.run(e, q, func(rows pgx.Rows) error {
+		var r sensorthings.ThingRecord
+		if err := rows.Scan(&r.ID, &r.Name, &r.Class, &r.Latitude, &r.Longitude, &r.LastSeen); err != nil {
+			return err
+		}
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, count, nil
+}
+
+// ListDatastreams lists the parameters measured by sensors. A non-empty thingID restricts the result
+// to one sensor and a non-empty id to one datastream.
+func (db *DB) ListDatastreams(thingID, id string, q *sensorthings.Query) ([]sensorthings.DatastreamRecord, int64, error) {
+	e := &entityQuery{
+		base:         datastreamsBase,
+		columns:      "thing_id, parameter, phenomenon_start, phenomenon_end",
+		properties:   datastreamProperties,
+		defaultOrder: "id",
+	}
+	if thingID != "" {
+		e.where("thing_id = $%d", thingID)
+	}
+	if id != "" {
+		streamThing, parameter, ok := sensorthings.SplitDatastreamID(id)
+		if !ok {
+			return []sensorthings.DatastreamRecord{}, 0, nil
+		}
+		e.where("thing_id = $%d", streamThing)
+		e.where("parameter = $%d", parameter)
+	}
+
+	results := []sensorthings.DatastreamRecord{}
+	count, err := db.run(e, q, func(rows pgx.Rows) error {
+		var r sensorthings.DatastreamRecord
+		if err := rows.Scan(&r.ThingID, &r.Parameter, &r.Start, &r.End); err != nil {
+			return err
+		}
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, count, nil
+}
+
+// ListObservations lists readings of sensors, newest first by default. A non-empty datastreamID
+// restricts the result to one datastream and a non-empty id to one reading.
+func (db *DB) ListObservations(datastreamID, id string, q *sensorthings.Query) ([]models.AirQualityData, int64, error) {
+	e := &entityQuery{
+		base: observationsBase,
+		columns: "id, sensor_id, parameter, value, timestamp, unit, original_value, original_unit, observation_id, " +
+			"humidity, temperature, altitude, sensor_model, firmware_version, tags, lateness",
+		properties:   observationProperties,
+		defaultOrder: "timestamp DESC, id",
+	}
+	if datastreamID != "" {
+		thingID, parameter, ok := sensorthings.SplitDatastreamID(datastreamID)
+		if !ok {
+			return []models.AirQualityData{}, 0, nil
+		}
+		e.where("sensor_id = $%d", thingID)
+		e.where("parameter = $%d", parameter)
+	}
+	if id != "" {
+		readingID, err := uuid.Parse(id)
+		if err != nil {
+			return []models.AirQualityData{}, 0, nil
+		}
+		e.where("id = $%d", readingID)
+	}
+
+	results := []models.AirQualityData{}
+	count, err := db.run(e, q, func(rows pgx.Rows) error {
+		var data models.AirQualityData
+		if err := rows.Scan(&data.ID, &data.SensorID, &data.Parameter, &data.Value, &data.Timestamp,
+			&data.Unit, &data.OriginalValue, &data.OriginalUnit, &data.ObservationID,
+			&data.Humidity, &data.Temperature, &data.Altitude, &data.SensorModel, &data.FirmwareVersion, &data.Tags,
+			&data.Lateness); err != nil {
+			return err
+		}
+		results = append(results, data)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, count, nil
+}
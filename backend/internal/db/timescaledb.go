@@ -2,13 +2,23 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/user/airpollution/internal/models"
 )
 
+// ErrReadingIDReused is returned for a sensor reading whose ID is already stored for another reading
+// at the same timestamp. The ID collides with the primary key, which the upsert on the natural key
+// does not cover.
+var ErrReadingIDReused = errors.New("reading ID is already used by another reading")
+
 // DB represents the database connection
 type DB struct {
 	pool *pgxpool.Pool
@@ -42,11 +52,24 @@ func (db *DB) InitSchema() error {
 	_, err := db.pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS air_quality_data (
 			id UUID,
+			sensor_id TEXT,
 			latitude FLOAT NOT NULL,
 			longitude FLOAT NOT NULL,
 			parameter TEXT NOT NULL,
 			value FLOAT NOT NULL,
 			timestamp TIMESTAMPTZ NOT NULL,
+			unit TEXT,
+			original_value FLOAT,
+			original_unit TEXT,
+			signature_status TEXT,
+			observation_id UUID,
+			humidity FLOAT,
+			temperature FLOAT,
+			altitude FLOAT,
+			sensor_model TEXT,
+			firmware_version TEXT,
+			tags JSONB,
+			lateness TEXT,
 			PRIMARY KEY (id, timestamp)
 		);
 	`)
@@ -54,6 +77,26 @@ func (db *DB) InitSchema() error {
 		return fmt.Errorf("failed to create air_quality_data table: %w", err)
 	}
 
+	// Add columns to tables created by earlier versions
+	_, err = db.pool.Exec(ctx, `
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS sensor_id TEXT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS signature_status TEXT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS unit TEXT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS original_value FLOAT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS original_unit TEXT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS observation_id UUID;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS humidity FLOAT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS temperature FLOAT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS altitude FLOAT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS sensor_model TEXT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS firmware_version TEXT;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS tags JSONB;
+		ALTER TABLE air_quality_data ADD COLUMN IF NOT EXISTS lateness TEXT;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add air_quality_data columns: %w", err)
+	}
+
 	// Convert to TimescaleDB hypertable
 	_, err = db.pool.Exec(ctx, `
 		SELECT create_hypertable('air_quality_data', 'timestamp', if_not_exists => TRUE);
@@ -62,6 +105,22 @@ func (db *DB) InitSchema() error {
 		return fmt.Errorf("failed to create hypertable: %w", err)
 	}
 
+	// Natural key used to deduplicate sensor readings
+	_, err = db.pool.Exec(ctx, `
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_air_quality_natural_key ON air_quality_data (sensor_id, parameter, timestamp);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create natural key index: %w", err)
+	}
+
+	// Readings of the same multi-parameter observation
+	_, err = db.pool.Exec(ctx, `
+		CREATE INDEX IF NOT EXISTS idx_air_quality_observation ON air_quality_data (observation_id, timestamp);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create observation index: %w", err)
+	}
+
 	// Create anomalies table
 	_, err = db.pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS anomalies (
@@ -74,6 +133,8 @@ func (db *DB) InitSchema() error {
 			detected_at TIMESTAMPTZ NOT NULL,
 			air_quality_data_id UUID,
 			air_quality_data_timestamp TIMESTAMPTZ,
+			published_at TIMESTAMPTZ,
+			averaging_period TEXT,
 			FOREIGN KEY (air_quality_data_id, air_quality_data_timestamp) REFERENCES air_quality_data(id, timestamp),
 			PRIMARY KEY (id, detected_at)
 		);
@@ -82,6 +143,64 @@ func (db *DB) InitSchema() error {
 		return fmt.Errorf("failed to create anomalies table: %w", err)
 	}
 
+	_, err = db.pool.Exec(ctx, `
+		ALTER TABLE anomalies ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+		ALTER TABLE anomalies ADD COLUMN IF NOT EXISTS averaging_period TEXT;
+		CREATE INDEX IF NOT EXISTS idx_anomalies_reading ON anomalies (air_quality_data_id);
+		CREATE INDEX IF NOT EXISTS idx_anomalies_id ON anomalies (id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add anomalies columns: %w", err)
+	}
+
+	// Create sensors table
+	_, err = db.pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS sensors (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			api_key_hash TEXT NOT NULL UNIQUE,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			class TEXT NOT NULL DEFAULT 'default',
+			signing_secret TEXT,
+			require_signature BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create sensors table: %w", err)
+	}
+
+	_, err = db.pool.Exec(ctx, `
+		ALTER TABLE sensors ADD COLUMN IF NOT EXISTS signing_secret TEXT;
+		ALTER TABLE sensors ADD COLUMN IF NOT EXISTS require_signature BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE sensors ADD COLUMN IF NOT EXISTS class TEXT NOT NULL DEFAULT 'default';
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add sensors columns: %w", err)
+	}
+
+	// Create current_aqi table, the latest index of each location on each scale
+	_, err = db.pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS current_aqi (
+			location TEXT NOT NULL,
+			scale TEXT NOT NULL,
+			latitude FLOAT NOT NULL,
+			longitude FLOAT NOT NULL,
+			value INTEGER NOT NULL,
+			category TEXT NOT NULL,
+			color TEXT NOT NULL,
+			dominant_pollutant TEXT NOT NULL,
+			pollutants JSONB NOT NULL,
+			observed_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (location, scale)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create current_aqi table: %w", err)
+	}
+
 	return nil
 }
 
@@ -90,33 +209,96 @@ func (db *DB) Close() {
 	db.pool.Close()
 }
 
-// InsertAirQualityData inserts a new air quality data point
-func (db *DB) InsertAirQualityData(data *models.AirQualityData) error {
+// InsertAirQualityData upserts an air quality data point and reports whether it was newly inserted.
+// Readings from a sensor are deduplicated on the natural key (sensor, parameter, timestamp) and
+// anonymous readings on their ID. On conflict the stored row keeps its ID, which is copied back to
+// data so that anomalies reference the stored reading.
+func (db *DB) InsertAirQualityData(data *models.AirQualityData) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := db.pool.Exec(ctx, `
-		INSERT INTO air_quality_data (id, latitude, longitude, parameter, value, timestamp)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, data.ID, data.Latitude, data.Longitude, data.Parameter, data.Value, data.Timestamp)
+	return db.InsertAirQualityDataContext(ctx, data)
+}
+
+// InsertAirQualityDataContext is InsertAirQualityData bounded by ctx instead of its own timeout
+func (db *DB) InsertAirQualityDataContext(ctx context.Context, data *models.AirQualityData) (bool, error) {
+	conflictTarget := "(id, timestamp)"
+	if data.SensorID != "" {
+		conflictTarget = "(sensor_id, parameter, timestamp)"
+	}
 
+	tags, err := encodeTags(data.Tags)
 	if err != nil {
-		return fmt.Errorf("failed to insert air quality data: %w", err)
+		return false, err
 	}
 
-	return nil
+	var inserted bool
+	err = db.pool.QueryRow(ctx, `
+		INSERT INTO air_quality_data (id, sensor_id, latitude, longitude, parameter, value, timestamp,
+			unit, original_value, original_unit, signature_status, observation_id,
+			humidity, temperature, altitude, sensor_model, firmware_version, tags, lateness)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, NULLIF($8, ''), $9, NULLIF($10, ''), NULLIF($11, ''), $12,
+			$13, $14, $15, NULLIF($16, ''), NULLIF($17, ''), $18, NULLIF($19, ''))
+		ON CONFLICT `+conflictTarget+` DO UPDATE SET
+			latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude,
+			value = EXCLUDED.value,
+			unit = EXCLUDED.unit,
+			original_value = EXCLUDED.original_value,
+			original_unit = EXCLUDED.original_unit,
+			signature_status = EXCLUDED.signature_status,
+			observation_id = EXCLUDED.observation_id,
+			humidity = EXCLUDED.humidity,
+			temperature = EXCLUDED.temperature,
+			altitude = EXCLUDED.altitude,
+			sensor_model = EXCLUDED.sensor_model,
+			firmware_version = EXCLUDED.firmware_version,
+			tags = EXCLUDED.tags,
+			lateness = EXCLUDED.lateness
+		RETURNING id, (xmax = 0)
+	`, data.ID, data.SensorID, data.Latitude, data.Longitude, data.Parameter, data.Value, data.Timestamp,
+		data.Unit, data.OriginalValue, data.OriginalUnit, data.SignatureStatus, data.ObservationID,
+		data.Humidity, data.Temperature, data.Altitude, data.SensorModel, data.FirmwareVersion, tags, data.Lateness).
+		Scan(&data.ID, &inserted)
+
+	if isReadingIDConflict(err) {
+		return false, fmt.Errorf("failed to insert air quality data %s: %w", data.ID, ErrReadingIDReused)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to insert air quality data: %w", err)
+	}
+
+	return inserted, nil
 }
 
+// encodeTags encodes reading tags as JSON, or NULL when there are none
+func encodeTags(tags map[string]string) (interface{}, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tags: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// insertAnomalySQL inserts an anomaly unless one with its ID is already stored. Anomalies detected
+// again for a redelivered reading have the same ID but a later detection time, so the primary key
+// alone does not catch them.
+const insertAnomalySQL = `
+	INSERT INTO anomalies (id, type, parameter, value, latitude, longitude, detected_at, air_quality_data_id, air_quality_data_timestamp, averaging_period)
+	SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')
+	WHERE NOT EXISTS (SELECT 1 FROM anomalies WHERE id = $1)
+`
+
 // InsertAnomaly inserts a new anomaly
 func (db *DB) InsertAnomaly(anomaly *models.Anomaly) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := db.pool.Exec(ctx, `
-		INSERT INTO anomalies (id, type, parameter, value, latitude, longitude, detected_at, air_quality_data_id, air_quality_data_timestamp)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, anomaly.ID, anomaly.Type, anomaly.Parameter, anomaly.Value, anomaly.Latitude, anomaly.Longitude, anomaly.DetectedAt, anomaly.AirQualityDataID, anomaly.AirQualityDataTimestamp)
-
+	_, err := db.pool.Exec(ctx, insertAnomalySQL, anomalyArgs(anomaly)...)
 	if err != nil {
 		return fmt.Errorf("failed to insert anomaly: %w", err)
 	}
@@ -124,6 +306,92 @@ func (db *DB) InsertAnomaly(anomaly *models.Anomaly) error {
 	return nil
 }
 
+// InsertAnomalies inserts the anomalies of a reading in one transaction, so that either all of
+// them are stored or none
+func (db *DB) InsertAnomalies(anomalies []*models.Anomaly) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return db.InsertAnomaliesContext(ctx, anomalies)
+}
+
+// InsertAnomaliesContext is InsertAnomalies bounded by ctx instead of its own timeout
+func (db *DB) InsertAnomaliesContext(ctx context.Context, anomalies []*models.Anomaly) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, anomaly := range anomalies {
+		if _, err := tx.Exec(ctx, insertAnomalySQL, anomalyArgs(anomaly)...); err != nil {
+			return fmt.Errorf("failed to insert anomaly: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit anomalies: %w", err)
+	}
+
+	return nil
+}
+
+// anomalyArgs returns the arguments of insertAnomalySQL
+func anomalyArgs(anomaly *models.Anomaly) []interface{} {
+	return []interface{}{anomaly.ID, anomaly.Type, anomaly.Parameter, anomaly.Value, anomaly.Latitude, anomaly.Longitude,
+		anomaly.DetectedAt, anomaly.AirQualityDataID, anomaly.AirQualityDataTimestamp, anomaly.AveragingPeriod}
+}
+
+// GetAnomaliesForReading gets the stored anomalies of a reading
+func (db *DB) GetAnomaliesForReading(readingID uuid.UUID, readingTimestamp time.Time) ([]models.Anomaly, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := db.pool.Query(ctx, `
+		SELECT id, type, parameter, value, latitude, longitude, detected_at, air_quality_data_id, air_quality_data_timestamp, published_at,
+			COALESCE(averaging_period, '')
+		FROM anomalies
+		WHERE air_quality_data_id = $1 AND air_quality_data_timestamp = $2
+		ORDER BY detected_at
+	`, readingID, readingTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomalies of reading: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.Anomaly
+	for rows.Next() {
+		var anomaly models.Anomaly
+		if err := rows.Scan(&anomaly.ID, &anomaly.Type, &anomaly.Parameter, &anomaly.Value, &anomaly.Latitude, &anomaly.Longitude,
+			&anomaly.DetectedAt, &anomaly.AirQualityDataID, &anomaly.AirQualityDataTimestamp, &anomaly.PublishedAt, &anomaly.AveragingPeriod); err != nil {
+			return nil, err
+		}
+		results = append(results, anomaly)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// MarkAnomalyPublished records that an anomaly alert was published
+func (db *DB) MarkAnomalyPublished(anomaly *models.Anomaly) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := db.pool.Exec(ctx, `
+		UPDATE anomalies SET published_at = NOW()
+		WHERE id = $1 AND detected_at = $2
+	`, anomaly.ID, anomaly.DetectedAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark anomaly as published: %w", err)
+	}
+
+	return nil
+}
+
 // GetRecentDataForParameter gets the recent data for a specific parameter in a location
 func (db *DB) GetRecentDataForParameter(parameter string, latitude, longitude float64, hours int) ([]models.AirQualityData, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -134,7 +402,9 @@ func (db *DB) GetRecentDataForParameter(parameter string, latitude, longitude fl
 	lonDelta := 0.25
 
 	rows, err := db.pool.Query(ctx, `
-		SELECT id, latitude, longitude, parameter, value, timestamp
+		SELECT id, COALESCE(sensor_id, ''), latitude, longitude, parameter, value, timestamp, observation_id,
+			humidity, temperature, altitude, COALESCE(sensor_model, ''), COALESCE(firmware_version, ''), tags,
+			COALESCE(lateness, '')
 		FROM air_quality_data
 		WHERE parameter = $1
 		AND latitude BETWEEN $2 - $3 AND $2 + $3
@@ -151,7 +421,9 @@ func (db *DB) GetRecentDataForParameter(parameter string, latitude, longitude fl
 	var results []models.AirQualityData
 	for rows.Next() {
 		var data models.AirQualityData
-		if err := rows.Scan(&data.ID, &data.Latitude, &data.Longitude, &data.Parameter, &data.Value, &data.Timestamp); err != nil {
+		if err := rows.Scan(&data.ID, &data.SensorID, &data.Latitude, &data.Longitude, &data.Parameter, &data.Value, &data.Timestamp, &data.ObservationID,
+			&data.Humidity, &data.Temperature, &data.Altitude, &data.SensorModel, &data.FirmwareVersion, &data.Tags,
+			&data.Lateness); err != nil {
 			return nil, err
 		}
 		results = append(results, data)
@@ -164,13 +436,39 @@ func (db *DB) GetRecentDataForParameter(parameter string, latitude, longitude fl
 	return results, nil
 }
 
+// ForEachReadingSince streams the sensor, parameter, location, value and timestamp of every
+// reading since a time, in timestamp order, without loading them all into memory
+func (db *DB) ForEachReadingSince(ctx context.Context, since time.Time, fn func(*models.AirQualityData)) error {
+	rows, err := db.pool.Query(ctx, `
+		SELECT COALESCE(sensor_id, ''), parameter, latitude, longitude, value, timestamp
+		FROM air_quality_data
+		WHERE timestamp > $1
+		ORDER BY timestamp
+	`, since)
+	if err != nil {
+		return fmt.Errorf("failed to query readings: %w", err)
+	}
+	defer rows.Close()
+
+	var data models.AirQualityData
+	for rows.Next() {
+		if err := rows.Scan(&data.SensorID, &data.Parameter, &data.Latitude, &data.Longitude, &data.Value, &data.Timestamp); err != nil {
+			return err
+		}
+		fn(&data)
+	}
+
+	return rows.Err()
+}
+
 // GetRecentAnomalies gets recent anomalies
 func (db *DB) GetRecentAnomalies(hours int) ([]models.Anomaly, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	rows, err := db.pool.Query(ctx, `
-		SELECT id, type, parameter, value, latitude, longitude, detected_at, air_quality_data_id, air_quality_data_timestamp
+		SELECT id, type, parameter, value, latitude, longitude, detected_at, air_quality_data_id, air_quality_data_timestamp,
+			COALESCE(averaging_period, '')
 		FROM anomalies
 		WHERE detected_at > NOW() - INTERVAL '$1 hours'
 		ORDER BY detected_at DESC
@@ -186,7 +484,7 @@ func (db *DB) GetRecentAnomalies(hours int) ([]models.Anomaly, error) {
 		var anomaly models.Anomaly
 		if err := rows.Scan(&anomaly.ID, &anomaly.Type, &anomaly.Parameter, &anomaly.Value,
 			&anomaly.Latitude, &anomaly.Longitude, &anomaly.DetectedAt,
-			&anomaly.AirQualityDataID, &anomaly.AirQualityDataTimestamp); err != nil {
+			&anomaly.AirQualityDataID, &anomaly.AirQualityDataTimestamp, &anomaly.AveragingPeriod); err != nil {
 			return nil, err
 		}
 		results = append(results, anomaly)
@@ -198,3 +496,28 @@ func (db *DB) GetRecentAnomalies(hours int) ([]models.Anomaly, error) {
 
 	return results, nil
 }
+
+// isReadingIDConflict reports whether a write violated the primary key of air_quality_data. The
+// constraints of hypertable chunks are named after the constraint of the table with a chunk prefix.
+func isReadingIDConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "23505" && strings.HasSuffix(pgErr.ConstraintName, "air_quality_data_pkey")
+}
+
+// IsPermanent reports whether a write failed because the database rejected the row itself, such
+// as a value out of range or a violated constraint, so that retrying the same row will keep failing.
+// Connection errors and timeouts are not permanent.
+func IsPermanent(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code[:2] {
+	case "22", "23": // Data exception, integrity constraint violation
+		return true
+	}
+	return false
+}
@@ -0,0 +1,5 @@
+// Package ingestpb contains the generated protobuf and gRPC code of the ingest service.
+// The schema is in proto/ingest/v1/ingest.proto.
+package ingestpb
+
+//go:generate protoc -I ../../../proto --go_out=. --go_opt=module=github.com/user/airpollution/internal/grpc/ingestpb --go-grpc_out=. --go-grpc_opt=module=github.com/user/airpollution/internal/grpc/ingestpb ingest/v1/ingest.proto
@@ -0,0 +1,199 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.27.1
+// source: ingest/v1/ingest.proto
+
+package ingestpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	IngestService_SubmitReading_FullMethodName  = "/airpollution.ingest.v1.IngestService/SubmitReading"
+	IngestService_UploadReadings_FullMethodName = "/airpollution.ingest.v1.IngestService/UploadReadings"
+	IngestService_StreamReadings_FullMethodName = "/airpollution.ingest.v1.IngestService/StreamReadings"
+)
+
+// IngestServiceClient is the client API for IngestService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// IngestService accepts air quality readings over gRPC. Readings go through the same
+// validation, normalization and Kafka path as the HTTP ingest API.
+type IngestServiceClient interface {
+	// SubmitReading submits a single reading
+	SubmitReading(ctx context.Context, in *Reading, opts ...grpc.CallOption) (*ReadingResult, error)
+	// UploadReadings accepts a client stream of readings and returns a summary when the client closes the stream
+	UploadReadings(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Reading, UploadSummary], error)
+	// StreamReadings accepts a continuous stream of readings and acknowledges them in batches.
+	// The server does not read more than Ack.window readings past the last acknowledged one.
+	StreamReadings(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Reading, Ack], error)
+}
+
+type ingestServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIngestServiceClient(cc grpc.ClientConnInterface) IngestServiceClient {
+	return &ingestServiceClient{cc}
+}
+
+func (c *ingestServiceClient) SubmitReading(ctx context.Context, in *Reading, opts ...grpc.CallOption) (*ReadingResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReadingResult)
+	err := c.cc.Invoke(ctx, IngestService_SubmitReading_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ingestServiceClient) UploadReadings(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Reading, UploadSummary], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IngestService_ServiceDesc.Streams[0], IngestService_UploadReadings_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Reading, UploadSummary]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IngestService_UploadReadingsClient = grpc.ClientStreamingClient[Reading, UploadSummary]
+
+func (c *ingestServiceClient) StreamReadings(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Reading, Ack], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IngestService_ServiceDesc.Streams[1], IngestService_StreamReadings_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Reading, Ack]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IngestService_StreamReadingsClient = grpc.BidiStreamingClient[Reading, Ack]
+
+// IngestServiceServer is the server API for IngestService service.
+// All implementations must embed UnimplementedIngestServiceServer
+// for forward compatibility.
+//
+// IngestService accepts air quality readings over gRPC. Readings go through the same
+// validation, normalization and Kafka path as the HTTP ingest API.
+type IngestServiceServer interface {
+	// SubmitReading submits a single reading
+	SubmitReading(context.Context, *Reading) (*ReadingResult, error)
+	// UploadReadings accepts a client stream of readings and returns a summary when the client closes the stream
+	UploadReadings(grpc.ClientStreamingServer[Reading, UploadSummary]) error
+	// StreamReadings accepts a continuous stream of readings and acknowledges them in batches.
+	// The server does not read more than Ack.window readings past the last acknowledged one.
+	StreamReadings(grpc.BidiStreamingServer[Reading, Ack]) error
+	mustEmbedUnimplementedIngestServiceServer()
+}
+
+// UnimplementedIngestServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIngestServiceServer struct{}
+
+func (UnimplementedIngestServiceServer) SubmitReading(context.Context, *Reading) (*ReadingResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitReading not implemented")
+}
+func (UnimplementedIngestServiceServer) UploadReadings(grpc.ClientStreamingServer[Reading, UploadSummary]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadReadings not implemented")
+}
+func (UnimplementedIngestServiceServer) StreamReadings(grpc.BidiStreamingServer[Reading, Ack]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamReadings not implemented")
+}
+func (UnimplementedIngestServiceServer) mustEmbedUnimplementedIngestServiceServer() {}
+func (UnimplementedIngestServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeIngestServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IngestServiceServer will
+// result in compilation errors.
+type UnsafeIngestServiceServer interface {
+	mustEmbedUnimplementedIngestServiceServer()
+}
+
+func RegisterIngestServiceServer(s grpc.ServiceRegistrar, srv IngestServiceServer) {
+	// If the following call pancis, it indicates UnimplementedIngestServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&IngestService_ServiceDesc, srv)
+}
+
+func _IngestService_SubmitReading_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Reading)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IngestServiceServer).SubmitReading(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IngestService_SubmitReading_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IngestServiceServer).SubmitReading(ctx, req.(*Reading))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IngestService_UploadReadings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IngestServiceServer).UploadReadings(&grpc.GenericServerStream[Reading, UploadSummary]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IngestService_UploadReadingsServer = grpc.ClientStreamingServer[Reading, UploadSummary]
+
+func _IngestService_StreamReadings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IngestServiceServer).StreamReadings(&grpc.GenericServerStream[Reading, Ack]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IngestService_StreamReadingsServer = grpc.BidiStreamingServer[Reading, Ack]
+
+// IngestService_ServiceDesc is the grpc.ServiceDesc for IngestService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IngestService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "airpollution.ingest.v1.IngestService",
+	HandlerType: (*IngestServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitReading",
+			Handler:    _IngestService_SubmitReading_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadReadings",
+			Handler:       _IngestService_UploadReadings_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StreamReadings",
+			Handler:       _IngestService_StreamReadings_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "ingest/v1/ingest.proto",
+}
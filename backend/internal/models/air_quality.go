@@ -9,24 +9,66 @@ import (
 // AirQualityData represents a data point for air quality
 type AirQualityData struct {
 	ID        uuid.UUID `json:"id" db:"id"`
+	SensorID  string    `json:"sensor_id,omitempty" db:"sensor_id"`
 	Latitude  float64   `json:"latitude" db:"latitude"`
 	Longitude float64   `json:"longitude" db:"longitude"`
 	Parameter string    `json:"parameter" db:"parameter"`
 	Value     float64   `json:"value" db:"value"`
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+	// Unit is the canonical unit of Value; OriginalValue and OriginalUnit keep what the sensor reported
+	Unit          string  `json:"unit,omitempty" db:"unit"`
+	OriginalValue float64 `json:"original_value,omitempty" db:"original_value"`
+	OriginalUnit  string  `json:"original_unit,omitempty" db:"original_unit"`
+	// SignatureStatus records whether the submission was signed by the sensor
+	SignatureStatus string `json:"signature_status,omitempty" db:"signature_status"`
+	// ObservationID links readings of different parameters measured at the same location and instant
+	ObservationID *uuid.UUID `json:"observation_id,omitempty" db:"observation_id"`
+	// Lateness records whether the reading arrived on time, later than the timestamp policy allows,
+	// or after a newer reading of its sensor
+	Lateness string `json:"lateness,omitempty" db:"lateness"`
+	ReadingMetadata
 }
 
+// ReadingMetadata holds optional meteorological and device metadata reported with a reading
+type ReadingMetadata struct {
+	// Humidity is the relative humidity in percent, used to correct low-cost PM sensors
+	Humidity *float64 `json:"humidity,omitempty" db:"humidity"`
+	// Temperature is the air temperature in °C
+	Temperature *float64 `json:"temperature,omitempty" db:"temperature"`
+	// Altitude is the height above sea level in meters
+	Altitude        *float64          `json:"altitude,omitempty" db:"altitude"`
+	SensorModel     string            `json:"sensor_model,omitempty" db:"sensor_model"`
+	FirmwareVersion string            `json:"firmware_version,omitempty" db:"firmware_version"`
+	Tags            map[string]string `json:"tags,omitempty" db:"tags"`
+}
+
+// Signature verification outcomes recorded with a reading
+const (
+	SignatureVerified = "verified"
+	SignatureUnsigned = "unsigned"
+)
+
+// Lateness classifications recorded with a reading
+const (
+	LatenessOnTime     = "on_time"
+	LatenessLate       = "late"
+	LatenessOutOfOrder = "out_of_order"
+)
+
 // Anomaly represents an anomaly in air quality data
 type Anomaly struct {
-	ID                      uuid.UUID `json:"id" db:"id"`
-	Type                    string    `json:"type" db:"type"`
-	Parameter               string    `json:"parameter" db:"parameter"`
-	Value                   float64   `json:"value" db:"value"`
-	Latitude                float64   `json:"latitude" db:"latitude"`
-	Longitude               float64   `json:"longitude" db:"longitude"`
-	DetectedAt              time.Time `json:"detected_at" db:"detected_at"`
-	AirQualityDataID        uuid.UUID `json:"air_quality_data_id,omitempty" db:"air_quality_data_id"`
-	AirQualityDataTimestamp time.Time `json:"air_quality_data_timestamp,omitempty" db:"air_quality_data_timestamp"`
+	ID                      uuid.UUID  `json:"id" db:"id"`
+	Type                    string     `json:"type" db:"type"`
+	Parameter               string     `json:"parameter" db:"parameter"`
+	Value                   float64    `json:"value" db:"value"`
+	Latitude                float64    `json:"latitude" db:"latitude"`
+	Longitude               float64    `json:"longitude" db:"longitude"`
+	DetectedAt              time.Time  `json:"detected_at" db:"detected_at"`
+	AirQualityDataID        uuid.UUID  `json:"air_quality_data_id,omitempty" db:"air_quality_data_id"`
+	AirQualityDataTimestamp time.Time  `json:"air_quality_data_timestamp,omitempty" db:"air_quality_data_timestamp"`
+	PublishedAt             *time.Time `json:"published_at,omitempty" db:"published_at"`
+	// AveragingPeriod is set for anomalies of a mean, such as "24h"; Value is then the mean
+	AveragingPeriod string `json:"averaging_period,omitempty" db:"averaging_period"`
 }
 
 // AnomalyType represents the type of anomaly detected
@@ -34,6 +76,7 @@ type AnomalyType string
 
 const (
 	ThresholdExceeded       AnomalyType = "ThresholdExceeded"
+	AlertLevelExceeded      AnomalyType = "AlertLevelExceeded"
 	StatisticalOutlier      AnomalyType = "StatisticalOutlier"
 	SpikeDetected           AnomalyType = "SpikeDetected"
 	GeographicInconsistency AnomalyType = "GeographicInconsistency"
@@ -46,12 +89,19 @@ type AnomalyAlert struct {
 	Type      string    `json:"type"`
 	Location  []float64 `json:"location"` // [latitude, longitude]
 	Timestamp time.Time `json:"timestamp"`
+	// AveragingPeriod is set when Value is a mean over the period
+	AveragingPeriod string `json:"averaging_period,omitempty"`
 }
 
 // NewAirQualityData creates a new air quality data point
 func NewAirQualityData(latitude, longitude float64, parameter string, value float64, timestamp time.Time) *AirQualityData {
+	return NewAirQualityDataWithID(uuid.New(), latitude, longitude, parameter, value, timestamp)
+}
+
+// NewAirQualityDataWithID creates a new air quality data point with a given ID, such as one supplied by the client
+func NewAirQualityDataWithID(id uuid.UUID, latitude, longitude float64, parameter string, value float64, timestamp time.Time) *AirQualityData {
 	return &AirQualityData{
-		ID:        uuid.New(),
+		ID:        id,
 		Latitude:  latitude,
 		Longitude: longitude,
 		Parameter: parameter,
@@ -74,10 +124,11 @@ func NewAnomaly(anomalyType string, parameter string, value, latitude, longitude
 	}
 }
 
-// NewAnomalyFromData creates a new anomaly from air quality data
+// NewAnomalyFromData creates a new anomaly from air quality data. The ID is derived from the
+// reading and the anomaly type, so detecting the same anomaly again yields the same ID.
 func NewAnomalyFromData(anomalyType string, data *AirQualityData) *Anomaly {
 	return &Anomaly{
-		ID:                      uuid.New(),
+		ID:                      uuid.NewSHA1(data.ID, []byte(anomalyType)),
 		Type:                    anomalyType,
 		Parameter:               data.Parameter,
 		Value:                   data.Value,
@@ -89,13 +140,24 @@ func NewAnomalyFromData(anomalyType string, data *AirQualityData) *Anomaly {
 	}
 }
 
+// NewAveragedAnomalyFromData creates a new anomaly of the mean over an averaging period ending
+// with a reading. The ID is derived from the reading, the anomaly type and the period.
+func NewAveragedAnomalyFromData(anomalyType string, data *AirQualityData, period string, mean float64) *Anomaly {
+	a := NewAnomalyFromData(anomalyType, data)
+	a.ID = uuid.NewSHA1(data.ID, []byte(anomalyType+"/"+period))
+	a.Value = mean
+	a.AveragingPeriod = period
+	return a
+}
+
 // ToAnomalyAlert converts an Anomaly to an AnomalyAlert
 func (a *Anomaly) ToAnomalyAlert() *AnomalyAlert {
 	return &AnomalyAlert{
-		Parameter: a.Parameter,
-		Value:     a.Value,
-		Type:      a.Type,
-		Location:  []float64{a.Latitude, a.Longitude},
-		Timestamp: a.DetectedAt,
+		Parameter:       a.Parameter,
+		Value:           a.Value,
+		Type:            a.Type,
+		Location:        []float64{a.Latitude, a.Longitude},
+		Timestamp:       a.DetectedAt,
+		AveragingPeriod: a.AveragingPeriod,
 	}
 }
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// AirQualityIndex is the air quality index of a location on one national scale
+type AirQualityIndex struct {
+	// Location is the sensor ID of the location, or its rounded coordinates for readings without one
+	Location  string  `json:"location" db:"location"`
+	Scale     string  `json:"scale" db:"scale"`
+	Latitude  float64 `json:"latitude" db:"latitude"`
+	Longitude float64 `json:"longitude" db:"longitude"`
+	Value     int     `json:"value" db:"value"`
+	Category  string  `json:"category" db:"category"`
+	// Color is the color of the category defined by the scale, as #RRGGBB
+	Color string `json:"color" db:"color"`
+	// DominantPollutant is the parameter with the highest sub-index
+	DominantPollutant string           `json:"dominant_pollutant" db:"dominant_pollutant"`
+	Pollutants        []PollutantIndex `json:"pollutants" db:"pollutants"`
+	// ObservedAt is the timestamp of the reading that completed the averaging windows
+	ObservedAt time.Time `json:"observed_at" db:"observed_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PollutantIndex is the sub-index of one pollutant, from its mean over the averaging period of the scale
+type PollutantIndex struct {
+	Parameter       string  `json:"parameter"`
+	AveragingPeriod string  `json:"averaging_period"`
+	Concentration   float64 `json:"concentration"`
+	// Unit is the unit of Concentration, the unit in which the scale states its breakpoints
+	Unit  string `json:"unit"`
+	Index int    `json:"index"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Sensor represents a registered device that is allowed to submit readings
+type Sensor struct {
+	ID         string `json:"id" db:"id"`
+	Name       string `json:"name" db:"name"`
+	APIKeyHash string `json:"-" db:"api_key_hash"`
+	Enabled    bool   `json:"enabled" db:"enabled"`
+	// Class selects the rate limit of the sensor, such as "default" or "reference"
+	Class string `json:"class" db:"class"`
+	// SigningSecret is the shared HMAC secret; it must be stored in plain text to verify signatures
+	SigningSecret    string    `json:"-" db:"signing_secret"`
+	RequireSignature bool      `json:"require_signature" db:"require_signature"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// DefaultSensorClass is the class of sensors registered without one
+const DefaultSensorClass = "default"
+
+// NewSensor creates a new enabled sensor
+func NewSensor(id, name, apiKeyHash string) *Sensor {
+	now := time.Now()
+	return &Sensor{
+		ID:         id,
+		Name:       name,
+		APIKeyHash: apiKeyHash,
+		Enabled:    true,
+		Class:      DefaultSensorClass,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
@@ -0,0 +1,412 @@
+package anomaly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/airpollution/internal/services/averaging"
+	"github.com/user/airpollution/internal/services/parameters"
+	"github.com/user/airpollution/internal/services/window"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the statistical rules
+const (
+	DefaultZScore           = 3.0
+	DefaultSpikeFactor      = 1.5
+	DefaultGeographicFactor = 3.0
+	DefaultMinOutlierCount  = 10
+	DefaultMinSpikeCount    = 5
+	DefaultMinNearbyCount   = 3
+)
+
+// maxNeighborhoodCells is the largest radius in window cells. Each reading is kept in the windows
+// of (2r+1)² cells, so smaller cells quickly cost more memory than they improve the fit.
+const maxNeighborhoodCells = 10
+
+// Thresholds are the settings the built-in rules apply to one reading
+type Thresholds struct {
+	// AlertLevel is the value above which a single reading raises an alert, nil when the parameter has none
+	AlertLevel *float64
+	// Limit1h, Limit8h and Limit24h are the limits of the means over averaging periods, nil when
+	// the parameter has no limit for the period
+	Limit1h  *float64
+	Limit8h  *float64
+	Limit24h *float64
+	// Averaging is the data completeness a mean needs to be compared with its limit
+	Averaging averaging.Requirement
+	// ZScore is the distance from the mean, in standard deviations, beyond which a reading is an outlier
+	ZScore float64
+	// SpikeFactor is the multiple of the mean above which a reading is a spike
+	SpikeFactor float64
+	// GeographicFactor is how many times above or below the median of nearby readings a reading may be
+	GeographicFactor float64
+	// MinOutlierCount, MinSpikeCount and MinNearbyCount are the recent readings the statistical rules need
+	MinOutlierCount int
+	MinSpikeCount   int
+	MinNearbyCount  int
+}
+
+// Overrides changes some of the thresholds; unset fields keep the value they override
+type Overrides struct {
+	AlertLevel       *float64 `json:"alert_level,omitempty" yaml:"alert_level,omitempty"`
+	Limit1h          *float64 `json:"limit_1h,omitempty" yaml:"limit_1h,omitempty"`
+	Limit8h          *float64 `json:"limit_8h,omitempty" yaml:"limit_8h,omitempty"`
+	Limit24h         *float64 `json:"limit_24h,omitempty" yaml:"limit_24h,omitempty"`
+	MinCompleteness  *float64 `json:"min_completeness,omitempty" yaml:"min_completeness,omitempty"`
+	MinHourReadings  *int     `json:"min_hour_readings,omitempty" yaml:"min_hour_readings,omitempty"`
+	ZScore           *float64 `json:"zscore,omitempty" yaml:"zscore,omitempty"`
+	SpikeFactor      *float64 `json:"spike_factor,omitempty" yaml:"spike_factor,omitempty"`
+	GeographicFactor *float64 `json:"geographic_factor,omitempty" yaml:"geographic_factor,omitempty"`
+	MinOutlierCount  *int     `json:"min_outlier_count,omitempty" yaml:"min_outlier_count,omitempty"`
+	MinSpikeCount    *int     `json:"min_spike_count,omitempty" yaml:"min_spike_count,omitempty"`
+	MinNearbyCount   *int     `json:"min_nearby_count,omitempty" yaml:"min_nearby_count,omitempty"`
+}
+
+// Region overrides thresholds for readings inside a latitude and longitude box
+type Region struct {
+	Name         string               `json:"name" yaml:"name"`
+	MinLatitude  float64              `json:"min_latitude" yaml:"min_latitude"`
+	MaxLatitude  float64              `json:"max_latitude" yaml:"max_latitude"`
+	MinLongitude float64              `json:"min_longitude" yaml:"min_longitude"`
+	MaxLongitude float64              `json:"max_longitude" yaml:"max_longitude"`
+	Defaults     Overrides            `json:"defaults" yaml:"defaults"`
+	Parameters   map[string]Overrides `json:"parameters" yaml:"parameters"`
+}
+
+// Neighborhood sets the detection windows that the statistical rules compare a reading with. It is
+// only applied at startup, because changing it regroups the windows.
+type Neighborhood struct {
+	// Radius is the half-width of the neighborhood of a reading in degrees
+	Radius *float64 `json:"radius,omitempty" yaml:"radius,omitempty"`
+	// CellSize is the size of the window cells in degrees
+	CellSize *float64 `json:"cell_size,omitempty" yaml:"cell_size,omitempty"`
+}
+
+// Config is the declarative detection configuration. Thresholds of a reading are resolved from
+// the defaults, then the overrides of its parameter, then the defaults and parameter overrides
+// of the first region containing it.
+type Config struct {
+	Neighborhood Neighborhood         `json:"neighborhood" yaml:"neighborhood"`
+	Defaults     Overrides            `json:"defaults" yaml:"defaults"`
+	Parameters   map[string]Overrides `json:"parameters" yaml:"parameters"`
+	Regions      []Region             `json:"regions" yaml:"regions"`
+}
+
+// DefaultConfig returns the configuration of the built-in thresholds, with the WHO guideline
+// levels as limits and the alert levels of single readings
+func DefaultConfig() *Config {
+	v := func(f float64) *float64 { return &f }
+	return &Config{
+		Parameters: map[string]Overrides{
+			parameters.PM25: {AlertLevel: v(PM25AlertLevel), Limit24h: v(PM25Limit)},
+			parameters.PM10: {AlertLevel: v(PM10AlertLevel), Limit24h: v(PM10Limit)},
+			parameters.NO2:  {AlertLevel: v(NO2AlertLevel), Limit1h: v(NO2HourLimit), Limit24h: v(NO2Limit)},
+			parameters.O3:   {AlertLevel: v(O3AlertLevel), Limit8h: v(O3Limit)},
+			parameters.SO2:  {AlertLevel: v(SO2AlertLevel), Limit24h: v(SO2Limit)},
+			parameters.CO:   {AlertLevel: v(COAlertLevel), Limit24h: v(COLimit)},
+		},
+	}
+}
+
+// LoadConfig reads a configuration file, YAML or JSON by its extension, validates it and lays it
+// over the built-in configuration. Settings the file does not set keep their built-in values, so
+// the alert levels and limits of parameters it does not mention stay in effect.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&cfg)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil // An empty file keeps the built-in defaults
+		}
+	default:
+		return nil, fmt.Errorf("unsupported configuration format %q, expected .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %w", path, err)
+	}
+	return DefaultConfig().merge(&cfg), nil
+}
+
+// merge lays a validated configuration over this one: its neighborhood, defaults and parameter
+// overrides replace the settings they set, and its regions replace the regions
+func (c *Config) merge(other *Config) *Config {
+	c.Neighborhood.merge(other.Neighborhood)
+	c.Defaults.merge(other.Defaults)
+	if c.Parameters == nil {
+		c.Parameters = make(map[string]Overrides)
+	}
+	for name, o := range other.Parameters {
+		merged := c.Parameters[name]
+		merged.merge(o)
+		c.Parameters[name] = merged
+	}
+	if len(other.Regions) > 0 {
+		c.Regions = other.Regions
+	}
+	return c
+}
+
+// merge sets the radius and cell size that other sets
+func (n *Neighborhood) merge(other Neighborhood) {
+	if other.Radius != nil {
+		n.Radius = other.Radius
+	}
+	if other.CellSize != nil {
+		n.CellSize = other.CellSize
+	}
+}
+
+// Validate checks the neighborhood, thresholds, parameters and regions of the configuration and
+// keys the parameter overrides by canonical parameter name
+func (c *Config) Validate() error {
+	if err := c.Neighborhood.validate(); err != nil {
+		return err
+	}
+	if err := c.Defaults.validate("defaults"); err != nil {
+		return err
+	}
+	params, err := normalizeParameters(c.Parameters, "parameters")
+	if err != nil {
+		return err
+	}
+	c.Parameters = params
+
+	names := make(map[string]bool)
+	for i := range c.Regions {
+		region := &c.Regions[i]
+		where := fmt.Sprintf("regions[%d]", i)
+		if region.Name == "" {
+			return fmt.Errorf("%s: name is required", where)
+		}
+		if names[region.Name] {
+			return fmt.Errorf("%s: duplicate region %q", where, region.Name)
+		}
+		names[region.Name] = true
+		where = fmt.Sprintf("region %q", region.Name)
+
+		if region.MinLatitude < -90 || region.MaxLatitude > 90 || region.MinLatitude >= region.MaxLatitude {
+			return fmt.Errorf("%s: latitudes must satisfy -90 <= min_latitude < max_latitude <= 90", where)
+		}
+		if region.MinLongitude < -180 || region.MaxLongitude > 180 || region.MinLongitude >= region.MaxLongitude {
+			return fmt.Errorf("%s: longitudes must satisfy -180 <= min_longitude < max_longitude <= 180", where)
+		}
+		if err := region.Defaults.validate(where + " defaults"); err != nil {
+			return err
+		}
+		if region.Parameters, err = normalizeParameters(region.Parameters, where+" parameters"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks that the neighborhood is positive and not too many cells wide
+func (n Neighborhood) validate() error {
+	if n.Radius != nil && *n.Radius <= 0 {
+		return errors.New("neighborhood: radius must be positive")
+	}
+	if n.CellSize != nil && *n.CellSize <= 0 {
+		return errors.New("neighborhood: cell_size must be positive")
+	}
+	config := n.Windows(window.Config{})
+	if config.Radius/config.CellSize > maxNeighborhoodCells {
+		return fmt.Errorf("neighborhood: radius must be at most %d times cell_size", maxNeighborhoodCells)
+	}
+	return nil
+}
+
+// Windows returns the window configuration with the radius and cell size of the neighborhood,
+// or their defaults when they are not set
+func (n Neighborhood) Windows(config window.Config) window.Config {
+	config.Radius = window.DefaultRadius
+	if n.Radius != nil {
+		config.Radius = *n.Radius
+	}
+	config.CellSize = window.DefaultCellSize
+	if n.CellSize != nil {
+		config.CellSize = *n.CellSize
+	}
+	return config
+}
+
+// normalizeParameters validates parameter overrides and keys them by canonical parameter name
+func normalizeParameters(overrides map[string]Overrides, where string) (map[string]Overrides, error) {
+	normalized := make(map[string]Overrides, len(overrides))
+	for name, o := range overrides {
+		def, ok := parameters.Default().Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown parameter %q", where, name)
+		}
+		if _, ok := normalized[def.Name]; ok {
+			return nil, fmt.Errorf("%s: parameter %q is configured twice", where, def.Name)
+		}
+		if err := o.validate(where + " " + def.Name); err != nil {
+			return nil, err
+		}
+		normalized[def.Name] = o
+	}
+	return normalized, nil
+}
+
+// validate checks that the set thresholds are in range
+func (o Overrides) validate(where string) error {
+	for _, v := range []struct {
+		name  string
+		value *float64
+	}{{"alert_level", o.AlertLevel}, {"limit_1h", o.Limit1h}, {"limit_8h", o.Limit8h}, {"limit_24h", o.Limit24h},
+		{"zscore", o.ZScore}, {"spike_factor", o.SpikeFactor}} {
+		if v.value != nil && *v.value <= 0 {
+			return fmt.Errorf("%s: %s must be positive", where, v.name)
+		}
+	}
+	if o.GeographicFactor != nil && *o.GeographicFactor <= 1 {
+		return fmt.Errorf("%s: geographic_factor must be greater than 1", where)
+	}
+	if o.MinCompleteness != nil && (*o.MinCompleteness <= 0 || *o.MinCompleteness > 1) {
+		return fmt.Errorf("%s: min_completeness must be greater than 0 and at most 1", where)
+	}
+
+	// A standard deviation needs at least two readings
+	for _, v := range []struct {
+		name  string
+		value *int
+		min   int
+	}{{"min_outlier_count", o.MinOutlierCount, 2}, {"min_spike_count", o.MinSpikeCount, 1}, {"min_nearby_count", o.MinNearbyCount, 1},
+		{"min_hour_readings", o.MinHourReadings, 1}} {
+		if v.value != nil && *v.value < v.min {
+			return fmt.Errorf("%s: %s must be at least %d", where, v.name, v.min)
+		}
+	}
+	return nil
+}
+
+// merge sets the thresholds that other sets
+func (o *Overrides) merge(other Overrides) {
+	for _, f := range []struct{ dst, src **float64 }{
+		{&o.AlertLevel, &other.AlertLevel}, {&o.Limit1h, &other.Limit1h}, {&o.Limit8h, &other.Limit8h},
+		{&o.Limit24h, &other.Limit24h}, {&o.MinCompleteness, &other.MinCompleteness}, {&o.ZScore, &other.ZScore},
+		{&o.SpikeFactor, &other.SpikeFactor}, {&o.GeographicFactor, &other.GeographicFactor},
+	} {
+		if *f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+	for _, f := range []struct{ dst, src **int }{
+		{&o.MinHourReadings, &other.MinHourReadings}, {&o.MinOutlierCount, &other.MinOutlierCount},
+		{&o.MinSpikeCount, &other.MinSpikeCount}, {&o.MinNearbyCount, &other.MinNearbyCount},
+	} {
+		if *f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+}
+
+// Resolve returns the thresholds of a reading of a parameter at a location
+func (c *Config) Resolve(parameter string, latitude, longitude float64) Thresholds {
+	t := Thresholds{
+		ZScore:           DefaultZScore,
+		SpikeFactor:      DefaultSpikeFactor,
+		GeographicFactor: DefaultGeographicFactor,
+		MinOutlierCount:  DefaultMinOutlierCount,
+		MinSpikeCount:    DefaultMinSpikeCount,
+		MinNearbyCount:   DefaultMinNearbyCount,
+		Averaging: averaging.Requirement{
+			MinCompleteness: averaging.DefaultMinCompleteness,
+			MinHourReadings: averaging.DefaultMinHourReadings,
+		},
+	}
+
+	t.apply(c.Defaults)
+	t.apply(c.Parameters[parameter])
+	for i := range c.Regions {
+		region := &c.Regions[i]
+		if region.contains(latitude, longitude) {
+			t.apply(region.Defaults)
+			t.apply(region.Parameters[parameter])
+			break
+		}
+	}
+	return t
+}
+
+// contains reports whether a location is inside the region
+func (r *Region) contains(latitude, longitude float64) bool {
+	return latitude >= r.MinLatitude && latitude <= r.MaxLatitude &&
+		longitude >= r.MinLongitude && longitude <= r.MaxLongitude
+}
+
+// apply sets the thresholds that the overrides set
+func (t *Thresholds) apply(o Overrides) {
+	if o.AlertLevel != nil {
+		t.AlertLevel = o.AlertLevel
+	}
+	if o.Limit1h != nil {
+		t.Limit1h = o.Limit1h
+	}
+	if o.Limit8h != nil {
+		t.Limit8h = o.Limit8h
+	}
+	if o.Limit24h != nil {
+		t.Limit24h = o.Limit24h
+	}
+	if o.MinCompleteness != nil {
+		t.Averaging.MinCompleteness = *o.MinCompleteness
+	}
+	if o.MinHourReadings != nil {
+		t.Averaging.MinHourReadings = *o.MinHourReadings
+	}
+	if o.ZScore != nil {
+		t.ZScore = *o.ZScore
+	}
+	if o.SpikeFactor != nil {
+		t.SpikeFactor = *o.SpikeFactor
+	}
+	if o.GeographicFactor != nil {
+		t.GeographicFactor = *o.GeographicFactor
+	}
+	if o.MinOutlierCount != nil {
+		t.MinOutlierCount = *o.MinOutlierCount
+	}
+	if o.MinSpikeCount != nil {
+		t.MinSpikeCount = *o.MinSpikeCount
+	}
+	if o.MinNearbyCount != nil {
+		t.MinNearbyCount = *o.MinNearbyCount
+	}
+}
+
+// Limit returns the limit of the mean over an averaging period, nil when there is none
+func (t Thresholds) Limit(period averaging.Period) *float64 {
+	switch period {
+	case averaging.OneHour:
+		return t.Limit1h
+	case averaging.EightHours:
+		return t.Limit8h
+	case averaging.TwentyFourHours:
+		return t.Limit24h
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package anomaly
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/services/averaging"
+	"github.com/user/airpollution/internal/services/window"
+)
+
+const testConfigYAML = `
+defaults:
+  zscore: 2.5
+parameters:
+  pm25:
+    limit_24h: 25
+  NO2:
+    spike_factor: 2
+regions:
+  - name: istanbul
+    min_latitude: 40.8
+    max_latitude: 41.3
+    min_longitude: 28.5
+    max_longitude: 29.5
+    defaults:
+      min_nearby_count: 5
+    parameters:
+      PM2.5:
+        limit_24h: 35
+  - name: marmara
+    min_latitude: 40
+    max_latitude: 42
+    min_longitude: 26
+    max_longitude: 31
+    defaults:
+      zscore: 4
+`
+
+// writeConfig writes a configuration file into a temporary directory and returns its path
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigResolve(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, "detection.yaml", testConfigYAML))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		parameter   string
+		lat, lon    float64
+		limit       float64 // 0 means no limit
+		zScore      float64
+		spikeFactor float64
+		minNearby   int
+	}{
+		{"Parameter Override", "PM2.5", 0, 0, 25, 2.5, DefaultSpikeFactor, DefaultMinNearbyCount},
+		{"Built-In Limit Outside The File", "PM10", 0, 0, PM10Limit, 2.5, DefaultSpikeFactor, DefaultMinNearbyCount},
+		{"Parameter Alias", "NO2", 0, 0, NO2Limit, 2.5, 2, DefaultMinNearbyCount},
+		{"Region Parameter Override", "PM2.5", 41.0, 29.0, 35, 2.5, DefaultSpikeFactor, 5},
+		{"First Matching Region Wins", "NO2", 41.0, 29.0, NO2Limit, 2.5, 2, 5},
+		{"Second Region", "PM2.5", 40.5, 27.0, 25, 4, DefaultSpikeFactor, DefaultMinNearbyCount},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			thresholds := cfg.Resolve(tc.parameter, tc.lat, tc.lon)
+
+			var limit float64
+			if thresholds.Limit24h != nil {
+				limit = *thresholds.Limit24h
+			}
+			if limit != tc.limit {
+				t.Errorf("Expected limit %v but got %v", tc.limit, limit)
+			}
+			if thresholds.ZScore != tc.zScore {
+				t.Errorf("Expected z-score %v but got %v", tc.zScore, thresholds.ZScore)
+			}
+			if thresholds.SpikeFactor != tc.spikeFactor {
+				t.Errorf("Expected spike factor %v but got %v", tc.spikeFactor, thresholds.SpikeFactor)
+			}
+			if thresholds.MinNearbyCount != tc.minNearby {
+				t.Errorf("Expected minimum nearby count %d but got %d", tc.minNearby, thresholds.MinNearbyCount)
+			}
+		})
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, "detection.json", `{"parameters": {"O3": {"limit_8h": 120, "min_completeness": 0.5}}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	thresholds := cfg.Resolve("O3", 0, 0)
+	if limit := thresholds.Limit(averaging.EightHours); limit == nil || *limit != 120 {
+		t.Errorf("Expected 8-hour limit 120 but got %v", limit)
+	}
+	if thresholds.Averaging.MinCompleteness != 0.5 || thresholds.Averaging.MinHourReadings != averaging.DefaultMinHourReadings {
+		t.Errorf("Expected completeness 0.5 with the default hour readings but got %+v", thresholds.Averaging)
+	}
+}
+
+func TestLoadConfigKeepsBuiltInThresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Empty File", ""},
+		{"Defaults Only", "defaults:\n  zscore: 2.5\n"},
+		{"Other Setting Of The Parameter", "parameters:\n  PM2.5:\n    spike_factor: 2\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeConfig(t, "detection.yaml", tc.content))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for parameter, expected := range map[string]struct{ alertLevel, limit float64 }{
+				"PM2.5": {PM25AlertLevel, PM25Limit},
+				"SO2":   {SO2AlertLevel, SO2Limit},
+			} {
+				thresholds := cfg.Resolve(parameter, 0, 0)
+				if thresholds.AlertLevel == nil || *thresholds.AlertLevel != expected.alertLevel {
+					t.Errorf("Expected %s alert level %v but got %v", parameter, expected.alertLevel, thresholds.AlertLevel)
+				}
+				if thresholds.Limit24h == nil || *thresholds.Limit24h != expected.limit {
+					t.Errorf("Expected %s 24-hour limit %v but got %v", parameter, expected.limit, thresholds.Limit24h)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{"Unknown Field", "c.yaml", "defaults:\n  zscore_cutoff: 3\n", "zscore_cutoff"},
+		{"Unknown JSON Field", "c.json", `{"default": {}}`, "default"},
+		{"Unknown Parameter", "c.yaml", "parameters:\n  XYZ:\n    limit_1h: 1\n", "unknown parameter"},
+		{"Parameter Twice", "c.yaml", "parameters:\n  PM2.5:\n    limit_1h: 1\n  pm25:\n    limit_1h: 2\n", "configured twice"},
+		{"Negative Limit", "c.yaml", "parameters:\n  PM10:\n    limit_24h: -1\n", "limit_24h must be positive"},
+		{"Instantaneous Limit", "c.yaml", "parameters:\n  PM10:\n    limit: 50\n", "limit"},
+		{"Completeness", "c.yaml", "defaults:\n  min_completeness: 1.5\n", "min_completeness"},
+		{"Geographic Factor", "c.yaml", "defaults:\n  geographic_factor: 0.5\n", "geographic_factor"},
+		{"Outlier Count", "c.yaml", "defaults:\n  min_outlier_count: 1\n", "min_outlier_count"},
+		{"Region Bounds", "c.yaml", "regions:\n  - name: r\n    min_latitude: 10\n    max_latitude: 5\n    min_longitude: 0\n    max_longitude: 1\n", "latitudes"},
+		{"Duplicate Region", "c.yaml", "regions:\n  - {name: r, min_latitude: 0, max_latitude: 1, min_longitude: 0, max_longitude: 1}\n  - {name: r, min_latitude: 0, max_latitude: 1, min_longitude: 0, max_longitude: 1}\n", "duplicate region"},
+		{"Negative Radius", "c.yaml", "neighborhood:\n  radius: -0.25\n", "radius must be positive"},
+		{"Too Many Cells", "c.yaml", "neighborhood:\n  radius: 0.5\n  cell_size: 0.01\n", "at most 10 times cell_size"},
+		{"Unsupported Format", "c.toml", "", "unsupported"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tc.file, tc.content))
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q but got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestDefaultConfigMatchesWHOLimits(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	thresholds := DefaultConfig().Resolve("PM2.5", 0, 0)
+	if thresholds.Limit24h == nil || *thresholds.Limit24h != PM25Limit || thresholds.Limit1h != nil {
+		t.Errorf("Expected only a 24-hour limit of %v but got %+v", PM25Limit, thresholds)
+	}
+	if thresholds.AlertLevel == nil || *thresholds.AlertLevel != PM25AlertLevel {
+		t.Errorf("Expected alert level %v but got %v", PM25AlertLevel, thresholds.AlertLevel)
+	}
+	if thresholds.ZScore != DefaultZScore || thresholds.MinOutlierCount != DefaultMinOutlierCount {
+		t.Errorf("Expected default statistical thresholds but got %+v", thresholds)
+	}
+}
+
+func TestNeighborhoodWindows(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, "c.yaml", "neighborhood:\n  radius: 0.1\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	config := cfg.Neighborhood.Windows(window.Config{Duration: time.Hour})
+	if config.Radius != 0.1 || config.CellSize != window.DefaultCellSize || config.Duration != time.Hour {
+		t.Errorf("Expected a radius of 0.1 with the default cell size, got %+v", config)
+	}
+	if config := DefaultConfig().Neighborhood.Windows(window.Config{}); config.Radius != window.DefaultRadius {
+		t.Errorf("Expected the default radius, got %+v", config)
+	}
+}
+
+func TestExampleConfigLoads(t *testing.T) {
+	if _, err := LoadConfig("../../../docs/detection.example.yaml"); err != nil {
+		t.Errorf("Expected the example configuration to load, got %v", err)
+	}
+}
@@ -1,30 +1,69 @@
 package anomaly
 
 import (
+	"errors"
+	"fmt"
 	"math"
-	"sort"
+	"sync/atomic"
 
 	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/averaging"
 )
 
-// WHO limits for common air pollutants (in μg/m³)
+// WHO guideline levels (2021) for common air pollutants, the limits of DefaultConfig, in the
+// canonical unit of each parameter (μg/m³, mg/m³ for CO)
 const (
-	PM25Limit = 15.0  // Annual mean
-	PM10Limit = 45.0  // Annual mean
-	NO2Limit  = 25.0  // Annual mean
-	O3Limit   = 100.0 // 8-hour mean
+	PM25Limit    = 15.0  // 24-hour mean
+	PM10Limit    = 45.0  // 24-hour mean
+	NO2Limit     = 25.0  // 24-hour mean
+	NO2HourLimit = 200.0 // 1-hour mean (WHO 2005)
+	O3Limit      = 100.0 // Maximum daily 8-hour mean
+	SO2Limit     = 40.0  // 24-hour mean
+	COLimit      = 4.0   // 24-hour mean
+)
+
+// Alert levels of single readings, the alert levels of DefaultConfig: the EU alert thresholds
+// where there is one, otherwise the lower bound of the India NAQI severe band
+const (
+	PM25AlertLevel = 250.0
+	PM10AlertLevel = 430.0
+	NO2AlertLevel  = 400.0
+	O3AlertLevel   = 240.0
+	SO2AlertLevel  = 500.0
+	COAlertLevel   = 34.0
 )
 
 // Detector is responsible for detecting anomalies in air quality data
 type Detector struct {
 	historicalData map[string][]models.AirQualityData // Map of parameter to historical data
+	rules          *Registry
+	config         atomic.Pointer[Config]
 }
 
-// NewDetector creates a new anomaly detector
+// NewDetector creates a new anomaly detector running the rules of the default registry
 func NewDetector() *Detector {
-	return &Detector{
+	return NewDetectorWithRegistry(Default())
+}
+
+// NewDetectorWithRegistry creates a new anomaly detector running the rules of a registry
+func NewDetectorWithRegistry(rules *Registry) *Detector {
+	d := &Detector{
 		historicalData: make(map[string][]models.AirQualityData),
+		rules:          rules,
 	}
+	d.config.Store(DefaultConfig())
+	return d
+}
+
+// SetConfig replaces the thresholds of the detector. It is safe to call while readings are
+// being checked; each reading is checked with either the old or the new configuration.
+func (d *Detector) SetConfig(config *Config) {
+	d.config.Store(config)
+}
+
+// Config returns the configuration the detector currently applies
+func (d *Detector) Config() *Config {
+	return d.config.Load()
 }
 
 // AddHistoricalData adds historical data for a parameter
@@ -37,51 +76,36 @@ func (d *Detector) AddHistoricalData(data []models.AirQualityData) {
 	}
 }
 
-// Detect checks for anomalies in the given data point
-func (d *Detector) Detect(data *models.AirQualityData, recentData []models.AirQualityData) (*models.Anomaly, error) {
-	// Check for threshold exceedance
-	if anomaly := d.checkThresholdExceeded(data); anomaly != nil {
-		return anomaly, nil
-	}
-
-	// Check for statistical outlier (Z-score)
-	if anomaly := d.checkStatisticalOutlier(data, recentData); anomaly != nil {
-		return anomaly, nil
-	}
-
-	// Check for spike detection
-	if anomaly := d.checkSpikeDetection(data, recentData); anomaly != nil {
-		return anomaly, nil
-	}
-
-	// Check for geographic inconsistency
-	if anomaly := d.checkGeographicInconsistency(data, recentData); anomaly != nil {
-		return anomaly, nil
+// Detect runs every rule enabled for the parameter of the data point, given the recent readings
+// and averages in ctx, and returns the findings of all rules. The thresholds of ctx are set from
+// the configuration for the parameter and location of the data point.
+// A rule that fails does not stop the others; its error is returned with the findings.
+func (d *Detector) Detect(data *models.AirQualityData, ctx Context) ([]*models.Anomaly, error) {
+	ctx.Thresholds = d.config.Load().Resolve(data.Parameter, data.Latitude, data.Longitude)
+
+	var anomalies []*models.Anomaly
+	var errs []error
+	for _, rule := range d.rules.Rules(data.Parameter) {
+		found, err := rule.Evaluate(data, ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.Name(), err))
+			continue
+		}
+		anomalies = append(anomalies, found...)
 	}
 
-	return nil, nil
+	return anomalies, errors.Join(errs...)
 }
 
-// checkThresholdExceeded checks if the value exceeds WHO limits
-func (d *Detector) checkThresholdExceeded(data *models.AirQualityData) *models.Anomaly {
-	var limit float64
-
-	switch data.Parameter {
-	case "PM2.5":
-		limit = PM25Limit
-	case "PM10":
-		limit = PM10Limit
-	case "NO2":
-		limit = NO2Limit
-	case "O3":
-		limit = O3Limit
-	default:
-		return nil // No known threshold for this parameter
+// checkAlertLevel checks if a single reading exceeds the alert level of its parameter
+func checkAlertLevel(data *models.AirQualityData, ctx Context) *models.Anomaly {
+	if ctx.Thresholds.AlertLevel == nil {
+		return nil // No alert level for this parameter
 	}
 
-	if data.Value > limit {
+	if data.Value > *ctx.Thresholds.AlertLevel {
 		return models.NewAnomalyFromData(
-			string(models.ThresholdExceeded),
+			string(models.AlertLevelExceeded),
 			data,
 		)
 	}
@@ -89,38 +113,48 @@ func (d *Detector) checkThresholdExceeded(data *models.AirQualityData) *models.A
 	return nil
 }
 
-// checkStatisticalOutlier uses Z-score to detect outliers
-func (d *Detector) checkStatisticalOutlier(data *models.AirQualityData, recentData []models.AirQualityData) *models.Anomaly {
-	if len(recentData) < 10 {
-		return nil // Not enough data for statistical analysis
-	}
+// checkThresholdExceeded returns a check of the mean over an averaging period, ending with the
+// hour of the reading, against the limit of the parameter for that period. An anomaly is only
+// raised when the mean crosses the limit, not for every reading while it stays above it. A mean
+// that does not meet the completeness requirement is not checked.
+func checkThresholdExceeded(period averaging.Period) func(data *models.AirQualityData, ctx Context) *models.Anomaly {
+	return func(data *models.AirQualityData, ctx Context) *models.Anomaly {
+		limit := ctx.Thresholds.Limit(period)
+		if limit == nil || ctx.Averages == nil {
+			return nil // No limit for this parameter and period
+		}
 
-	// Extract values
-	values := make([]float64, len(recentData))
-	for i, d := range recentData {
-		values[i] = d.Value
-	}
+		location := averaging.Location(data)
+		mean, ok := ctx.Averages.Mean(data.Parameter, location, period, data.Timestamp, ctx.Thresholds.Averaging)
+		if !ok {
+			return nil // Not enough hours with readings
+		}
+
+		if ctx.Averages.Crossed(data.Parameter, location, period, mean.Value > *limit) {
+			return models.NewAveragedAnomalyFromData(
+				string(models.ThresholdExceeded),
+				data,
+				period.Name,
+				mean.Value,
+			)
+		}
 
-	// Calculate mean
-	sum := 0.0
-	for _, v := range values {
-		sum += v
+		return nil
 	}
-	mean := sum / float64(len(values))
+}
 
-	// Calculate standard deviation
-	sumSquaredDiff := 0.0
-	for _, v := range values {
-		diff := v - mean
-		sumSquaredDiff += diff * diff
+// checkStatisticalOutlier uses Z-score to detect outliers
+func checkStatisticalOutlier(data *models.AirQualityData, ctx Context) *models.Anomaly {
+	recent := ctx.Recent
+	if recent.Count < ctx.Thresholds.MinOutlierCount {
+		return nil // Not enough data for statistical analysis
 	}
-	stdDev := math.Sqrt(sumSquaredDiff / float64(len(values)))
 
 	// Calculate Z-score
-	zScore := (data.Value - mean) / stdDev
+	zScore := (data.Value - recent.Mean) / recent.StdDev
 
-	// If Z-score is greater than 3, it's an outlier (99.7% confidence)
-	if math.Abs(zScore) > 3.0 {
+	// With the default cutoff of 3, an outlier is outside 99.7% of a normal distribution
+	if math.Abs(zScore) > ctx.Thresholds.ZScore {
 		return models.NewAnomalyFromData(
 			string(models.StatisticalOutlier),
 			data,
@@ -131,25 +165,14 @@ func (d *Detector) checkStatisticalOutlier(data *models.AirQualityData, recentDa
 }
 
 // checkSpikeDetection checks for sudden spikes in values
-func (d *Detector) checkSpikeDetection(data *models.AirQualityData, recentData []models.AirQualityData) *models.Anomaly {
-	if len(recentData) < 5 {
+func checkSpikeDetection(data *models.AirQualityData, ctx Context) *models.Anomaly {
+	recent := ctx.Recent
+	if recent.Count < ctx.Thresholds.MinSpikeCount {
 		return nil // Not enough data for spike detection
 	}
 
-	// Sort by timestamp to ensure chronological order
-	sort.Slice(recentData, func(i, j int) bool {
-		return recentData[i].Timestamp.Before(recentData[j].Timestamp)
-	})
-
-	// Calculate average of recent values (last 24 hours)
-	var sum float64
-	for _, d := range recentData {
-		sum += d.Value
-	}
-	avg := sum / float64(len(recentData))
-
-	// Check if current value is 50% higher than the average
-	if data.Value > avg*1.5 {
+	// Check if current value is higher than the average of the window by the spike factor
+	if data.Value > recent.Mean*ctx.Thresholds.SpikeFactor {
 		return models.NewAnomalyFromData(
 			string(models.SpikeDetected),
 			data,
@@ -159,49 +182,17 @@ func (d *Detector) checkSpikeDetection(data *models.AirQualityData, recentData [
 	return nil
 }
 
-// checkGeographicInconsistency checks if value is inconsistent with nearby readings
-func (d *Detector) checkGeographicInconsistency(data *models.AirQualityData, recentData []models.AirQualityData) *models.Anomaly {
-	if len(recentData) < 3 {
-		return nil // Not enough data for geographic consistency check
-	}
-
-	// Find nearby readings within the last hour (approximately 25km radius)
-	const (
-		latDelta = 0.25 // Roughly 25km
-		lonDelta = 0.25
-	)
-
-	// Filter nearby readings
-	var nearbyReadings []models.AirQualityData
-	for _, d := range recentData {
-		latDiff := math.Abs(data.Latitude - d.Latitude)
-		lonDiff := math.Abs(data.Longitude - d.Longitude)
-
-		if latDiff <= latDelta && lonDiff <= lonDelta {
-			nearbyReadings = append(nearbyReadings, d)
-		}
-	}
-
-	if len(nearbyReadings) < 3 {
+// checkGeographicInconsistency checks if value is inconsistent with readings in its neighborhood
+func checkGeographicInconsistency(data *models.AirQualityData, ctx Context) *models.Anomaly {
+	recent := ctx.Recent
+	if recent.Count < ctx.Thresholds.MinNearbyCount {
 		return nil // Not enough nearby readings
 	}
 
-	// Calculate median of nearby readings
-	values := make([]float64, len(nearbyReadings))
-	for i, d := range nearbyReadings {
-		values[i] = d.Value
-	}
-	sort.Float64s(values)
-
-	var median float64
-	if len(values)%2 == 0 {
-		median = (values[len(values)/2-1] + values[len(values)/2]) / 2
-	} else {
-		median = values[len(values)/2]
-	}
-
-	// Check if current value is significantly different (>3x) from median
-	if data.Value > median*3 || (median > 0 && data.Value*3 < median) {
+	// Check if current value differs from the median by more than the geographic factor
+	median := recent.Median
+	factor := ctx.Thresholds.GeographicFactor
+	if data.Value > median*factor || (median > 0 && data.Value*factor < median) {
 		return models.NewAnomalyFromData(
 			string(models.GeographicInconsistency),
 			data,
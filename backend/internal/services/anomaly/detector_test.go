@@ -4,27 +4,28 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/averaging"
+	"github.com/user/airpollution/internal/services/window"
 )
 
-func TestCheckThresholdExceeded(t *testing.T) {
-	detector := NewDetector()
-
+func TestCheckAlertLevel(t *testing.T) {
 	tests := []struct {
 		name      string
 		parameter string
 		value     float64
 		expected  bool
 	}{
-		{"PM2.5 Below Threshold", "PM2.5", 10.0, false},
-		{"PM2.5 Above Threshold", "PM2.5", 20.0, true},
-		{"PM10 Below Threshold", "PM10", 40.0, false},
-		{"PM10 Above Threshold", "PM10", 50.0, true},
-		{"NO2 Below Threshold", "NO2", 20.0, false},
-		{"NO2 Above Threshold", "NO2", 30.0, true},
-		{"O3 Below Threshold", "O3", 90.0, false},
-		{"O3 Above Threshold", "O3", 110.0, true},
-		{"Unknown Parameter", "CO", 100.0, false},
+		{"PM2.5 Above Guideline", "PM2.5", 20.0, false},
+		{"PM2.5 Above Alert Level", "PM2.5", 260.0, true},
+		{"PM10 Below Alert Level", "PM10", 400.0, false},
+		{"PM10 Above Alert Level", "PM10", 450.0, true},
+		{"NO2 Below Alert Level", "NO2", 300.0, false},
+		{"NO2 Above Alert Level", "NO2", 410.0, true},
+		{"O3 Below Alert Level", "O3", 200.0, false},
+		{"O3 Above Alert Level", "O3", 250.0, true},
+		{"Unknown Parameter", "BC", 1000.0, false},
 	}
 
 	for _, tc := range tests {
@@ -37,7 +38,7 @@ func TestCheckThresholdExceeded(t *testing.T) {
 				Timestamp: time.Now(),
 			}
 
-			anomaly := detector.checkThresholdExceeded(data)
+			anomaly := checkAlertLevel(data, defaultContext(data, window.Stats{}))
 
 			if tc.expected && anomaly == nil {
 				t.Errorf("Expected anomaly but got nil")
@@ -47,16 +48,90 @@ func TestCheckThresholdExceeded(t *testing.T) {
 				t.Errorf("Expected no anomaly but got %v", anomaly)
 			}
 
-			if anomaly != nil && anomaly.Type != string(models.ThresholdExceeded) {
-				t.Errorf("Expected anomaly type %s but got %s", models.ThresholdExceeded, anomaly.Type)
+			if anomaly != nil && anomaly.Type != string(models.AlertLevelExceeded) {
+				t.Errorf("Expected anomaly type %s but got %s", models.AlertLevelExceeded, anomaly.Type)
 			}
 		})
 	}
 }
 
-func TestCheckStatisticalOutlier(t *testing.T) {
-	detector := NewDetector()
+func TestCheckThresholdExceeded(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		parameter string
+		period    averaging.Period
+		hours     int     // Hours with readings, ending with the hour of the reading
+		value     float64 // Value of every reading
+		expected  bool
+	}{
+		{"PM2.5 Day Above Limit", "PM2.5", averaging.TwentyFourHours, 24, 20.0, true},
+		{"PM2.5 Day Below Limit", "PM2.5", averaging.TwentyFourHours, 24, 10.0, false},
+		{"PM2.5 Incomplete Day", "PM2.5", averaging.TwentyFourHours, 12, 20.0, false},
+		{"PM2.5 Without Hourly Limit", "PM2.5", averaging.OneHour, 1, 200.0, false},
+		{"O3 Eight Hours Above Limit", "O3", averaging.EightHours, 6, 120.0, true},
+		{"O3 Incomplete Eight Hours", "O3", averaging.EightHours, 5, 120.0, false},
+		{"NO2 Hour Above Limit", "NO2", averaging.OneHour, 1, 210.0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			averages := averaging.New(averaging.Config{})
+			var data *models.AirQualityData
+			for h := tc.hours - 1; h >= 0; h-- {
+				data = &models.AirQualityData{ID: uuid.New(), SensorID: "s1", Parameter: tc.parameter, Value: tc.value,
+					Timestamp: now.Add(-time.Duration(h) * time.Hour)}
+				averages.Add(data)
+			}
 
+			ctx := defaultContext(data, window.Stats{})
+			ctx.Averages = averages
+			anomaly := checkThresholdExceeded(tc.period)(data, ctx)
+
+			if tc.expected && anomaly == nil {
+				t.Fatalf("Expected anomaly but got nil")
+			}
+			if !tc.expected && anomaly != nil {
+				t.Fatalf("Expected no anomaly but got %v", anomaly)
+			}
+			if anomaly != nil && (anomaly.Type != string(models.ThresholdExceeded) ||
+				anomaly.AveragingPeriod != tc.period.Name || anomaly.Value != tc.value) {
+				t.Errorf("Expected a %s anomaly of the %s mean %v but got %+v", models.ThresholdExceeded, tc.period, tc.value, anomaly)
+			}
+		})
+	}
+}
+
+func TestCheckThresholdExceededOnlyOnCrossing(t *testing.T) {
+	now := time.Now()
+	averages := averaging.New(averaging.Config{})
+	check := checkThresholdExceeded(averaging.OneHour)
+
+	// NO2 has an hourly limit of 200; each reading moves the mean of the hour
+	values := []struct {
+		value    float64
+		expected bool
+	}{
+		{250, true},  // Mean 250 crosses the limit
+		{250, false}, // Mean 250 stays above it
+		{100, false}, // Mean 200 drops to the limit
+		{400, true},  // Mean 250 crosses it again
+	}
+
+	for i, v := range values {
+		data := &models.AirQualityData{ID: uuid.New(), SensorID: "s1", Parameter: "NO2", Value: v.value, Timestamp: now}
+		averages.Add(data)
+
+		ctx := defaultContext(data, window.Stats{})
+		ctx.Averages = averages
+		if anomaly := check(data, ctx); (anomaly != nil) != v.expected {
+			t.Errorf("Reading %d: expected anomaly %v, got %v", i, v.expected, anomaly)
+		}
+	}
+}
+
+func TestCheckStatisticalOutlier(t *testing.T) {
 	// Create historical data
 	historicalData := make([]models.AirQualityData, 0)
 	now := time.Now()
@@ -91,13 +166,13 @@ func TestCheckStatisticalOutlier(t *testing.T) {
 	}
 
 	// Test normal value
-	anomaly := detector.checkStatisticalOutlier(normal, historicalData)
+	anomaly := checkStatisticalOutlier(normal, defaultContext(normal, statsOf(historicalData)))
 	if anomaly != nil {
 		t.Errorf("Expected no anomaly for normal value but got %v", anomaly)
 	}
 
 	// Test outlier
-	anomaly = detector.checkStatisticalOutlier(outlier, historicalData)
+	anomaly = checkStatisticalOutlier(outlier, defaultContext(outlier, statsOf(historicalData)))
 	if anomaly == nil {
 		t.Errorf("Expected anomaly for outlier value but got nil")
 	}
@@ -106,3 +181,80 @@ func TestCheckStatisticalOutlier(t *testing.T) {
 		t.Errorf("Expected anomaly type %s but got %s", models.StatisticalOutlier, anomaly.Type)
 	}
 }
+
+func TestDetectWithWindowStats(t *testing.T) {
+	detector := NewDetector()
+
+	tests := []struct {
+		name      string
+		parameter string
+		value     float64
+		recent    []float64
+		expected  []models.AnomalyType
+	}{
+		{"Too Few Readings", "CO", 12.0, []float64{2.0, 2.0}, nil},
+		{"Normal Value", "CO", 11.0, []float64{10.0, 11.0, 12.0, 10.0, 11.0}, nil},
+		{"Spike", "CO", 14.0, []float64{8.0, 9.0, 10.0, 8.0, 9.0}, []models.AnomalyType{models.SpikeDetected}},
+		{"Geographic Inconsistency", "CO", 1.0, []float64{12.0, 13.0, 14.0}, []models.AnomalyType{models.GeographicInconsistency}},
+		{"Several Findings", "PM2.5", 300.0, []float64{10.0, 11.0, 12.0, 10.0, 11.0},
+			[]models.AnomalyType{models.AlertLevelExceeded, models.SpikeDetected, models.GeographicInconsistency}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data := &models.AirQualityData{Parameter: tc.parameter, Value: tc.value, Timestamp: time.Now()}
+
+			anomalies, err := detector.Detect(data, Context{Recent: window.Summarize(tc.recent)})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(anomalies) != len(tc.expected) {
+				t.Fatalf("Expected %d anomalies but got %d", len(tc.expected), len(anomalies))
+			}
+			for i, anomaly := range anomalies {
+				if anomaly.Type != string(tc.expected[i]) {
+					t.Errorf("Expected anomaly type %s but got %s", tc.expected[i], anomaly.Type)
+				}
+			}
+		})
+	}
+}
+
+// statsOf summarizes the values of readings
+func statsOf(data []models.AirQualityData) window.Stats {
+	values := make([]float64, len(data))
+	for i, d := range data {
+		values[i] = d.Value
+	}
+	return window.Summarize(values)
+}
+
+// defaultContext is the context of a reading under the default configuration
+func defaultContext(data *models.AirQualityData, recent window.Stats) Context {
+	return Context{
+		Recent:     recent,
+		Thresholds: DefaultConfig().Resolve(data.Parameter, data.Latitude, data.Longitude),
+	}
+}
+
+func TestAnomalyIDIsStable(t *testing.T) {
+	data := &models.AirQualityData{ID: uuid.New(), Parameter: "PM2.5", Value: 300.0, Timestamp: time.Now()}
+
+	first := checkAlertLevel(data, defaultContext(data, window.Stats{}))
+	again := checkAlertLevel(data, defaultContext(data, window.Stats{}))
+	if first == nil || again == nil || first.ID != again.ID {
+		t.Errorf("Expected the same anomaly ID when detecting a reading again, got %v and %v", first, again)
+	}
+
+	otherData := &models.AirQualityData{ID: uuid.New(), Parameter: "PM2.5", Value: 300.0, Timestamp: time.Now()}
+	other := checkAlertLevel(otherData, defaultContext(otherData, window.Stats{}))
+	if other == nil || other.ID == first.ID {
+		t.Errorf("Expected anomalies of different readings to have different IDs")
+	}
+
+	hourly := models.NewAveragedAnomalyFromData(string(models.ThresholdExceeded), data, "1h", 300.0)
+	daily := models.NewAveragedAnomalyFromData(string(models.ThresholdExceeded), data, "24h", 300.0)
+	if hourly.ID == daily.ID || hourly.ID == first.ID {
+		t.Errorf("Expected anomalies of different averaging periods to have different IDs")
+	}
+}
@@ -0,0 +1,231 @@
+package anomaly
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/averaging"
+	"github.com/user/airpollution/internal/services/window"
+)
+
+// Context is what a rule knows about a reading besides the reading itself
+type Context struct {
+	// Recent summarizes the recent readings of the parameter in the neighborhood of the reading
+	Recent window.Stats
+	// Averages holds the hourly series the averaged threshold rules compute means from, nil when
+	// they are not kept
+	Averages *averaging.Store
+	// Thresholds are the thresholds configured for the parameter and location of the reading
+	Thresholds Thresholds
+}
+
+// Rule is an anomaly check run on every reading. A rule returns one finding per anomaly it
+// detects, usually none, created with models.NewAnomalyFromData and a type of its own.
+type Rule interface {
+	Name() string
+	Evaluate(data *models.AirQualityData, ctx Context) ([]*models.Anomaly, error)
+}
+
+// RuleFunc adapts a function to the Rule interface under a name
+type RuleFunc struct {
+	RuleName string
+	Func     func(data *models.AirQualityData, ctx Context) ([]*models.Anomaly, error)
+}
+
+// Name returns the name of the rule
+func (f RuleFunc) Name() string {
+	return f.RuleName
+}
+
+// Evaluate calls f.Func(data, ctx)
+func (f RuleFunc) Evaluate(data *models.AirQualityData, ctx Context) ([]*models.Anomaly, error) {
+	return f.Func(data, ctx)
+}
+
+// Registry holds the rules run by a detector, in registration order, and for which parameters
+// each of them is enabled. Rules are enabled for all parameters when they are registered.
+type Registry struct {
+	mu    sync.RWMutex
+	rules []Rule
+	// disabled holds the rules disabled for all parameters
+	disabled map[string]bool
+	// overrides holds, per rule and parameter, whether the rule is enabled regardless of disabled
+	overrides map[string]map[string]bool
+}
+
+// NewRegistry creates an empty rule registry
+func NewRegistry() *Registry {
+	return &Registry{
+		disabled:  make(map[string]bool),
+		overrides: make(map[string]map[string]bool),
+	}
+}
+
+// Register adds a rule that runs after the rules registered before it
+func (r *Registry) Register(rule Rule) error {
+	if rule == nil || rule.Name() == "" {
+		return fmt.Errorf("rule registration needs a rule with a name")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.rules {
+		if existing.Name() == rule.Name() {
+			return fmt.Errorf("rule %q is already registered", rule.Name())
+		}
+	}
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+// Lookup finds a rule by name
+func (r *Registry) Lookup(name string) (Rule, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rule := range r.rules {
+		if rule.Name() == name {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// Names returns the names of the registered rules in registration order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.rules))
+	for i, rule := range r.rules {
+		names[i] = rule.Name()
+	}
+	return names
+}
+
+// SetEnabled turns a rule on or off for a parameter. An empty parameter applies to all
+// parameters without a setting of their own.
+func (r *Registry) SetEnabled(name, parameter string, enabled bool) error {
+	if _, ok := r.Lookup(name); !ok {
+		return fmt.Errorf("no rule registered with name %q", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if parameter == "" {
+		r.disabled[name] = !enabled
+		return nil
+	}
+	if r.overrides[name] == nil {
+		r.overrides[name] = make(map[string]bool)
+	}
+	r.overrides[name][parameter] = enabled
+	return nil
+}
+
+// DisableAll turns off rules given as rule or rule:parameter entries separated by commas, such as
+// "spike,geographic-inconsistency:PM10"
+func (r *Registry) DisableAll(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		name, parameter, _ := strings.Cut(entry, ":")
+		name, parameter = strings.TrimSpace(name), strings.TrimSpace(parameter)
+		if name == "" {
+			return fmt.Errorf("invalid disabled rule %q, expected rule or rule:parameter", entry)
+		}
+		if err := r.SetEnabled(name, parameter, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether a rule runs for a parameter
+func (r *Registry) Enabled(name, parameter string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.enabled(name, parameter)
+}
+
+// Rules returns the rules enabled for a parameter in registration order
+func (r *Registry) Rules(parameter string) []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var rules []Rule
+	for _, rule := range r.rules {
+		if r.enabled(rule.Name(), parameter) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// enabled reports whether a rule runs for a parameter; the caller holds the lock
+func (r *Registry) enabled(name, parameter string) bool {
+	if enabled, ok := r.overrides[name][parameter]; ok {
+		return enabled
+	}
+	return !r.disabled[name]
+}
+
+// Names of the built-in rules
+const (
+	RuleAlertLevel              = "alert-level"
+	RuleThreshold1h             = "threshold-1h"
+	RuleThreshold8h             = "threshold-8h"
+	RuleThreshold24h            = "threshold-24h"
+	RuleStatisticalOutlier      = "statistical-outlier"
+	RuleSpike                   = "spike"
+	RuleGeographicInconsistency = "geographic-inconsistency"
+)
+
+// defaultRegistry holds the built-in rules and those registered by other packages
+var defaultRegistry = NewBuiltinRegistry()
+
+// Default returns the registry with the built-in rules
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a rule to the default registry. It is meant to be called from init functions
+// of packages that provide site-specific rules.
+func Register(rule Rule) error {
+	return defaultRegistry.Register(rule)
+}
+
+// NewBuiltinRegistry creates a registry with only the built-in rules
+func NewBuiltinRegistry() *Registry {
+	r := NewRegistry()
+	for _, rule := range []Rule{
+		RuleFunc{RuleName: RuleAlertLevel, Func: single(checkAlertLevel)},
+		RuleFunc{RuleName: RuleThreshold1h, Func: single(checkThresholdExceeded(averaging.OneHour))},
+		RuleFunc{RuleName: RuleThreshold8h, Func: single(checkThresholdExceeded(averaging.EightHours))},
+		RuleFunc{RuleName: RuleThreshold24h, Func: single(checkThresholdExceeded(averaging.TwentyFourHours))},
+		RuleFunc{RuleName: RuleStatisticalOutlier, Func: single(checkStatisticalOutlier)},
+		RuleFunc{RuleName: RuleSpike, Func: single(checkSpikeDetection)},
+		RuleFunc{RuleName: RuleGeographicInconsistency, Func: single(checkGeographicInconsistency)},
+	} {
+		if err := r.Register(rule); err != nil {
+			panic(err) // Built-in rules have distinct names
+		}
+	}
+	return r
+}
+
+// single adapts a check that finds at most one anomaly
+func single(check func(data *models.AirQualityData, ctx Context) *models.Anomaly) func(*models.AirQualityData, Context) ([]*models.Anomaly, error) {
+	return func(data *models.AirQualityData, ctx Context) ([]*models.Anomaly, error) {
+		return findings(check(data, ctx)), nil
+	}
+}
+
+// findings returns a single finding as a list, or nil when there is none
+func findings(anomaly *models.Anomaly) []*models.Anomaly {
+	if anomaly == nil {
+		return nil
+	}
+	return []*models.Anomaly{anomaly}
+}
@@ -0,0 +1,75 @@
+package anomaly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewBuiltinRegistry()
+
+	if err := registry.Register(RuleFunc{RuleName: RuleSpike, Func: nil}); err == nil {
+		t.Errorf("Expected an error when registering a rule name twice")
+	}
+
+	if err := registry.DisableAll("spike, geographic-inconsistency:PM10"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := registry.SetEnabled(RuleSpike, "NO2", true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		rule      string
+		parameter string
+		expected  bool
+	}{
+		{RuleAlertLevel, "PM10", true},
+		{RuleThreshold24h, "PM10", true},
+		{RuleSpike, "PM10", false},
+		{RuleSpike, "NO2", true},
+		{RuleGeographicInconsistency, "PM10", false},
+		{RuleGeographicInconsistency, "PM2.5", true},
+	}
+	for _, tc := range tests {
+		if registry.Enabled(tc.rule, tc.parameter) != tc.expected {
+			t.Errorf("Expected rule %s enabled for %s to be %v", tc.rule, tc.parameter, tc.expected)
+		}
+	}
+
+	rules := registry.Rules("PM10")
+	if len(rules) != 5 || rules[0].Name() != RuleAlertLevel || rules[4].Name() != RuleStatisticalOutlier {
+		t.Errorf("Expected alert-level, threshold and statistical-outlier rules for PM10, got %v", rules)
+	}
+
+	for _, value := range []string{"unknown", ":PM10"} {
+		if err := registry.DisableAll(value); err == nil {
+			t.Errorf("Expected an error for disabled rules %q", value)
+		}
+	}
+}
+
+func TestDetectRunsCustomRules(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(RuleFunc{RuleName: "failing", Func: func(*models.AirQualityData, Context) ([]*models.Anomaly, error) {
+		return nil, errors.New("site data unavailable")
+	}})
+	registry.Register(RuleFunc{RuleName: "negative-value", Func: func(data *models.AirQualityData, ctx Context) ([]*models.Anomaly, error) {
+		if data.Value >= 0 {
+			return nil, nil
+		}
+		return []*models.Anomaly{models.NewAnomalyFromData("NegativeValue", data)}, nil
+	}})
+
+	detector := NewDetectorWithRegistry(registry)
+	anomalies, err := detector.Detect(&models.AirQualityData{Parameter: "NO2", Value: -3, Timestamp: time.Now()}, Context{})
+	if err == nil {
+		t.Errorf("Expected the error of the failing rule")
+	}
+	if len(anomalies) != 1 || anomalies[0].Type != "NegativeValue" {
+		t.Errorf("Expected the finding of the rule after the failing one, got %v", anomalies)
+	}
+}
@@ -0,0 +1,199 @@
+package aqi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/averaging"
+	"github.com/user/airpollution/internal/services/parameters"
+)
+
+// Scale is a national air quality index
+type Scale string
+
+// Supported scales
+const (
+	USEPA     Scale = "us-epa"  // US EPA AQI, 0-500
+	EUCAQI    Scale = "eu-caqi" // European Common Air Quality Index, hourly, 0-100 and above
+	UKDAQI    Scale = "uk-daqi" // UK Daily Air Quality Index, 1-10
+	IndiaNAQI Scale = "in-naqi" // India National Air Quality Index, 0-500
+)
+
+// Scales lists the supported scales
+var Scales = []Scale{USEPA, EUCAQI, UKDAQI, IndiaNAQI}
+
+// ErrInsufficientData is returned when the available means do not meet the requirements of a scale
+var ErrInsufficientData = errors.New("not enough pollutants with complete averages")
+
+// ParseScale finds a supported scale by name
+func ParseScale(name string) (Scale, error) {
+	for _, s := range Scales {
+		if string(s) == strings.ToLower(strings.TrimSpace(name)) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported AQI scale %q", name)
+}
+
+// ParseScales parses scales separated by commas, such as "us-epa,in-naqi"
+func ParseScales(value string) ([]Scale, error) {
+	var scales []Scale
+	for _, name := range strings.Split(value, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		s, err := ParseScale(name)
+		if err != nil {
+			return nil, err
+		}
+		scales = append(scales, s)
+	}
+	return scales, nil
+}
+
+// Means returns the mean of a parameter in its canonical unit over an averaging period, and
+// false when the mean is not available or not complete enough
+type Means func(parameter string, period averaging.Period) (float64, bool)
+
+// band maps a range of concentrations to a range of the index. Stepwise scales have bands with
+// a single index value.
+type band struct {
+	cLow, cHigh float64
+	iLow, iHigh float64
+}
+
+// pollutant is how a scale computes the sub-index of a parameter
+type pollutant struct {
+	parameter string
+	period    averaging.Period
+	// unit is the unit of the breakpoints
+	unit string
+	// round prepares a concentration before the breakpoints are looked up, as each scale states
+	round func(float64) float64
+	bands []band
+	// extrapolate continues the last band beyond its end instead of capping the index there
+	extrapolate bool
+}
+
+// category is a named range of the index, up to and including upTo
+type category struct {
+	upTo  int
+	name  string
+	color string
+}
+
+// scaleDefinition is the breakpoints and categories of a scale
+type scaleDefinition struct {
+	pollutants []pollutant
+	categories []category
+	// validate checks that the pollutants with a sub-index are enough for the scale
+	validate func(found map[string]bool) error
+}
+
+// Compute computes the index of a scale from the means of the pollutants of a location. The
+// index is the highest sub-index, and its parameter is the dominant pollutant. Pollutants
+// without a complete mean over the averaging period of the scale are left out.
+func Compute(scale Scale, means Means) (*models.AirQualityIndex, error) {
+	def, ok := definitions[scale]
+	if !ok {
+		return nil, fmt.Errorf("unsupported AQI scale %q", scale)
+	}
+
+	index := &models.AirQualityIndex{Scale: string(scale)}
+	found := make(map[string]bool)
+	highest := -1.0
+	for _, p := range def.pollutants {
+		mean, ok := means(p.parameter, p.period)
+		if !ok {
+			continue
+		}
+		concentration, err := parameters.Default().Convert(p.parameter, mean, p.unit)
+		if err != nil {
+			return nil, err
+		}
+		if p.round != nil {
+			concentration = p.round(concentration)
+		}
+		sub, ok := p.subIndex(concentration)
+		if !ok {
+			continue // Below the range of this averaging period, such as 1-hour O3 on the US scale
+		}
+
+		found[p.parameter] = true
+		index.Pollutants = append(index.Pollutants, models.PollutantIndex{
+			Parameter:       p.parameter,
+			AveragingPeriod: p.period.Name,
+			Concentration:   concentration,
+			Unit:            p.unit,
+			Index:           sub,
+		})
+		if float64(sub) > highest {
+			highest = float64(sub)
+			index.Value = sub
+			index.DominantPollutant = p.parameter
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, ErrInsufficientData
+	}
+	if def.validate != nil {
+		if err := def.validate(found); err != nil {
+			return nil, err
+		}
+	}
+
+	c := def.category(index.Value)
+	index.Category, index.Color = c.name, c.color
+	return index, nil
+}
+
+// subIndex interpolates the index of a concentration linearly within its band. It reports false
+// for concentrations below the first band.
+func (p pollutant) subIndex(c float64) (int, bool) {
+	if c < p.bands[0].cLow {
+		return 0, false
+	}
+
+	for _, b := range p.bands {
+		if c <= b.cHigh {
+			return b.interpolate(c), true
+		}
+	}
+
+	last := p.bands[len(p.bands)-1]
+	if p.extrapolate {
+		return last.interpolate(c), true
+	}
+	return int(last.iHigh), true
+}
+
+// interpolate returns the index of a concentration on the line through the ends of the band
+func (b band) interpolate(c float64) int {
+	if b.iLow == b.iHigh {
+		return int(b.iLow)
+	}
+	return int(math.Round(b.iLow + (b.iHigh-b.iLow)*(c-b.cLow)/(b.cHigh-b.cLow)))
+}
+
+// category returns the category of an index value
+func (d scaleDefinition) category(value int) category {
+	for _, c := range d.categories {
+		if value <= c.upTo {
+			return c
+		}
+	}
+	return d.categories[len(d.categories)-1]
+}
+
+// truncate returns a function that truncates concentrations to a number of decimals
+func truncate(decimals int) func(float64) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return func(c float64) float64 {
+		// The small offset keeps values such as 0.29 from truncating to 0.28 by rounding error
+		return math.Floor(c*scale+1e-9) / scale
+	}
+}
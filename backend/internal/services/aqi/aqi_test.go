@@ -0,0 +1,142 @@
+package aqi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/user/airpollution/internal/services/averaging"
+	"github.com/user/airpollution/internal/services/parameters"
+)
+
+// mean is a mean of a parameter over a period in the unit it is given in
+type mean struct {
+	parameter string
+	period    averaging.Period
+	value     float64
+	unit      string
+}
+
+// meansOf returns the means as a Means function, converted to the canonical units
+func meansOf(t *testing.T, means ...mean) Means {
+	t.Helper()
+	canonical := make(map[string]float64)
+	for _, m := range means {
+		normalized, err := parameters.Default().Normalize(m.parameter, m.value, m.unit)
+		if err != nil {
+			t.Fatalf("Invalid mean %+v: %v", m, err)
+		}
+		canonical[m.parameter+"/"+m.period.Name] = normalized.Value
+	}
+	return func(parameter string, period averaging.Period) (float64, bool) {
+		v, ok := canonical[parameter+"/"+period.Name]
+		return v, ok
+	}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name     string
+		scale    Scale
+		means    []mean
+		value    int
+		category string
+		dominant string
+	}{
+		{"US EPA PM2.5", USEPA, []mean{{parameters.PM25, averaging.TwentyFourHours, 12.0, ""}}, 56, "Moderate", parameters.PM25},
+		{"US EPA Dominant Pollutant", USEPA, []mean{
+			{parameters.PM25, averaging.TwentyFourHours, 35.0, ""},
+			{parameters.O3, averaging.EightHours, 0.065, "ppm"},
+			{parameters.O3, averaging.OneHour, 0.070, "ppm"},
+		}, 99, "Moderate", parameters.PM25},
+		{"US EPA O3 Hourly", USEPA, []mean{
+			{parameters.O3, averaging.EightHours, 0.065, "ppm"},
+			{parameters.O3, averaging.OneHour, 0.170, "ppm"},
+		}, 157, "Unhealthy", parameters.O3},
+		{"US EPA Capped", USEPA, []mean{{parameters.PM10, averaging.TwentyFourHours, 900, ""}}, 500, "Hazardous", parameters.PM10},
+		{"EU CAQI", EUCAQI, []mean{{parameters.NO2, averaging.OneHour, 150, ""}, {parameters.PM10, averaging.OneHour, 20, ""}}, 63, "Medium", parameters.NO2},
+		{"EU CAQI Above 100", EUCAQI, []mean{{parameters.PM10, averaging.OneHour, 200, ""}}, 106, "Very High", parameters.PM10},
+		{"UK DAQI", UKDAQI, []mean{{parameters.PM25, averaging.TwentyFourHours, 40, ""}, {parameters.NO2, averaging.OneHour, 100, ""}}, 4, "Moderate", parameters.PM25},
+		{"UK DAQI Top Band", UKDAQI, []mean{{parameters.O3, averaging.EightHours, 300, ""}}, 10, "Very High", parameters.O3},
+		{"India NAQI", IndiaNAQI, []mean{
+			{parameters.PM10, averaging.TwentyFourHours, 150, ""},
+			{parameters.PM25, averaging.TwentyFourHours, 45, ""},
+			{parameters.NO2, averaging.TwentyFourHours, 20, ""},
+		}, 133, "Moderate", parameters.PM10},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			index, err := Compute(tc.scale, meansOf(t, tc.means...))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if index.Value != tc.value {
+				t.Errorf("Expected index %d, got %d (%+v)", tc.value, index.Value, index.Pollutants)
+			}
+			if index.Category != tc.category {
+				t.Errorf("Expected category %s, got %s", tc.category, index.Category)
+			}
+			if index.DominantPollutant != tc.dominant {
+				t.Errorf("Expected dominant pollutant %s, got %s", tc.dominant, index.DominantPollutant)
+			}
+		})
+	}
+}
+
+func TestComputeSkipsHourlyOzoneBelowItsRange(t *testing.T) {
+	index, err := Compute(USEPA, meansOf(t,
+		mean{parameters.O3, averaging.EightHours, 0.040, "ppm"},
+		mean{parameters.O3, averaging.OneHour, 0.050, "ppm"},
+	))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(index.Pollutants) != 1 || index.Pollutants[0].AveragingPeriod != "8h" {
+		t.Errorf("Expected only the 8-hour O3 sub-index, got %+v", index.Pollutants)
+	}
+	if index.Pollutants[0].Unit != parameters.PartsPerMillion || index.Pollutants[0].Concentration != 0.040 {
+		t.Errorf("Expected the concentration in ppm truncated to 0.040, got %+v", index.Pollutants[0])
+	}
+}
+
+func TestComputeInsufficientData(t *testing.T) {
+	tests := []struct {
+		name  string
+		scale Scale
+		means []mean
+	}{
+		{"No Means", USEPA, nil},
+		{"Pollutant Not On Scale", UKDAQI, []mean{{parameters.CO, averaging.EightHours, 5, ""}}},
+		{"India NAQI Two Pollutants", IndiaNAQI, []mean{
+			{parameters.PM10, averaging.TwentyFourHours, 150, ""},
+			{parameters.NO2, averaging.TwentyFourHours, 20, ""},
+		}},
+		{"India NAQI Without Particulate Matter", IndiaNAQI, []mean{
+			{parameters.NO2, averaging.TwentyFourHours, 20, ""},
+			{parameters.SO2, averaging.TwentyFourHours, 20, ""},
+			{parameters.CO, averaging.EightHours, 1, ""},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Compute(tc.scale, meansOf(t, tc.means...)); !errors.Is(err, ErrInsufficientData) {
+				t.Errorf("Expected ErrInsufficientData, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseScales(t *testing.T) {
+	scales, err := ParseScales("us-epa, IN-NAQI")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(scales) != 2 || scales[0] != USEPA || scales[1] != IndiaNAQI {
+		t.Errorf("Expected us-epa and in-naqi, got %v", scales)
+	}
+
+	if _, err := ParseScales("us-epa,aqhi"); err == nil {
+		t.Errorf("Expected an error for an unsupported scale")
+	}
+}
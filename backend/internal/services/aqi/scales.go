@@ -0,0 +1,158 @@
+package aqi
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/user/airpollution/internal/services/averaging"
+	"github.com/user/airpollution/internal/services/parameters"
+)
+
+// definitions holds the breakpoints of the supported scales. Concentrations are in the unit of
+// each pollutant; the means are converted from the canonical unit of the parameter.
+var definitions = map[Scale]scaleDefinition{
+	// US EPA AQI with the 2024 PM2.5 breakpoints. O3 uses the 8-hour mean and, from 0.125 ppm,
+	// the 1-hour mean, whichever gives the higher index. SO2 uses the 1-hour mean at all levels.
+	USEPA: {
+		pollutants: []pollutant{
+			{parameter: parameters.PM25, period: averaging.TwentyFourHours, unit: parameters.MicrogramsPerCubicMeter, round: truncate(1),
+				bands: epaBands(0, 9.0, 9.1, 35.4, 35.5, 55.4, 55.5, 125.4, 125.5, 225.4, 225.5, 325.4)},
+			{parameter: parameters.PM10, period: averaging.TwentyFourHours, unit: parameters.MicrogramsPerCubicMeter, round: truncate(0),
+				bands: epaBands(0, 54, 55, 154, 155, 254, 255, 354, 355, 424, 425, 604)},
+			{parameter: parameters.O3, period: averaging.EightHours, unit: parameters.PartsPerMillion, round: truncate(3),
+				bands: epaBands(0, 0.054, 0.055, 0.070, 0.071, 0.085, 0.086, 0.105, 0.106, 0.200)},
+			{parameter: parameters.O3, period: averaging.OneHour, unit: parameters.PartsPerMillion, round: truncate(3),
+				bands: []band{{0.125, 0.164, 101, 150}, {0.165, 0.204, 151, 200}, {0.205, 0.404, 201, 300}, {0.405, 0.604, 301, 500}}},
+			{parameter: parameters.CO, period: averaging.EightHours, unit: parameters.PartsPerMillion, round: truncate(1),
+				bands: epaBands(0, 4.4, 4.5, 9.4, 9.5, 12.4, 12.5, 15.4, 15.5, 30.4, 30.5, 50.4)},
+			{parameter: parameters.SO2, period: averaging.OneHour, unit: parameters.PartsPerBillion, round: truncate(0),
+				bands: epaBands(0, 35, 36, 75, 76, 185, 186, 304, 305, 604, 605, 1004)},
+			{parameter: parameters.NO2, period: averaging.OneHour, unit: parameters.PartsPerBillion, round: truncate(0),
+				bands: epaBands(0, 53, 54, 100, 101, 360, 361, 649, 650, 1249, 1250, 2049)},
+		},
+		categories: []category{
+			{50, "Good", "#00E400"},
+			{100, "Moderate", "#FFFF00"},
+			{150, "Unhealthy for Sensitive Groups", "#FF7E00"},
+			{200, "Unhealthy", "#FF0000"},
+			{300, "Very Unhealthy", "#8F3F97"},
+			{500, "Hazardous", "#7E0023"},
+		},
+	},
+
+	// Hourly CAQI of background stations; CO uses the 8-hour mean. Values above 100 continue
+	// the last band.
+	EUCAQI: {
+		pollutants: []pollutant{
+			{parameter: parameters.NO2, period: averaging.OneHour, unit: parameters.MicrogramsPerCubicMeter, extrapolate: true, bands: caqiBands(50, 100, 200, 400)},
+			{parameter: parameters.PM10, period: averaging.OneHour, unit: parameters.MicrogramsPerCubicMeter, extrapolate: true, bands: caqiBands(25, 50, 90, 180)},
+			{parameter: parameters.PM25, period: averaging.OneHour, unit: parameters.MicrogramsPerCubicMeter, extrapolate: true, bands: caqiBands(15, 30, 55, 110)},
+			{parameter: parameters.O3, period: averaging.OneHour, unit: parameters.MicrogramsPerCubicMeter, extrapolate: true, bands: caqiBands(60, 120, 180, 240)},
+			{parameter: parameters.CO, period: averaging.EightHours, unit: parameters.MilligramsPerCubicMeter, extrapolate: true, bands: caqiBands(5, 7.5, 10, 20)},
+			{parameter: parameters.SO2, period: averaging.OneHour, unit: parameters.MicrogramsPerCubicMeter, extrapolate: true, bands: caqiBands(50, 100, 350, 500)},
+		},
+		categories: []category{
+			{24, "Very Low", "#79BC6A"},
+			{49, "Low", "#BBCF4C"},
+			{74, "Medium", "#EEC20B"},
+			{99, "High", "#F29305"},
+			{math.MaxInt32, "Very High", "#E8416F"},
+		},
+	},
+
+	// UK DAQI bands. SO2 is defined on 15-minute means; the 1-hour mean is used instead.
+	UKDAQI: {
+		pollutants: []pollutant{
+			{parameter: parameters.O3, period: averaging.EightHours, unit: parameters.MicrogramsPerCubicMeter, round: math.Round,
+				bands: daqiBands(33, 66, 100, 120, 140, 160, 187, 213, 240)},
+			{parameter: parameters.NO2, period: averaging.OneHour, unit: parameters.MicrogramsPerCubicMeter, round: math.Round,
+				bands: daqiBands(67, 134, 200, 267, 334, 400, 467, 534, 600)},
+			{parameter: parameters.SO2, period: averaging.OneHour, unit: parameters.MicrogramsPerCubicMeter, round: math.Round,
+				bands: daqiBands(88, 177, 266, 354, 443, 532, 710, 887, 1064)},
+			{parameter: parameters.PM25, period: averaging.TwentyFourHours, unit: parameters.MicrogramsPerCubicMeter, round: math.Round,
+				bands: daqiBands(11, 23, 35, 41, 47, 53, 58, 64, 70)},
+			{parameter: parameters.PM10, period: averaging.TwentyFourHours, unit: parameters.MicrogramsPerCubicMeter, round: math.Round,
+				bands: daqiBands(16, 33, 50, 58, 66, 75, 83, 91, 100)},
+		},
+		categories: []category{
+			{1, "Low", "#9CFF9C"},
+			{2, "Low", "#31FF00"},
+			{3, "Low", "#31CF00"},
+			{4, "Moderate", "#FFFF00"},
+			{5, "Moderate", "#FFCF00"},
+			{6, "Moderate", "#FF9A00"},
+			{7, "High", "#FF6464"},
+			{8, "High", "#FF0000"},
+			{9, "High", "#990000"},
+			{10, "Very High", "#CE30FF"},
+		},
+	},
+
+	// India NAQI. The severe band, which has no upper breakpoint, is as wide as the band below
+	// it and the index is capped at 500.
+	IndiaNAQI: {
+		pollutants: []pollutant{
+			{parameter: parameters.PM10, period: averaging.TwentyFourHours, unit: parameters.MicrogramsPerCubicMeter, bands: naqiBands(50, 100, 250, 350, 430)},
+			{parameter: parameters.PM25, period: averaging.TwentyFourHours, unit: parameters.MicrogramsPerCubicMeter, bands: naqiBands(30, 60, 90, 120, 250)},
+			{parameter: parameters.NO2, period: averaging.TwentyFourHours, unit: parameters.MicrogramsPerCubicMeter, bands: naqiBands(40, 80, 180, 280, 400)},
+			{parameter: parameters.O3, period: averaging.EightHours, unit: parameters.MicrogramsPerCubicMeter, bands: naqiBands(50, 100, 168, 208, 748)},
+			{parameter: parameters.CO, period: averaging.EightHours, unit: parameters.MilligramsPerCubicMeter, bands: naqiBands(1, 2, 10, 17, 34)},
+			{parameter: parameters.SO2, period: averaging.TwentyFourHours, unit: parameters.MicrogramsPerCubicMeter, bands: naqiBands(40, 80, 380, 800, 1600)},
+		},
+		categories: []category{
+			{50, "Good", "#00B050"},
+			{100, "Satisfactory", "#92D050"},
+			{200, "Moderate", "#FFFF00"},
+			{300, "Poor", "#FF9900"},
+			{400, "Very Poor", "#FF0000"},
+			{500, "Severe", "#C00000"},
+		},
+		// The NAQI needs at least three pollutants, one of them particulate matter
+		validate: func(found map[string]bool) error {
+			if len(found) < 3 || !(found[parameters.PM25] || found[parameters.PM10]) {
+				return fmt.Errorf("%w: the India NAQI needs three pollutants including PM2.5 or PM10", ErrInsufficientData)
+			}
+			return nil
+		},
+	},
+}
+
+// epaBands creates the bands of the US EPA index from pairs of low and high breakpoints, for
+// the index ranges 0-50, 51-100, 101-150, 151-200, 201-300 and 301-500
+func epaBands(breakpoints ...float64) []band {
+	indices := [][2]float64{{0, 50}, {51, 100}, {101, 150}, {151, 200}, {201, 300}, {301, 500}}
+	bands := make([]band, 0, len(breakpoints)/2)
+	for i := 0; i+1 < len(breakpoints); i += 2 {
+		bands = append(bands, band{breakpoints[i], breakpoints[i+1], indices[i/2][0], indices[i/2][1]})
+	}
+	return bands
+}
+
+// caqiBands creates the bands of the CAQI from the concentrations at index 25, 50, 75 and 100
+func caqiBands(c25, c50, c75, c100 float64) []band {
+	return []band{{0, c25, 0, 25}, {c25, c50, 25, 50}, {c50, c75, 50, 75}, {c75, c100, 75, 100}}
+}
+
+// daqiBands creates the bands of the DAQI from the highest concentration of indices 1 to 9;
+// index 10 has no upper bound
+func daqiBands(highs ...float64) []band {
+	bands := make([]band, 0, len(highs)+1)
+	low := 0.0
+	for i, high := range highs {
+		bands = append(bands, band{low, high, float64(i + 1), float64(i + 1)})
+		low = high
+	}
+	return append(bands, band{low, math.Inf(1), 10, 10})
+}
+
+// naqiBands creates the bands of the NAQI from the concentrations at index 50, 100, 200, 300 and 400
+func naqiBands(c50, c100, c200, c300, c400 float64) []band {
+	return []band{
+		{0, c50, 0, 50},
+		{c50, c100, 50, 100},
+		{c100, c200, 100, 200},
+		{c200, c300, 200, 300},
+		{c300, c400, 300, 400},
+		{c400, c400 + (c400 - c300), 400, 500},
+	}
+}
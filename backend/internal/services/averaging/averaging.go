@@ -0,0 +1,252 @@
+package averaging
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+// Period is an averaging period of air quality standards, a whole number of clock hours
+type Period struct {
+	Name  string
+	Hours int
+}
+
+// Averaging periods used by the WHO guidelines and the national standards
+var (
+	OneHour         = Period{Name: "1h", Hours: 1}
+	EightHours      = Period{Name: "8h", Hours: 8}
+	TwentyFourHours = Period{Name: "24h", Hours: 24}
+)
+
+// Periods lists the supported averaging periods from the shortest
+var Periods = []Period{OneHour, EightHours, TwentyFourHours}
+
+// String returns the name of the period
+func (p Period) String() string {
+	return p.Name
+}
+
+const (
+	// DefaultRetention keeps the hours of the longest averaging period
+	DefaultRetention = 24 * time.Hour
+	// DefaultMinCompleteness is the share of valid hours a mean needs, 75% as in EU Directive 2008/50/EC
+	DefaultMinCompleteness = 0.75
+	// DefaultMinHourReadings is the number of readings an hour needs to be valid
+	DefaultMinHourReadings = 1
+)
+
+// Requirement is the data completeness a mean needs. Zero values select the defaults.
+type Requirement struct {
+	// MinCompleteness is the share of the hours of the period that must be valid, in (0, 1]
+	MinCompleteness float64
+	// MinHourReadings is the number of readings an hour needs to count as valid
+	MinHourReadings int
+}
+
+// Mean is the mean of a parameter over an averaging period
+type Mean struct {
+	Value float64
+	// ValidHours is the number of hours of the period with enough readings
+	ValidHours int
+	Period     Period
+}
+
+// Config configures a Store. Zero values select the defaults.
+type Config struct {
+	// Retention is how long hourly values are kept, measured from their hour; it should cover
+	// the longest averaging period that is asked for
+	Retention time.Duration
+}
+
+// Store keeps hourly sums of the readings of each parameter at each location, so that means
+// over averaging periods are computed without querying the stored time series for every
+// reading. Store is safe for concurrent use.
+//
+// The hours are kept in memory: they are warmed up from the database on start and then only see
+// the readings of this instance in real time. Readings routed to the backfill topic, late readings
+// and readings processed by other instances are not included, so the means are only complete
+// with a single processor instance.
+type Store struct {
+	mu        sync.Mutex
+	config    Config
+	series    map[seriesKey]*series
+	lastSweep int64
+	now       func() time.Time
+}
+
+// seriesKey identifies the series of a parameter at a location
+type seriesKey struct {
+	parameter string
+	location  string
+}
+
+// series holds the readings of a parameter at a location by clock hour
+type series struct {
+	hours map[int64]*hour
+	// above records the periods whose mean was above its limit when it was last checked
+	above map[Period]bool
+}
+
+// hour holds the running sum of the readings of one clock hour
+type hour struct {
+	count int
+	sum   float64
+}
+
+// New creates an empty store
+func New(config Config) *Store {
+	if config.Retention <= 0 {
+		config.Retention = DefaultRetention
+	}
+
+	return &Store{
+		config: config,
+		series: make(map[seriesKey]*series),
+		now:    time.Now,
+	}
+}
+
+// Location identifies where a reading was measured: its sensor, or its coordinates rounded to
+// about 10 meters for readings without a sensor ID
+func Location(data *models.AirQualityData) string {
+	if data.SensorID != "" {
+		return data.SensorID
+	}
+	return fmt.Sprintf("%.4f,%.4f", data.Latitude, data.Longitude)
+}
+
+// Cutoff returns the time before which readings are no longer kept
+func (s *Store) Cutoff() time.Time {
+	return s.now().Add(-s.config.Retention)
+}
+
+// Add adds a reading to the series of its parameter and location. It reports false for
+// readings older than the retention or without a finite value.
+func (s *Store) Add(data *models.AirQualityData) bool {
+	if math.IsNaN(data.Value) || math.IsInf(data.Value, 0) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := hourOf(s.Cutoff())
+	h := hourOf(data.Timestamp)
+	if h < cutoff {
+		return false
+	}
+	s.sweep(cutoff)
+
+	key := seriesKey{parameter: data.Parameter, location: Location(data)}
+	sr, ok := s.series[key]
+	if !ok {
+		sr = &series{hours: make(map[int64]*hour)}
+		s.series[key] = sr
+	}
+	hr, ok := sr.hours[h]
+	if !ok {
+		hr = &hour{}
+		sr.hours[h] = hr
+	}
+	hr.count++
+	hr.sum += data.Value
+	return true
+}
+
+// Mean returns the mean of a parameter at a location over the clock hours of a period that end
+// with the hour of end, as the mean of the hourly means. It reports false when the hours with
+// enough readings do not meet the completeness requirement.
+func (s *Store) Mean(parameter, location string, period Period, end time.Time, req Requirement) (Mean, bool) {
+	if req.MinCompleteness <= 0 {
+		req.MinCompleteness = DefaultMinCompleteness
+	}
+	if req.MinHourReadings <= 0 {
+		req.MinHourReadings = DefaultMinHourReadings
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sr, ok := s.series[seriesKey{parameter: parameter, location: location}]
+	if !ok {
+		return Mean{}, false
+	}
+
+	last := hourOf(end)
+	valid := 0
+	var sum float64
+	for h := last - int64(period.Hours) + 1; h <= last; h++ {
+		hr, ok := sr.hours[h]
+		if !ok || hr.count < req.MinHourReadings {
+			continue
+		}
+		valid++
+		sum += hr.sum / float64(hr.count)
+	}
+
+	// Compare counts rather than shares so that 6 of 8 hours meet 75% despite rounding
+	if valid == 0 || float64(valid) < req.MinCompleteness*float64(period.Hours)-1e-9 {
+		return Mean{}, false
+	}
+	return Mean{Value: sum / float64(valid), ValidHours: valid, Period: period}, true
+}
+
+// Crossed records whether the mean of a parameter at a location over a period is above its limit
+// and reports whether it crossed the limit, that is whether it is above and was not when it was
+// last recorded. Series without readings start below their limits.
+func (s *Store) Crossed(parameter, location string, period Period, above bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sr, ok := s.series[seriesKey{parameter: parameter, location: location}]
+	if !ok {
+		return above
+	}
+	if sr.above == nil {
+		sr.above = make(map[Period]bool)
+	}
+	was := sr.above[period]
+	sr.above[period] = above
+	return above && !was
+}
+
+// Len returns the number of series
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.series)
+}
+
+// sweep drops hours before cutoff from all series once per hour, and series left empty, so
+// that locations that stopped reporting do not hold memory
+func (s *Store) sweep(cutoff int64) {
+	if cutoff <= s.lastSweep {
+		return
+	}
+	s.lastSweep = cutoff
+
+	for key, sr := range s.series {
+		for h := range sr.hours {
+			if h < cutoff {
+				delete(sr.hours, h)
+			}
+		}
+		if len(sr.hours) == 0 {
+			delete(s.series, key)
+		}
+	}
+}
+
+// hourOf returns the clock hour of a timestamp as hours since 1970
+func hourOf(t time.Time) int64 {
+	n := t.Unix()
+	h := n / 3600
+	if n%3600 < 0 {
+		h-- // Round down for timestamps before 1970
+	}
+	return h
+}
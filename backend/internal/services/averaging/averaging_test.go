@@ -0,0 +1,126 @@
+package averaging
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+// newTestStore creates a store whose clock is at now
+func newTestStore(now time.Time) *Store {
+	s := New(Config{})
+	s.now = func() time.Time { return now }
+	return s
+}
+
+// reading creates a PM2.5 reading of sensor s1
+func reading(value float64, at time.Time) *models.AirQualityData {
+	return &models.AirQualityData{SensorID: "s1", Parameter: "PM2.5", Value: value, Timestamp: at}
+}
+
+func TestMean(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := newTestStore(now)
+
+	// Two readings in the current hour and one in each of the five hours before it
+	s.Add(reading(10, now))
+	s.Add(reading(30, now.Add(-10*time.Minute)))
+	for h := 1; h <= 5; h++ {
+		s.Add(reading(float64(h), now.Add(-time.Duration(h)*time.Hour)))
+	}
+
+	tests := []struct {
+		name     string
+		period   Period
+		req      Requirement
+		ok       bool
+		expected float64
+	}{
+		{"Hourly Mean", OneHour, Requirement{}, true, 20},
+		{"Hour Needs Readings", OneHour, Requirement{MinHourReadings: 3}, false, 0},
+		{"Six Of Eight Hours", EightHours, Requirement{}, true, (20.0 + 1 + 2 + 3 + 4 + 5) / 6},
+		{"Incomplete Day", TwentyFourHours, Requirement{}, false, 0},
+		{"Relaxed Day", TwentyFourHours, Requirement{MinCompleteness: 0.25}, true, (20.0 + 1 + 2 + 3 + 4 + 5) / 6},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mean, ok := s.Mean("PM2.5", "s1", tc.period, now, tc.req)
+			if ok != tc.ok {
+				t.Fatalf("Expected ok %v, got %v", tc.ok, ok)
+			}
+			if ok && math.Abs(mean.Value-tc.expected) > 1e-9 {
+				t.Errorf("Expected mean %v, got %v", tc.expected, mean.Value)
+			}
+		})
+	}
+}
+
+func TestMeanSeparatesLocationsAndParameters(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := newTestStore(now)
+
+	s.Add(reading(10, now))
+	s.Add(&models.AirQualityData{SensorID: "s2", Parameter: "PM2.5", Value: 50, Timestamp: now})
+	s.Add(&models.AirQualityData{SensorID: "s1", Parameter: "PM10", Value: 70, Timestamp: now})
+
+	if mean, ok := s.Mean("PM2.5", "s1", OneHour, now, Requirement{}); !ok || mean.Value != 10 {
+		t.Errorf("Expected mean 10 for s1, got %v (%v)", mean.Value, ok)
+	}
+	if _, ok := s.Mean("NO2", "s1", OneHour, now, Requirement{}); ok {
+		t.Errorf("Expected no mean for a parameter without readings")
+	}
+}
+
+func TestCrossed(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := newTestStore(now)
+	s.Add(reading(10, now))
+
+	steps := []struct {
+		period   Period
+		above    bool
+		expected bool
+	}{
+		{OneHour, false, false},
+		{OneHour, true, true},
+		{OneHour, true, false},
+		{TwentyFourHours, true, true},
+		{OneHour, false, false},
+		{OneHour, true, true},
+	}
+	for i, step := range steps {
+		if crossed := s.Crossed("PM2.5", "s1", step.period, step.above); crossed != step.expected {
+			t.Errorf("Step %d: expected crossed %v for %s above %v, got %v", i, step.expected, step.period, step.above, crossed)
+		}
+	}
+}
+
+func TestAddEvictsOldHours(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := newTestStore(now)
+
+	if s.Add(reading(10, now.Add(-25*time.Hour))) {
+		t.Errorf("Expected a reading older than the retention to be rejected")
+	}
+	if !s.Add(reading(10, now.Add(-23*time.Hour))) {
+		t.Errorf("Expected a reading inside the retention to be added")
+	}
+
+	s.now = func() time.Time { return now.Add(48 * time.Hour) }
+	s.Add(&models.AirQualityData{SensorID: "s2", Parameter: "PM2.5", Value: 1, Timestamp: now.Add(48 * time.Hour)})
+	if s.Len() != 1 {
+		t.Errorf("Expected the series of a silent location to be dropped, got %d series", s.Len())
+	}
+}
+
+func TestLocation(t *testing.T) {
+	if got := Location(&models.AirQualityData{SensorID: "s1", Latitude: 41}); got != "s1" {
+		t.Errorf("Expected sensor ID location, got %s", got)
+	}
+	if got := Location(&models.AirQualityData{Latitude: 41.01501, Longitude: 28.97899}); got != "41.0150,28.9790" {
+		t.Errorf("Expected rounded coordinates, got %s", got)
+	}
+}
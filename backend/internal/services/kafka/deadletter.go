@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DeadLetterTopic carries messages the processor gave up on, with the reason in their headers
+const DeadLetterTopic = "dead-letter-air-data"
+
+// Headers describing why a message was dead-lettered
+const (
+	headerError           = "dead-letter-error"
+	headerSourceTopic     = "dead-letter-source-topic"
+	headerSourcePartition = "dead-letter-source-partition"
+	headerSourceOffset    = "dead-letter-source-offset"
+	headerAttempts        = "dead-letter-attempts"
+	headerFailedAt        = "dead-letter-failed-at"
+)
+
+// DeadLetter is a message that could not be processed. Value is the original message, unchanged,
+// so that it can be re-injected into its source topic once the cause is fixed.
+type DeadLetter struct {
+	SourceTopic     string    `json:"source_topic"`
+	SourcePartition int       `json:"source_partition"`
+	SourceOffset    int64     `json:"source_offset"`
+	Error           string    `json:"error"`
+	Attempts        int       `json:"attempts"`
+	FailedAt        time.Time `json:"failed_at"`
+	Value           []byte    `json:"-"`
+
+	// Partition and Offset locate the dead letter in the dead-letter topic when it was read from there
+	Partition int   `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+// NewDeadLetter describes a fetched message that failed after a number of attempts
+func NewDeadLetter(msg *Message, cause error, attempts int) *DeadLetter {
+	return &DeadLetter{
+		SourceTopic:     msg.Topic,
+		SourcePartition: msg.Partition,
+		SourceOffset:    msg.Offset,
+		Error:           cause.Error(),
+		Attempts:        attempts,
+		FailedAt:        time.Now().UTC(),
+		Value:           msg.Value,
+	}
+}
+
+// ProduceDeadLetter writes a dead letter to the topic of the producer with retries
+func (p *Producer) ProduceDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	message := deadLetterMessage(dl)
+
+	// Retry logic
+	maxRetries := 3
+	var lastErr error
+	for i := 0; i < maxRetries; i++ {
+		err := p.writer.WriteMessages(ctx, message)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		// Exponential backoff: 100ms, 200ms, 400ms
+		backoff := time.Duration(100*(1<<i)) * time.Millisecond
+		time.Sleep(backoff)
+	}
+
+	return fmt.Errorf("error writing dead letter to Kafka after %d retries: %w", maxRetries, lastErr)
+}
+
+// deadLetterMessage encodes a dead letter as the original value with headers describing the failure
+func deadLetterMessage(dl *DeadLetter) kafka.Message {
+	return kafka.Message{
+		Value: dl.Value,
+		Headers: []kafka.Header{
+			{Key: headerError, Value: []byte(dl.Error)},
+			{Key: headerSourceTopic, Value: []byte(dl.SourceTopic)},
+			{Key: headerSourcePartition, Value: []byte(strconv.Itoa(dl.SourcePartition))},
+			{Key: headerSourceOffset, Value: []byte(strconv.FormatInt(dl.SourceOffset, 10))},
+			{Key: headerAttempts, Value: []byte(strconv.Itoa(dl.Attempts))},
+			{Key: headerFailedAt, Value: []byte(dl.FailedAt.Format(time.RFC3339Nano))},
+		},
+	}
+}
+
+// parseDeadLetter reads a dead letter from a message of the dead-letter topic
+func parseDeadLetter(msg kafka.Message) (*DeadLetter, error) {
+	dl := &DeadLetter{
+		Value:     msg.Value,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	}
+
+	var err error
+	for _, header := range msg.Headers {
+		value := string(header.Value)
+		switch header.Key {
+		case headerError:
+			dl.Error = value
+		case headerSourceTopic:
+			dl.SourceTopic = value
+		case headerSourcePartition:
+			dl.SourcePartition, err = strconv.Atoi(value)
+		case headerSourceOffset:
+			dl.SourceOffset, err = strconv.ParseInt(value, 10, 64)
+		case headerAttempts:
+			dl.Attempts, err = strconv.Atoi(value)
+		case headerFailedAt:
+			dl.FailedAt, err = time.Parse(time.RFC3339Nano, value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid header %s: %w", header.Key, err)
+		}
+	}
+
+	if dl.SourceTopic == "" {
+		return nil, errors.New("missing source topic header")
+	}
+	return dl, nil
+}
+
+// ReadDeadLetters calls fn for every message in the dead-letter topic, partition by partition,
+// up to the last message at the time of the call. It does not commit offsets, so reading the
+// topic does not change it.
+func ReadDeadLetters(ctx context.Context, brokers []string, fn func(*DeadLetter) error) error {
+	conn, err := kafka.DialContext(ctx, "tcp", brokers[0])
+	if err != nil {
+		return fmt.Errorf("error connecting to Kafka: %w", err)
+	}
+	partitions, err := conn.ReadPartitions(DeadLetterTopic)
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("error reading partitions of %s: %w", DeadLetterTopic, err)
+	}
+
+	for _, partition := range partitions {
+		if err := readDeadLetterPartition(ctx, brokers, partition.ID, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDeadLetterPartition calls fn for every message of one partition of the dead-letter topic
+func readDeadLetterPartition(ctx context.Context, brokers []string, partition int, fn func(*DeadLetter) error) error {
+	leader, err := kafka.DialLeader(ctx, "tcp", brokers[0], DeadLetterTopic, partition)
+	if err != nil {
+		return fmt.Errorf("error connecting to leader of partition %d: %w", partition, err)
+	}
+	first, last, err := leader.ReadOffsets()
+	leader.Close()
+	if err != nil {
+		return fmt.Errorf("error reading offsets of partition %d: %w", partition, err)
+	}
+	if first >= last {
+		return nil // Empty partition
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     DeadLetterTopic,
+		Partition: partition,
+		MaxBytes:  10e6, // 10MB
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(first); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("error reading partition %d: %w", partition, err)
+		}
+
+		dl, err := parseDeadLetter(msg)
+		if err != nil {
+			return fmt.Errorf("invalid dead letter at partition %d offset %d: %w", partition, msg.Offset, err)
+		}
+		if err := fn(dl); err != nil {
+			return err
+		}
+		if msg.Offset >= last-1 {
+			return nil
+		}
+	}
+}
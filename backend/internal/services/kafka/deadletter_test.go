@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDeadLetterRoundTrip(t *testing.T) {
+	msg := &Message{Topic: RawAirDataTopic, Partition: 2, Offset: 1234, Value: []byte(`{"parameter": `)}
+	dl := NewDeadLetter(msg, errors.New("unexpected end of JSON input"), 1)
+
+	encoded := deadLetterMessage(dl)
+	encoded.Partition = 0
+	encoded.Offset = 7
+
+	parsed, err := parseDeadLetter(encoded)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsed.SourceTopic != RawAirDataTopic || parsed.SourcePartition != 2 || parsed.SourceOffset != 1234 {
+		t.Errorf("Expected the source of the message, got %+v", parsed)
+	}
+	if parsed.Error != "unexpected end of JSON input" || parsed.Attempts != 1 || !parsed.FailedAt.Equal(dl.FailedAt) {
+		t.Errorf("Expected the failure of the message, got %+v", parsed)
+	}
+	if string(parsed.Value) != string(msg.Value) || parsed.Offset != 7 {
+		t.Errorf("Expected the original value at offset 7, got %q at %d", parsed.Value, parsed.Offset)
+	}
+}
+
+func TestParseDeadLetterInvalidHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+	}{
+		{"Missing Source Topic", nil},
+		{"Invalid Offset", []kafka.Header{{Key: headerSourceTopic, Value: []byte(RawAirDataTopic)}, {Key: headerSourceOffset, Value: []byte("x")}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseDeadLetter(kafka.Message{Headers: tc.headers}); err == nil {
+				t.Errorf("Expected an error for invalid headers")
+			}
+		})
+	}
+}
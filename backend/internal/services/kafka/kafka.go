@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,6 +14,8 @@ import (
 const (
 	RawAirDataTopic    = "raw-air-data"
 	AnomalyAlertsTopic = "anomaly-alerts"
+	// BackfillAirDataTopic carries readings that arrived later than the timestamp policy allows
+	BackfillAirDataTopic = "backfill-air-data"
 )
 
 // Producer handles producing messages to Kafka
@@ -81,6 +84,74 @@ func (p *Producer) ProduceAirQualityData(ctx context.Context, data *models.AirQu
 	return fmt.Errorf("error writing message to Kafka after %d retries: %w", maxRetries, lastErr)
 }
 
+// ProduceAirQualityDataBatch produces several air quality data messages in a single write with retries
+func (p *Producer) ProduceAirQualityDataBatch(ctx context.Context, data []*models.AirQualityData) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	values := make([][]byte, len(data))
+	for i, item := range data {
+		jsonData, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("error marshaling air quality data: %w", err)
+		}
+		values[i] = jsonData
+	}
+
+	return p.ProduceMessages(ctx, values)
+}
+
+// ProduceMessages writes already encoded messages in a single write with retries. When only some
+// of the messages fail, for example because one partition is unavailable, only those are retried,
+// so the messages that were written are not written again.
+func (p *Producer) ProduceMessages(ctx context.Context, values [][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	messages := make([]kafka.Message, len(values))
+	for i, value := range values {
+		messages[i] = kafka.Message{Value: value}
+	}
+
+	// Retry logic
+	maxRetries := 3
+	pending := messages
+	var lastErr error
+	for i := 0; i < maxRetries; i++ {
+		err := p.writer.WriteMessages(ctx, pending...)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		pending = failedMessages(pending, err)
+		// Exponential backoff: 100ms, 200ms, 400ms
+		backoff := time.Duration(100*(1<<i)) * time.Millisecond
+		time.Sleep(backoff)
+	}
+
+	return fmt.Errorf("error writing %d of a batch of %d messages to Kafka after %d retries: %w",
+		len(pending), len(messages), maxRetries, lastErr)
+}
+
+// failedMessages returns the messages of a write that failed: the ones with an error when the
+// writer reports an error per message, or all of them otherwise
+func failedMessages(messages []kafka.Message, err error) []kafka.Message {
+	var writeErrs kafka.WriteErrors
+	if !errors.As(err, &writeErrs) || len(writeErrs) != len(messages) {
+		return messages
+	}
+
+	failed := make([]kafka.Message, 0, writeErrs.Count())
+	for i, writeErr := range writeErrs {
+		if writeErr != nil {
+			failed = append(failed, messages[i])
+		}
+	}
+	return failed
+}
+
 // ProduceAnomaly produces an anomaly message with retries
 func (p *Producer) ProduceAnomaly(ctx context.Context, anomaly *models.Anomaly) error {
 	jsonData, err := json.Marshal(anomaly)
@@ -107,19 +178,45 @@ func (p *Producer) ProduceAnomaly(ctx context.Context, anomaly *models.Anomaly)
 	return fmt.Errorf("error writing message to Kafka after %d retries: %w", maxRetries, lastErr)
 }
 
-// ConsumeAirQualityData consumes air quality data messages
-func (c *Consumer) ConsumeAirQualityData(ctx context.Context) (*models.AirQualityData, error) {
-	msg, err := c.reader.ReadMessage(ctx)
+// Message is a fetched message that is committed explicitly once it has been processed
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Value     []byte
+	message   kafka.Message
+}
+
+// FetchMessage reads the next message without committing its offset
+func (c *Consumer) FetchMessage(ctx context.Context) (*Message, error) {
+	msg, err := c.reader.FetchMessage(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error reading message from Kafka: %w", err)
+		return nil, fmt.Errorf("error fetching message from Kafka: %w", err)
 	}
 
-	var data models.AirQualityData
-	if err := json.Unmarshal(msg.Value, &data); err != nil {
-		return nil, fmt.Errorf("error unmarshaling air quality data: %w", err)
+	return &Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Value:     msg.Value,
+		message:   msg,
+	}, nil
+}
+
+// CommitMessages commits the offsets of the given messages for the consumer group
+func (c *Consumer) CommitMessages(ctx context.Context, msgs ...*Message) error {
+	if len(msgs) == 0 {
+		return nil
 	}
 
-	return &data, nil
+	messages := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		messages[i] = msg.message
+	}
+	if err := c.reader.CommitMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("error committing %d offsets to Kafka: %w", len(messages), err)
+	}
+	return nil
 }
 
 // ConsumeAnomaly consumes anomaly messages
@@ -2,10 +2,13 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
 	"github.com/user/airpollution/internal/models"
 )
 
@@ -144,3 +147,33 @@ func TestMockConsumer(t *testing.T) {
 		t.Errorf("Expected ID %s, got %s", testData.ID, data.ID)
 	}
 }
+
+func TestFailedMessages(t *testing.T) {
+	messages := []kafka.Message{{Value: []byte("a")}, {Value: []byte("b")}, {Value: []byte("c")}}
+	unavailable := errors.New("leader not available")
+
+	tests := []struct {
+		name     string
+		err      error
+		expected []string
+	}{
+		{"Partial Failure", kafka.WriteErrors{nil, unavailable, nil}, []string{"b"}},
+		{"Wrapped Partial Failure", fmt.Errorf("write failed: %w", kafka.WriteErrors{unavailable, nil, unavailable}), []string{"a", "c"}},
+		{"Whole Write Failed", context.DeadlineExceeded, []string{"a", "b", "c"}},
+		{"Mismatched Errors", kafka.WriteErrors{unavailable}, []string{"a", "b", "c"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			failed := failedMessages(messages, tc.err)
+			if len(failed) != len(tc.expected) {
+				t.Fatalf("Expected %d failed messages, got %d", len(tc.expected), len(failed))
+			}
+			for i, msg := range failed {
+				if string(msg.Value) != tc.expected[i] {
+					t.Errorf("Expected failed message %q, got %q", tc.expected[i], msg.Value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"sort"
+	"sync"
+)
+
+// OffsetTracker tracks messages that are processed out of order, so that the offset of a
+// partition is only committed once all earlier messages of the partition have finished
+type OffsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+// partitionKey identifies a partition of a topic
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// partitionOffsets holds the messages of a partition that have not been committed, in fetch order
+type partitionOffsets struct {
+	pending  []*trackedMessage
+	byOffset map[int64]*trackedMessage
+	// committable is the last message whose predecessors have all finished, nil when there is none
+	committable *Message
+}
+
+// trackedMessage is a fetched message and whether it has finished
+type trackedMessage struct {
+	msg  *Message
+	done bool
+}
+
+// NewOffsetTracker creates an empty offset tracker
+func NewOffsetTracker() *OffsetTracker {
+	return &OffsetTracker{
+		partitions: make(map[partitionKey]*partitionOffsets),
+	}
+}
+
+// Track records a fetched message as in flight. Messages must be tracked in fetch order.
+// A message at or before an offset already tracked, as redelivered after a rebalance,
+// restarts tracking of its partition.
+func (t *OffsetTracker) Track(msg *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{topic: msg.Topic, partition: msg.Partition}
+	p, ok := t.partitions[key]
+	if ok && len(p.pending) > 0 && msg.Offset <= p.pending[len(p.pending)-1].msg.Offset {
+		ok = false
+	}
+	if ok && p.committable != nil && msg.Offset <= p.committable.Offset {
+		ok = false
+	}
+	if !ok {
+		p = &partitionOffsets{byOffset: make(map[int64]*trackedMessage)}
+		t.partitions[key] = p
+	}
+
+	tracked := &trackedMessage{msg: msg}
+	p.pending = append(p.pending, tracked)
+	p.byOffset[msg.Offset] = tracked
+}
+
+// Done marks a tracked message as finished. Messages whose partition was restarted are ignored.
+func (t *OffsetTracker) Done(msg *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[partitionKey{topic: msg.Topic, partition: msg.Partition}]
+	if !ok {
+		return
+	}
+	tracked, ok := p.byOffset[msg.Offset]
+	if !ok || tracked.msg != msg {
+		return
+	}
+	tracked.done = true
+
+	// Advance over the finished prefix of the partition
+	for len(p.pending) > 0 && p.pending[0].done {
+		p.committable = p.pending[0].msg
+		delete(p.byOffset, p.committable.Offset)
+		p.pending = p.pending[1:]
+	}
+}
+
+// Committable returns, per partition, the last message whose predecessors have all finished
+// since the previous call, sorted by topic and partition
+func (t *OffsetTracker) Committable() []*Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var msgs []*Message
+	for _, p := range t.partitions {
+		if p.committable != nil {
+			msgs = append(msgs, p.committable)
+			p.committable = nil
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].Topic != msgs[j].Topic {
+			return msgs[i].Topic < msgs[j].Topic
+		}
+		return msgs[i].Partition < msgs[j].Partition
+	})
+	return msgs
+}
+
+// Pending returns the number of tracked messages that have not finished or are waiting for earlier ones
+func (t *OffsetTracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := 0
+	for _, p := range t.partitions {
+		pending += len(p.pending)
+	}
+	return pending
+}
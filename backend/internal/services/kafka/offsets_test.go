@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"testing"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := NewOffsetTracker()
+
+	msgs := make([]*Message, 4)
+	for i := range msgs {
+		msgs[i] = &Message{Topic: RawAirDataTopic, Partition: 0, Offset: int64(10 + i)}
+		tracker.Track(msgs[i])
+	}
+	other := &Message{Topic: RawAirDataTopic, Partition: 1, Offset: 3}
+	tracker.Track(other)
+
+	// A later message finishing first cannot be committed
+	tracker.Done(msgs[1])
+	tracker.Done(msgs[2])
+	if committable := tracker.Committable(); len(committable) != 0 {
+		t.Errorf("Expected nothing to commit, got %d messages", len(committable))
+	}
+
+	// Once the earliest message finishes, the partition advances over all finished messages
+	tracker.Done(msgs[0])
+	tracker.Done(other)
+	committable := tracker.Committable()
+	if len(committable) != 2 || committable[0] != msgs[2] || committable[1] != other {
+		t.Fatalf("Expected offsets 12 of partition 0 and 3 of partition 1, got %+v", committable)
+	}
+	if committable := tracker.Committable(); len(committable) != 0 {
+		t.Errorf("Expected committed messages to be returned once, got %d", len(committable))
+	}
+	if pending := tracker.Pending(); pending != 1 {
+		t.Errorf("Expected 1 pending message, got %d", pending)
+	}
+
+	// A redelivered message restarts the partition and finishing a stale message has no effect
+	redelivered := &Message{Topic: RawAirDataTopic, Partition: 0, Offset: 11}
+	tracker.Track(redelivered)
+	tracker.Done(msgs[3])
+	if committable := tracker.Committable(); len(committable) != 0 {
+		t.Errorf("Expected stale message to be ignored, got %+v", committable)
+	}
+	tracker.Done(redelivered)
+	if committable := tracker.Committable(); len(committable) != 1 || committable[0] != redelivered {
+		t.Errorf("Expected the redelivered message to be committable, got %+v", committable)
+	}
+}
@@ -0,0 +1,225 @@
+package lorawan
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Reading is a parameter value decoded from a payload
+type Reading struct {
+	Parameter string
+	Value     float64
+	// Unit is the unit of Value; empty means the canonical unit of the parameter
+	Unit string
+}
+
+// Decoded is the content of an uplink payload
+type Decoded struct {
+	Readings []Reading
+	// Humidity in percent and Temperature in °C are nil when the payload has none
+	Humidity    *float64
+	Temperature *float64
+	// Location is set by devices with a GPS fix and nil otherwise
+	Location *Location
+}
+
+// Decoder turns the payload of an uplink into readings
+type Decoder interface {
+	Decode(fPort int, payload []byte) (*Decoded, error)
+}
+
+// DecoderFunc adapts a function to the Decoder interface
+type DecoderFunc func(fPort int, payload []byte) (*Decoded, error)
+
+// Decode calls f(fPort, payload)
+func (f DecoderFunc) Decode(fPort int, payload []byte) (*Decoded, error) {
+	return f(fPort, payload)
+}
+
+// Registry maps device profiles to payload decoders
+type Registry struct {
+	mu       sync.RWMutex
+	decoders map[string]Decoder
+}
+
+// NewRegistry creates an empty decoder registry
+func NewRegistry() *Registry {
+	return &Registry{
+		decoders: make(map[string]Decoder),
+	}
+}
+
+// Register adds the decoder of a device profile
+func (r *Registry) Register(profile string, decoder Decoder) error {
+	if profile == "" || decoder == nil {
+		return fmt.Errorf("decoder registration needs a device profile and a decoder")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.decoders[profile]; ok {
+		return fmt.Errorf("device profile %q already has a decoder", profile)
+	}
+	r.decoders[profile] = decoder
+	return nil
+}
+
+// Alias registers the decoder of an existing profile for another device profile, so that
+// profiles named by the network server can share a built-in decoder
+func (r *Registry) Alias(profile, existing string) error {
+	decoder, ok := r.Lookup(existing)
+	if !ok {
+		return fmt.Errorf("no decoder registered for device profile %q", existing)
+	}
+	return r.Register(profile, decoder)
+}
+
+// AliasAll registers aliases given as profile=existing pairs separated by commas, such as
+// "Air Sensor Rev B=compact-v1,acme/aq-200=compact-v1"
+func (r *Registry) AliasAll(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		profile, existing, ok := strings.Cut(entry, "=")
+		profile, existing = strings.TrimSpace(profile), strings.TrimSpace(existing)
+		if !ok || profile == "" || existing == "" {
+			return fmt.Errorf("invalid decoder alias %q, expected profile=decoder", entry)
+		}
+		if err := r.Alias(profile, existing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lookup finds the decoder of a device profile
+func (r *Registry) Lookup(profile string) (Decoder, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	decoder, ok := r.decoders[profile]
+	return decoder, ok
+}
+
+// Profiles returns the registered device profiles sorted by name
+func (r *Registry) Profiles() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	profiles := make([]string, 0, len(r.decoders))
+	for profile := range r.decoders {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// CompactV1 is the device profile of the built-in compact binary format
+const CompactV1 = "compact-v1"
+
+// defaultRegistry holds the built-in decoders and those registered by other packages
+var defaultRegistry = mustNewDefaultRegistry()
+
+// Default returns the registry with the built-in decoders
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a decoder to the default registry. It is meant to be called from init
+// functions of packages that provide decoders for their devices.
+func Register(profile string, decoder Decoder) error {
+	return defaultRegistry.Register(profile, decoder)
+}
+
+// mustNewDefaultRegistry creates the default registry and panics on invalid built-in decoders
+func mustNewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	if err := r.Register(CompactV1, DecoderFunc(DecodeCompactV1)); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// compactField describes a field of the compact format: its size in bytes and how to read it
+type compactField struct {
+	size int
+	read func(b []byte, d *Decoded)
+}
+
+// compactReading reads a big endian unsigned 16-bit value with the given scale as a parameter reading
+func compactReading(parameter, unit string, scale float64) compactField {
+	return compactField{size: 2, read: func(b []byte, d *Decoded) {
+		d.Readings = append(d.Readings, Reading{
+			Parameter: parameter,
+			Value:     float64(binary.BigEndian.Uint16(b)) * scale,
+			Unit:      unit,
+		})
+	}}
+}
+
+// compactFields maps the field types of the compact format to their decoding
+var compactFields = map[byte]compactField{
+	0x01: compactReading("PM2.5", "µg/m³", 0.1),
+	0x02: compactReading("PM10", "µg/m³", 0.1),
+	0x03: compactReading("NO2", "ppb", 1),
+	0x04: compactReading("O3", "ppb", 1),
+	0x05: compactReading("SO2", "ppb", 1),
+	0x06: compactReading("CO", "ppm", 0.01),
+	0x10: {size: 2, read: func(b []byte, d *Decoded) {
+		temperature := float64(int16(binary.BigEndian.Uint16(b))) * 0.1
+		d.Temperature = &temperature
+	}},
+	0x11: {size: 1, read: func(b []byte, d *Decoded) {
+		humidity := float64(b[0]) * 0.5
+		d.Humidity = &humidity
+	}},
+	0x20: {size: 8, read: func(b []byte, d *Decoded) {
+		d.Location = &Location{
+			Latitude:  float64(int32(binary.BigEndian.Uint32(b[0:4]))) * 1e-7,
+			Longitude: float64(int32(binary.BigEndian.Uint32(b[4:8]))) * 1e-7,
+		}
+	}},
+}
+
+// DecodeCompactV1 decodes the compact binary format of our LoRa sensors. The payload is a version
+// byte 0x01 followed by fields of a type byte and a big endian value:
+//
+//	0x01 PM2.5        uint16, 0.1 µg/m³
+//	0x02 PM10         uint16, 0.1 µg/m³
+//	0x03 NO2          uint16, ppb
+//	0x04 O3           uint16, ppb
+//	0x05 SO2          uint16, ppb
+//	0x06 CO           uint16, 0.01 ppm
+//	0x10 temperature  int16, 0.1 °C
+//	0x11 humidity     uint8, 0.5 %
+//	0x20 GPS fix      int32 latitude and int32 longitude, 1e-7 degrees
+func DecodeCompactV1(_ int, payload []byte) (*Decoded, error) {
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+	if payload[0] != 0x01 {
+		return nil, fmt.Errorf("unsupported compact payload version %d", payload[0])
+	}
+
+	decoded := &Decoded{}
+	for i := 1; i < len(payload); {
+		fieldType := payload[i]
+		field, ok := compactFields[fieldType]
+		if !ok {
+			return nil, fmt.Errorf("unknown field type 0x%02x at byte %d", fieldType, i)
+		}
+		if i+1+field.size > len(payload) {
+			return nil, fmt.Errorf("truncated field 0x%02x at byte %d", fieldType, i)
+		}
+		field.read(payload[i+1:i+1+field.size], decoded)
+		i += 1 + field.size
+	}
+
+	if len(decoded.Readings) == 0 {
+		return nil, fmt.Errorf("payload contains no readings")
+	}
+	return decoded, nil
+}
@@ -0,0 +1,182 @@
+package lorawan
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const chirpStackUplinkJSON = `{
+	"deduplicationId": "3ac7e3c4-4401-4b8d-9386-a5c902f9202d",
+	"time": "2025-05-02T13:45:00.123Z",
+	"deviceInfo": {
+		"deviceProfileName": "Air Sensor Rev B",
+		"deviceName": "roof-1",
+		"devEui": "0101010101010101"
+	},
+	"fPort": 1,
+	"fCnt": 42,
+	"data": "AQEA+gIBkBAA1xGC",
+	"rxInfo": [
+		{"gatewayId": "aa", "rssi": -110, "snr": -5, "location": {"latitude": 41.0, "longitude": 29.0}},
+		{"gatewayId": "bb", "rssi": -60, "snr": 9.5, "location": {"latitude": 41.015, "longitude": 28.979, "altitude": 80}},
+		{"gatewayId": "cc", "rssi": -40, "snr": 10}
+	]
+}`
+
+const thingsStackUplinkJSON = `{
+	"end_device_ids": {"device_id": "eui-70b3d57ed005a8b1", "dev_eui": "70B3D57ED005A8B1"},
+	"received_at": "2025-05-02T13:45:01Z",
+	"uplink_message": {
+		"f_port": 2,
+		"f_cnt": 7,
+		"frm_payload": "AQEA+iAYcmRwEUXYMA==",
+		"received_at": "2025-05-02T13:45:00Z",
+		"rx_metadata": [{"gateway_ids": {"gateway_id": "gw-1"}, "rssi": -70, "snr": 8}],
+		"version_ids": {"brand_id": "acme", "model_id": "aq-200"},
+		"locations": {"user": {"latitude": 41.02, "longitude": 28.98, "source": "SOURCE_REGISTRY"}}
+	}
+}`
+
+func TestParseChirpStackUplink(t *testing.T) {
+	uplink, err := ParseUplink([]byte(chirpStackUplinkJSON))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if uplink.DevEUI != "0101010101010101" || uplink.DeviceProfile != "Air Sensor Rev B" || uplink.FPort != 1 || uplink.FCnt != 42 {
+		t.Errorf("Unexpected uplink %+v", uplink)
+	}
+	if !uplink.ReceivedAt.Equal(time.Date(2025, 5, 2, 13, 45, 0, 123000000, time.UTC)) {
+		t.Errorf("Expected time of the event, got %v", uplink.ReceivedAt)
+	}
+	if len(uplink.Payload) != 12 || uplink.Location != nil {
+		t.Errorf("Expected a 12 byte payload and no device location, got %d bytes and %v", len(uplink.Payload), uplink.Location)
+	}
+
+	// The strongest gateway has no location, so the next strongest one is used
+	location := uplink.GatewayLocation()
+	if location == nil || location.Latitude != 41.015 || location.Longitude != 28.979 {
+		t.Errorf("Expected location of gateway bb, got %+v", location)
+	}
+}
+
+func TestParseThingsStackUplink(t *testing.T) {
+	uplink, err := ParseUplink([]byte(thingsStackUplinkJSON))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if uplink.DevEUI != "70b3d57ed005a8b1" || uplink.DeviceName != "eui-70b3d57ed005a8b1" || uplink.DeviceProfile != "acme/aq-200" {
+		t.Errorf("Unexpected uplink %+v", uplink)
+	}
+	if !uplink.ReceivedAt.Equal(time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)) {
+		t.Errorf("Expected received_at of the uplink message, got %v", uplink.ReceivedAt)
+	}
+	if uplink.Location == nil || uplink.Location.Latitude != 41.02 {
+		t.Errorf("Expected the user location, got %+v", uplink.Location)
+	}
+	if uplink.GatewayLocation() != nil {
+		t.Errorf("Expected no gateway location")
+	}
+}
+
+func TestParseUplinkErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		notUplink   bool
+		expectError bool
+	}{
+		{"ChirpStack Join", `{"deviceInfo": {"devEui": "0101010101010101"}, "devAddr": "00189440"}`, true, true},
+		{"Things Stack Join Accept", `{"end_device_ids": {"dev_eui": "70B3D57ED005A8B1"}, "join_accept": {}}`, true, true},
+		{"Unknown Format", `{"devEUI": "0101010101010101"}`, false, true},
+		{"Invalid JSON", `{`, false, true},
+		{"Invalid Base64", `{"deviceInfo": {"devEui": "01"}, "fCnt": 1, "data": "%%%"}`, false, true},
+		{"Missing DevEUI", `{"deviceInfo": {}, "fCnt": 1, "data": "AQ=="}`, false, true},
+		{"Null Device Info", `{"deviceInfo": null, "fCnt": 1}`, false, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseUplink([]byte(tc.body))
+			if tc.expectError && err == nil {
+				t.Fatalf("Expected error, got nil")
+			}
+			if errors.Is(err, ErrNotUplink) != tc.notUplink {
+				t.Errorf("Expected ErrNotUplink %v, got %v", tc.notUplink, err)
+			}
+		})
+	}
+}
+
+func TestDecodeCompactV1(t *testing.T) {
+	decoded, err := DecodeCompactV1(1, []byte{0x01, 0x01, 0x00, 0xFA, 0x02, 0x01, 0x90, 0x10, 0xFF, 0xEC, 0x11, 0x82})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []Reading{{"PM2.5", 25, "µg/m³"}, {"PM10", 40, "µg/m³"}}
+	if len(decoded.Readings) != len(expected) {
+		t.Fatalf("Expected %d readings, got %+v", len(expected), decoded.Readings)
+	}
+	for i, reading := range decoded.Readings {
+		if reading != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], reading)
+		}
+	}
+	if decoded.Temperature == nil || *decoded.Temperature != -2 {
+		t.Errorf("Expected temperature -2, got %v", decoded.Temperature)
+	}
+	if decoded.Humidity == nil || *decoded.Humidity != 65 {
+		t.Errorf("Expected humidity 65, got %v", decoded.Humidity)
+	}
+
+	invalid := map[string][]byte{
+		"Empty":         {},
+		"Version":       {0x02, 0x01, 0x00, 0xFA},
+		"Unknown Field": {0x01, 0x7F, 0x00},
+		"Truncated":     {0x01, 0x01, 0x00},
+		"No Readings":   {0x01, 0x11, 0x82},
+	}
+	for name, payload := range invalid {
+		if _, err := DecodeCompactV1(1, payload); err == nil {
+			t.Errorf("Expected error for %s payload", name)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	custom := DecoderFunc(func(int, []byte) (*Decoded, error) {
+		return &Decoded{Readings: []Reading{{Parameter: "O3", Value: 1}}}, nil
+	})
+
+	if err := registry.Register("custom", custom); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := registry.Register("custom", custom); err == nil {
+		t.Errorf("Expected error for duplicate profile")
+	}
+	if err := registry.AliasAll("Air Sensor Rev B=custom, acme/aq-200=custom"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := registry.AliasAll("other=missing"); err == nil {
+		t.Errorf("Expected error for alias of unknown profile")
+	}
+	if err := registry.AliasAll("no-separator"); err == nil {
+		t.Errorf("Expected error for invalid alias")
+	}
+
+	decoder, ok := registry.Lookup("acme/aq-200")
+	if !ok {
+		t.Fatalf("Expected alias to be registered, got %v", registry.Profiles())
+	}
+	if decoded, _ := decoder.Decode(1, nil); decoded.Readings[0].Parameter != "O3" {
+		t.Errorf("Expected the aliased decoder")
+	}
+
+	if _, ok := Default().Lookup(CompactV1); !ok {
+		t.Errorf("Expected built-in %s decoder", CompactV1)
+	}
+}
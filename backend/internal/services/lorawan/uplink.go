@@ -0,0 +1,264 @@
+package lorawan
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotUplink is returned for network server events that are not uplinks, such as joins and status reports
+var ErrNotUplink = errors.New("event is not an uplink")
+
+// Location is a position reported by a network server or decoded from a payload
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	// Altitude is the height above sea level in meters, nil when unknown
+	Altitude *float64
+}
+
+// Gateway is a gateway that received an uplink
+type Gateway struct {
+	ID   string
+	RSSI float64
+	SNR  float64
+	// Location is nil when the gateway has no known position
+	Location *Location
+}
+
+// Uplink is a LoRaWAN uplink in a network server independent form
+type Uplink struct {
+	// DevEUI is the device EUI in lower case hex
+	DevEUI     string
+	DeviceName string
+	// DeviceProfile selects the payload decoder. ChirpStack sends the device profile name;
+	// The Things Stack sends the end device brand and model as brand/model.
+	DeviceProfile string
+	FPort         int
+	FCnt          uint32
+	Payload       []byte
+	// ReceivedAt is when the network server received the uplink, zero when the envelope has no time
+	ReceivedAt time.Time
+	// Location is the device location known to the network server, nil when there is none
+	Location *Location
+	Gateways []Gateway
+}
+
+// GatewayLocation returns the location of the gateway with the strongest signal, or nil when no
+// gateway that received the uplink has a known position
+func (u *Uplink) GatewayLocation() *Location {
+	var best *Gateway
+	for i := range u.Gateways {
+		gateway := &u.Gateways[i]
+		if gateway.Location == nil {
+			continue
+		}
+		if best == nil || gateway.RSSI > best.RSSI || (gateway.RSSI == best.RSSI && gateway.SNR > best.SNR) {
+			best = gateway
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.Location
+}
+
+// location is a position in ChirpStack and The Things Stack envelopes
+type location struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Altitude  *float64 `json:"altitude"`
+}
+
+// toLocation converts an envelope position; positions without coordinates or at 0,0 are unknown
+func (l *location) toLocation() *Location {
+	if l == nil || l.Latitude == nil || l.Longitude == nil || (*l.Latitude == 0 && *l.Longitude == 0) {
+		return nil
+	}
+	return &Location{Latitude: *l.Latitude, Longitude: *l.Longitude, Altitude: l.Altitude}
+}
+
+// chirpStackUplink is a ChirpStack v4 uplink event
+type chirpStackUplink struct {
+	Time       *time.Time `json:"time"`
+	DeviceInfo *struct {
+		DeviceProfileName string `json:"deviceProfileName"`
+		DeviceName        string `json:"deviceName"`
+		DevEUI            string `json:"devEui"`
+	} `json:"deviceInfo"`
+	FPort  int     `json:"fPort"`
+	FCnt   *uint32 `json:"fCnt"`
+	Data   string  `json:"data"`
+	RxInfo []struct {
+		GatewayID string     `json:"gatewayId"`
+		RSSI      float64    `json:"rssi"`
+		SNR       float64    `json:"snr"`
+		Location  *location  `json:"location"`
+		NSTime    *time.Time `json:"nsTime"`
+	} `json:"rxInfo"`
+}
+
+// thingsStackUplink is a The Things Stack v3 uplink message
+type thingsStackUplink struct {
+	EndDeviceIDs *struct {
+		DeviceID string `json:"device_id"`
+		DevEUI   string `json:"dev_eui"`
+	} `json:"end_device_ids"`
+	ReceivedAt    *time.Time `json:"received_at"`
+	UplinkMessage *struct {
+		FPort      int        `json:"f_port"`
+		FCnt       uint32     `json:"f_cnt"`
+		FRMPayload string     `json:"frm_payload"`
+		ReceivedAt *time.Time `json:"received_at"`
+		RxMetadata []struct {
+			GatewayIDs struct {
+				GatewayID string `json:"gateway_id"`
+			} `json:"gateway_ids"`
+			RSSI     float64   `json:"rssi"`
+			SNR      float64   `json:"snr"`
+			Location *location `json:"location"`
+		} `json:"rx_metadata"`
+		VersionIDs *struct {
+			BrandID string `json:"brand_id"`
+			ModelID string `json:"model_id"`
+		} `json:"version_ids"`
+		Locations map[string]*location `json:"locations"`
+	} `json:"uplink_message"`
+}
+
+// ParseUplink parses a ChirpStack v4 or The Things Stack v3 webhook body. The format is detected
+// from the envelope: ChirpStack events carry deviceInfo, The Things Stack messages end_device_ids.
+// Events of either network server that are not uplinks return ErrNotUplink.
+func ParseUplink(body []byte) (*Uplink, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, fmt.Errorf("invalid uplink JSON: %w", err)
+	}
+
+	switch {
+	case probe["deviceInfo"] != nil:
+		return parseChirpStack(body)
+	case probe["end_device_ids"] != nil:
+		return parseThingsStack(body)
+	default:
+		return nil, errors.New("unknown uplink format, expected a ChirpStack or The Things Stack envelope")
+	}
+}
+
+// parseChirpStack converts a ChirpStack v4 uplink event
+func parseChirpStack(body []byte) (*Uplink, error) {
+	var event chirpStackUplink
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid ChirpStack uplink: %w", err)
+	}
+	if event.DeviceInfo == nil {
+		return nil, errors.New("uplink has no device info")
+	}
+	// Join, status and ack events share deviceInfo but have no frame counter
+	if event.FCnt == nil {
+		return nil, ErrNotUplink
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %w", err)
+	}
+
+	uplink := &Uplink{
+		DevEUI:        strings.ToLower(event.DeviceInfo.DevEUI),
+		DeviceName:    event.DeviceInfo.DeviceName,
+		DeviceProfile: event.DeviceInfo.DeviceProfileName,
+		FPort:         event.FPort,
+		FCnt:          *event.FCnt,
+		Payload:       payload,
+	}
+	if event.Time != nil {
+		uplink.ReceivedAt = event.Time.UTC()
+	}
+	for _, rx := range event.RxInfo {
+		uplink.Gateways = append(uplink.Gateways, Gateway{
+			ID:       rx.GatewayID,
+			RSSI:     rx.RSSI,
+			SNR:      rx.SNR,
+			Location: rx.Location.toLocation(),
+		})
+		if uplink.ReceivedAt.IsZero() && rx.NSTime != nil {
+			uplink.ReceivedAt = rx.NSTime.UTC()
+		}
+	}
+
+	return uplink, validate(uplink)
+}
+
+// parseThingsStack converts a The Things Stack v3 uplink message
+func parseThingsStack(body []byte) (*Uplink, error) {
+	var message thingsStackUplink
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("invalid The Things Stack uplink: %w", err)
+	}
+	if message.EndDeviceIDs == nil {
+		return nil, errors.New("uplink has no end device identifiers")
+	}
+	// Join accepts, downlink events and service data share end_device_ids
+	if message.UplinkMessage == nil {
+		return nil, ErrNotUplink
+	}
+	up := message.UplinkMessage
+
+	payload, err := base64.StdEncoding.DecodeString(up.FRMPayload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 frm_payload: %w", err)
+	}
+
+	uplink := &Uplink{
+		DevEUI:     strings.ToLower(message.EndDeviceIDs.DevEUI),
+		DeviceName: message.EndDeviceIDs.DeviceID,
+		FPort:      up.FPort,
+		FCnt:       up.FCnt,
+		Payload:    payload,
+	}
+	if up.VersionIDs != nil && up.VersionIDs.BrandID != "" && up.VersionIDs.ModelID != "" {
+		uplink.DeviceProfile = up.VersionIDs.BrandID + "/" + up.VersionIDs.ModelID
+	}
+	switch {
+	case up.ReceivedAt != nil:
+		uplink.ReceivedAt = up.ReceivedAt.UTC()
+	case message.ReceivedAt != nil:
+		uplink.ReceivedAt = message.ReceivedAt.UTC()
+	}
+	// The user set location takes precedence over locations resolved by the network
+	sources := make([]string, 0, len(up.Locations))
+	for source := range up.Locations {
+		if source != "user" {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	for _, source := range append([]string{"user"}, sources...) {
+		if uplink.Location = up.Locations[source].toLocation(); uplink.Location != nil {
+			break
+		}
+	}
+	for _, rx := range up.RxMetadata {
+		uplink.Gateways = append(uplink.Gateways, Gateway{
+			ID:       rx.GatewayIDs.GatewayID,
+			RSSI:     rx.RSSI,
+			SNR:      rx.SNR,
+			Location: rx.Location.toLocation(),
+		})
+	}
+
+	return uplink, validate(uplink)
+}
+
+// validate checks the fields every uplink needs
+func validate(uplink *Uplink) error {
+	if uplink.DevEUI == "" {
+		return errors.New("uplink has no device EUI")
+	}
+	return nil
+}
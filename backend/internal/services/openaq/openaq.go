@@ -0,0 +1,217 @@
+package openaq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Measurement is a single OpenAQ measurement in a version independent form
+type Measurement struct {
+	// Version is the OpenAQ API version of the result, 2 or 3
+	Version    int
+	LocationID string
+	Location   string
+	Parameter  string
+	Unit       string
+	Value      float64
+	// Timestamp is the end of the averaging period in UTC
+	Timestamp time.Time
+	// Latitude and Longitude are nil when the result has no coordinates, as is common in v3
+	Latitude  *float64
+	Longitude *float64
+	// Err is set when the result could not be read; the other fields may then be incomplete
+	Err error
+}
+
+// document is an OpenAQ API response
+type document struct {
+	Results []json.RawMessage `json:"results"`
+}
+
+// coordinates is the location of a v2 or v3 result
+type coordinates struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// datetime is a v2 date or v3 datetime object
+type datetime struct {
+	UTC string `json:"utc"`
+}
+
+// v2Result is a result of the v2 measurements endpoint
+type v2Result struct {
+	LocationID  json.Number  `json:"locationId"`
+	Location    string       `json:"location"`
+	Parameter   string       `json:"parameter"`
+	Value       *float64     `json:"value"`
+	Date        datetime     `json:"date"`
+	Unit        string       `json:"unit"`
+	Coordinates *coordinates `json:"coordinates"`
+}
+
+// v3Result is a result of the v3 sensor measurements endpoint
+type v3Result struct {
+	Value     *float64 `json:"value"`
+	Parameter struct {
+		Name  string `json:"name"`
+		Units string `json:"units"`
+	} `json:"parameter"`
+	Period struct {
+		DatetimeFrom *datetime `json:"datetimeFrom"`
+		DatetimeTo   *datetime `json:"datetimeTo"`
+	} `json:"period"`
+	Datetime    *datetime    `json:"datetime"`
+	Coordinates *coordinates `json:"coordinates"`
+	LocationsID json.Number  `json:"locationsId"`
+}
+
+// Parse reads an OpenAQ v2 or v3 measurements document, either an API response with a
+// results array or a bare array of results. The version is detected per result.
+// Results that cannot be read are returned with Err set.
+func Parse(r io.Reader) ([]Measurement, error) {
+	reader := bufio.NewReader(r)
+	first, err := firstByte(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []json.RawMessage
+	decoder := json.NewDecoder(reader)
+	switch first {
+	case '{':
+		var doc document
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("invalid OpenAQ document: %w", err)
+		}
+		if doc.Results == nil {
+			return nil, errors.New("OpenAQ document has no results")
+		}
+		results = doc.Results
+	case '[':
+		if err := decoder.Decode(&results); err != nil {
+			return nil, fmt.Errorf("invalid OpenAQ document: %w", err)
+		}
+	default:
+		return nil, errors.New("OpenAQ document must be a JSON object or array")
+	}
+
+	measurements := make([]Measurement, len(results))
+	for i, raw := range results {
+		measurements[i] = parseResult(raw)
+	}
+	return measurements, nil
+}
+
+// firstByte returns the first non-whitespace byte without consuming it
+func firstByte(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, errors.New("OpenAQ document is empty")
+			}
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
+
+// parseResult converts a single v2 or v3 result. In v2 the parameter is a string, in v3 an object.
+func parseResult(raw json.RawMessage) Measurement {
+	var probe struct {
+		Parameter json.RawMessage `json:"parameter"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return Measurement{Err: fmt.Errorf("invalid result: %w", err)}
+	}
+
+	if p := bytes.TrimSpace(probe.Parameter); len(p) > 0 && p[0] == '{' {
+		return parseV3(raw)
+	}
+	return parseV2(raw)
+}
+
+// parseV2 converts a v2 result
+func parseV2(raw json.RawMessage) Measurement {
+	m := Measurement{Version: 2}
+
+	var result v2Result
+	if err := json.Unmarshal(raw, &result); err != nil {
+		m.Err = fmt.Errorf("invalid v2 result: %w", err)
+		return m
+	}
+
+	m.LocationID = result.LocationID.String()
+	m.Location = result.Location
+	m.Parameter = result.Parameter
+	m.Unit = result.Unit
+	if result.Coordinates != nil {
+		m.Latitude, m.Longitude = result.Coordinates.Latitude, result.Coordinates.Longitude
+	}
+
+	if result.Value == nil {
+		m.Err = errors.New("result has no value")
+		return m
+	}
+	m.Value = *result.Value
+
+	m.Timestamp, m.Err = parseTime(result.Date.UTC)
+	return m
+}
+
+// parseV3 converts a v3 result. Hourly and daily results are stamped with the end of their period.
+func parseV3(raw json.RawMessage) Measurement {
+	m := Measurement{Version: 3}
+
+	var result v3Result
+	if err := json.Unmarshal(raw, &result); err != nil {
+		m.Err = fmt.Errorf("invalid v3 result: %w", err)
+		return m
+	}
+
+	m.LocationID = result.LocationsID.String()
+	m.Parameter = result.Parameter.Name
+	m.Unit = result.Parameter.Units
+	if result.Coordinates != nil {
+		m.Latitude, m.Longitude = result.Coordinates.Latitude, result.Coordinates.Longitude
+	}
+
+	if result.Value == nil {
+		m.Err = errors.New("result has no value")
+		return m
+	}
+	m.Value = *result.Value
+
+	var stamp string
+	switch {
+	case result.Period.DatetimeTo != nil:
+		stamp = result.Period.DatetimeTo.UTC
+	case result.Period.DatetimeFrom != nil:
+		stamp = result.Period.DatetimeFrom.UTC
+	case result.Datetime != nil:
+		stamp = result.Datetime.UTC
+	}
+	m.Timestamp, m.Err = parseTime(stamp)
+	return m
+}
+
+// parseTime parses an OpenAQ UTC timestamp
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("result has no timestamp")
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %s", strconv.Quote(value))
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,91 @@
+package openaq
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseV2(t *testing.T) {
+	doc := `{
+		"meta": {"name": "openaq-api", "found": 2},
+		"results": [
+			{"locationId": 8118, "location": "Beşiktaş", "parameter": "pm25", "value": 12.5,
+			 "date": {"utc": "2025-05-02T13:00:00+00:00", "local": "2025-05-02T16:00:00+03:00"},
+			 "unit": "µg/m³", "coordinates": {"latitude": 41.043, "longitude": 29.009}, "country": "TR"},
+			{"locationId": 8118, "parameter": "no2", "value": 21.0, "date": {"utc": "not a date"}, "unit": "ppb"}
+		]
+	}`
+
+	measurements, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(measurements) != 2 {
+		t.Fatalf("Expected 2 measurements, got %d", len(measurements))
+	}
+
+	m := measurements[0]
+	if m.Err != nil {
+		t.Fatalf("Unexpected result error: %v", m.Err)
+	}
+	if m.Version != 2 || m.LocationID != "8118" || m.Parameter != "pm25" || m.Unit != "µg/m³" || m.Value != 12.5 {
+		t.Errorf("Unexpected measurement %+v", m)
+	}
+	if !m.Timestamp.Equal(time.Date(2025, 5, 2, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected timestamp 2025-05-02T13:00:00Z, got %s", m.Timestamp)
+	}
+	if m.Latitude == nil || *m.Latitude != 41.043 || m.Longitude == nil || *m.Longitude != 29.009 {
+		t.Errorf("Expected coordinates to be read, got %v %v", m.Latitude, m.Longitude)
+	}
+
+	if measurements[1].Err == nil {
+		t.Errorf("Expected invalid timestamp to be reported")
+	}
+}
+
+func TestParseV3(t *testing.T) {
+	doc := `[
+		{"value": 0.031, "flagInfo": {"hasFlags": false},
+		 "parameter": {"id": 10, "name": "o3", "units": "ppm", "displayName": null},
+		 "period": {"label": "1hour", "interval": "01:00:00",
+			"datetimeFrom": {"utc": "2025-05-02T12:00:00Z", "local": "2025-05-02T08:00:00-04:00"},
+			"datetimeTo": {"utc": "2025-05-02T13:00:00Z", "local": "2025-05-02T09:00:00-04:00"}},
+		 "coordinates": null},
+		{"parameter": {"name": "pm10", "units": "µg/m³"}, "period": {"datetimeTo": {"utc": "2025-05-02T13:00:00Z"}}}
+	]`
+
+	measurements, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(measurements) != 2 {
+		t.Fatalf("Expected 2 measurements, got %d", len(measurements))
+	}
+
+	m := measurements[0]
+	if m.Err != nil {
+		t.Fatalf("Unexpected result error: %v", m.Err)
+	}
+	if m.Version != 3 || m.Parameter != "o3" || m.Unit != "ppm" || m.Value != 0.031 {
+		t.Errorf("Unexpected measurement %+v", m)
+	}
+	if !m.Timestamp.Equal(time.Date(2025, 5, 2, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the end of the period as timestamp, got %s", m.Timestamp)
+	}
+	if m.Latitude != nil || m.Longitude != nil {
+		t.Errorf("Expected no coordinates")
+	}
+
+	if measurements[1].Err == nil {
+		t.Errorf("Expected missing value to be reported")
+	}
+}
+
+func TestParseInvalidDocument(t *testing.T) {
+	for _, doc := range []string{"", "  ", `"results"`, `{"meta": {}}`, `{"results": [`} {
+		if _, err := Parse(strings.NewReader(doc)); err == nil {
+			t.Errorf("Expected error for document %q", doc)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package parameters
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Canonical parameter names
+const (
+	PM25 = "PM2.5"
+	PM10 = "PM10"
+	NO2  = "NO2"
+	O3   = "O3"
+	SO2  = "SO2"
+	CO   = "CO"
+)
+
+// Canonical unit names
+const (
+	MicrogramsPerCubicMeter = "µg/m³"
+	MilligramsPerCubicMeter = "mg/m³"
+	PartsPerBillion         = "ppb"
+	PartsPerMillion         = "ppm"
+)
+
+// molarVolume is the volume of one mole of ideal gas in litres at 25 °C and 1 atm,
+// the reference conditions used by the WHO and EU for ppb to µg/m³ conversion
+const molarVolume = 24.45
+
+// Definition describes a measured parameter
+type Definition struct {
+	Name          string   `json:"name"`
+	Aliases       []string `json:"aliases"`
+	CanonicalUnit string   `json:"canonical_unit"`
+	Units         []string `json:"units"`
+	// MolecularWeight in g/mol, needed to convert mixing ratios (ppb, ppm) of gases
+	MolecularWeight float64 `json:"molecular_weight,omitempty"`
+	// MinValue and MaxValue bound plausible values in the canonical unit
+	MinValue float64 `json:"min_value"`
+	MaxValue float64 `json:"max_value"`
+}
+
+// Normalized is a value converted to the canonical parameter name and unit
+type Normalized struct {
+	Parameter     string
+	Value         float64
+	Unit          string
+	OriginalValue float64
+	OriginalUnit  string
+}
+
+// Registry is a catalogue of known parameters
+type Registry struct {
+	definitions map[string]*Definition
+	aliases     map[string]*Definition
+}
+
+// NewRegistry creates a registry from the given definitions
+func NewRegistry(definitions ...Definition) (*Registry, error) {
+	r := &Registry{
+		definitions: make(map[string]*Definition),
+		aliases:     make(map[string]*Definition),
+	}
+
+	for i := range definitions {
+		def := definitions[i]
+		if def.Name == "" || def.CanonicalUnit == "" {
+			return nil, fmt.Errorf("parameter definition needs a name and a canonical unit")
+		}
+		if _, ok := r.definitions[def.Name]; ok {
+			return nil, fmt.Errorf("duplicate parameter %q", def.Name)
+		}
+		r.definitions[def.Name] = &def
+
+		for _, name := range append([]string{def.Name}, def.Aliases...) {
+			key := aliasKey(name)
+			if existing, ok := r.aliases[key]; ok && existing.Name != def.Name {
+				return nil, fmt.Errorf("alias %q of %q is already used by %q", name, def.Name, existing.Name)
+			}
+			r.aliases[key] = &def
+		}
+	}
+
+	return r, nil
+}
+
+// defaultRegistry is the built-in catalogue
+var defaultRegistry = mustNewRegistry(
+	Definition{
+		Name:          PM25,
+		Aliases:       []string{"pm25", "PM2_5", "pm2.5", "PM25", "pm2_5"},
+		CanonicalUnit: MicrogramsPerCubicMeter,
+		Units:         []string{MicrogramsPerCubicMeter, MilligramsPerCubicMeter},
+		MinValue:      0,
+		MaxValue:      1000,
+	},
+	Definition{
+		Name:          PM10,
+		Aliases:       []string{"pm10", "PM_10"},
+		CanonicalUnit: MicrogramsPerCubicMeter,
+		Units:         []string{MicrogramsPerCubicMeter, MilligramsPerCubicMeter},
+		MinValue:      0,
+		MaxValue:      2000,
+	},
+	Definition{
+		Name:            NO2,
+		Aliases:         []string{"no2", "nitrogen_dioxide"},
+		CanonicalUnit:   MicrogramsPerCubicMeter,
+		Units:           []string{MicrogramsPerCubicMeter, PartsPerBillion, PartsPerMillion},
+		MolecularWeight: 46.0055,
+		MinValue:        0,
+		MaxValue:        4000,
+	},
+	Definition{
+		Name:            O3,
+		Aliases:         []string{"o3", "ozone"},
+		CanonicalUnit:   MicrogramsPerCubicMeter,
+		Units:           []string{MicrogramsPerCubicMeter, PartsPerBillion, PartsPerMillion},
+		MolecularWeight: 47.9982,
+		MinValue:        0,
+		MaxValue:        2000,
+	},
+	Definition{
+		Name:            SO2,
+		Aliases:         []string{"so2", "sulfur_dioxide", "sulphur_dioxide"},
+		CanonicalUnit:   MicrogramsPerCubicMeter,
+		Units:           []string{MicrogramsPerCubicMeter, PartsPerBillion, PartsPerMillion},
+		MolecularWeight: 64.066,
+		MinValue:        0,
+		MaxValue:        5000,
+	},
+	Definition{
+		Name:            CO,
+		Aliases:         []string{"co", "carbon_monoxide"},
+		CanonicalUnit:   MilligramsPerCubicMeter,
+		Units:           []string{MilligramsPerCubicMeter, MicrogramsPerCubicMeter, PartsPerBillion, PartsPerMillion},
+		MolecularWeight: 28.010,
+		MinValue:        0,
+		MaxValue:        200,
+	},
+)
+
+// Default returns the built-in parameter catalogue
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Lookup finds a parameter by its canonical name or an alias
+func (r *Registry) Lookup(name string) (*Definition, bool) {
+	def, ok := r.aliases[aliasKey(name)]
+	return def, ok
+}
+
+// Definitions returns all parameters sorted by name
+func (r *Registry) Definitions() []Definition {
+	results := make([]Definition, 0, len(r.definitions))
+	for _, def := range r.definitions {
+		results = append(results, *def)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// Normalize resolves the parameter name, converts the value to the canonical unit and checks the valid range.
+// An empty unit means the value is already in the canonical unit.
+func (r *Registry) Normalize(parameter string, value float64, unit string) (*Normalized, error) {
+	def, ok := r.Lookup(parameter)
+	if !ok {
+		return nil, fmt.Errorf("unknown parameter %q", parameter)
+	}
+
+	from := def.CanonicalUnit
+	if unit != "" {
+		from, ok = canonicalUnit(unit)
+		if !ok || !def.allowsUnit(from) {
+			return nil, fmt.Errorf("unit %q is not allowed for %s, expected one of %s", unit, def.Name, strings.Join(def.Units, ", "))
+		}
+	}
+
+	converted, err := convert(value, from, def.CanonicalUnit, def.MolecularWeight)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s from %s: %w", def.Name, from, err)
+	}
+
+	if converted < def.MinValue || converted > def.MaxValue {
+		return nil, fmt.Errorf("%s value %g %s is outside the valid range %g-%g %s",
+			def.Name, converted, def.CanonicalUnit, def.MinValue, def.MaxValue, def.CanonicalUnit)
+	}
+
+	return &Normalized{
+		Parameter:     def.Name,
+		Value:         converted,
+		Unit:          def.CanonicalUnit,
+		OriginalValue: value,
+		OriginalUnit:  from,
+	}, nil
+}
+
+// Convert converts a value of a parameter from its canonical unit to another unit, such as the
+// ppb in which some national air quality indices state their breakpoints
+func (r *Registry) Convert(parameter string, value float64, unit string) (float64, error) {
+	def, ok := r.Lookup(parameter)
+	if !ok {
+		return 0, fmt.Errorf("unknown parameter %q", parameter)
+	}
+	to, ok := canonicalUnit(unit)
+	if !ok {
+		return 0, fmt.Errorf("unsupported unit %q", unit)
+	}
+
+	converted, err := convert(value, def.CanonicalUnit, to, def.MolecularWeight)
+	if err != nil {
+		return 0, fmt.Errorf("cannot convert %s to %s: %w", def.Name, to, err)
+	}
+	return converted, nil
+}
+
+// allowsUnit reports whether the parameter may be reported in the given canonical unit
+func (d *Definition) allowsUnit(unit string) bool {
+	for _, allowed := range d.Units {
+		if allowed == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// convert converts a value between mass concentrations and mixing ratios
+func convert(value float64, from, to string, molecularWeight float64) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+
+	// Convert to µg/m³ first
+	var micrograms float64
+	switch from {
+	case MicrogramsPerCubicMeter:
+		micrograms = value
+	case MilligramsPerCubicMeter:
+		micrograms = value * 1000
+	case PartsPerBillion, PartsPerMillion:
+		if molecularWeight == 0 {
+			return 0, fmt.Errorf("mixing ratios need a molecular weight")
+		}
+		ppb := value
+		if from == PartsPerMillion {
+			ppb = value * 1000
+		}
+		micrograms = ppb * molecularWeight / molarVolume
+	default:
+		return 0, fmt.Errorf("unsupported unit %q", from)
+	}
+
+	switch to {
+	case MicrogramsPerCubicMeter:
+		return micrograms, nil
+	case MilligramsPerCubicMeter:
+		return micrograms / 1000, nil
+	case PartsPerBillion, PartsPerMillion:
+		if molecularWeight == 0 {
+			return 0, fmt.Errorf("mixing ratios need a molecular weight")
+		}
+		ppb := micrograms * molarVolume / molecularWeight
+		if to == PartsPerMillion {
+			return ppb / 1000, nil
+		}
+		return ppb, nil
+	default:
+		return 0, fmt.Errorf("unsupported unit %q", to)
+	}
+}
+
+// unitAliases maps normalized unit spellings to canonical unit names
+var unitAliases = map[string]string{
+	"ug/m3":  MicrogramsPerCubicMeter,
+	"mcg/m3": MicrogramsPerCubicMeter,
+	"mg/m3":  MilligramsPerCubicMeter,
+	"ppb":    PartsPerBillion,
+	"ppm":    PartsPerMillion,
+}
+
+// canonicalUnit resolves spellings such as ug/m3, μg/m^3 or µg/m³ to a canonical unit name
+func canonicalUnit(unit string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(unit))
+	key = strings.NewReplacer(" ", "", "^", "", "³", "3", "μ", "u", "µ", "u").Replace(key)
+	canonical, ok := unitAliases[key]
+	return canonical, ok
+}
+
+// aliasKey normalizes a parameter name for alias lookups
+func aliasKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// mustNewRegistry creates a registry and panics on invalid built-in definitions
+func mustNewRegistry(definitions ...Definition) *Registry {
+	r, err := NewRegistry(definitions...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
@@ -0,0 +1,120 @@
+package parameters
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	registry := Default()
+
+	tests := []struct {
+		name              string
+		parameter         string
+		value             float64
+		unit              string
+		expectedParameter string
+		expectedValue     float64
+		expectedUnit      string
+	}{
+		{"Canonical Without Unit", "PM2.5", 25.0, "", PM25, 25.0, MicrogramsPerCubicMeter},
+		{"Alias pm25", "pm25", 25.0, "ug/m3", PM25, 25.0, MicrogramsPerCubicMeter},
+		{"Alias PM2_5", "PM2_5", 0.025, "mg/m3", PM25, 25.0, MicrogramsPerCubicMeter},
+		{"NO2 ppb", "no2", 10.0, "ppb", NO2, 18.816, MicrogramsPerCubicMeter},
+		{"O3 ppm", "O3", 0.05, "ppm", O3, 98.156, MicrogramsPerCubicMeter},
+		{"O3 Greek Mu", "O3", 100.0, "μg/m^3", O3, 100.0, MicrogramsPerCubicMeter},
+		{"CO mg/m3", "CO", 4.0, "mg/m³", CO, 4.0, MilligramsPerCubicMeter},
+		{"CO ppm", "co", 1.0, "ppm", CO, 1.1456, MilligramsPerCubicMeter},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			normalized, err := registry.Normalize(tc.parameter, tc.value, tc.unit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if normalized.Parameter != tc.expectedParameter {
+				t.Errorf("Expected parameter %s, got %s", tc.expectedParameter, normalized.Parameter)
+			}
+			if math.Abs(normalized.Value-tc.expectedValue) > 0.001 {
+				t.Errorf("Expected value %f, got %f", tc.expectedValue, normalized.Value)
+			}
+			if normalized.Unit != tc.expectedUnit {
+				t.Errorf("Expected unit %s, got %s", tc.expectedUnit, normalized.Unit)
+			}
+			if normalized.OriginalValue != tc.value {
+				t.Errorf("Expected original value %f, got %f", tc.value, normalized.OriginalValue)
+			}
+		})
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	registry := Default()
+
+	tests := []struct {
+		name      string
+		parameter string
+		value     float64
+		unit      string
+	}{
+		{"Unknown Parameter", "benzene", 1.0, ""},
+		{"Unknown Unit", "PM2.5", 1.0, "grains"},
+		{"Disallowed Unit", "PM10", 1.0, "ppb"},
+		{"Above Range", "PM2.5", 5000.0, ""},
+		{"Negative", "NO2", -1.0, "ppb"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := registry.Normalize(tc.parameter, tc.value, tc.unit); err == nil {
+				t.Errorf("Expected error for %s %f %s", tc.parameter, tc.value, tc.unit)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	registry := Default()
+
+	tests := []struct {
+		name      string
+		parameter string
+		value     float64
+		unit      string
+		expected  float64
+	}{
+		{"NO2 ppb", NO2, 18.816, "ppb", 10.0},
+		{"O3 ppm", O3, 98.156, "ppm", 0.05},
+		{"CO ppm", CO, 1.1456, "ppm", 1.0},
+		{"CO ug/m3", CO, 4.0, "ug/m3", 4000.0},
+		{"PM2.5 Canonical", PM25, 25.0, "µg/m³", 25.0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			converted, err := registry.Convert(tc.parameter, tc.value, tc.unit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if math.Abs(converted-tc.expected) > 0.001 {
+				t.Errorf("Expected value %f, got %f", tc.expected, converted)
+			}
+		})
+	}
+
+	if _, err := registry.Convert(PM10, 10, "ppb"); err == nil {
+		t.Errorf("Expected an error converting particulate matter to ppb")
+	}
+}
+
+func TestNewRegistryRejectsConflictingAliases(t *testing.T) {
+	_, err := NewRegistry(
+		Definition{Name: "A", Aliases: []string{"x"}, CanonicalUnit: PartsPerBillion},
+		Definition{Name: "B", Aliases: []string{"X"}, CanonicalUnit: PartsPerBillion},
+	)
+	if err == nil {
+		t.Errorf("Expected error for conflicting aliases")
+	}
+}
@@ -0,0 +1,225 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultClass is the class of sensors without a class of their own, and the fallback for unknown classes
+	DefaultClass = "default"
+	// AnonymousClass is the class of clients without a sensor identity, which are keyed by IP address
+	AnonymousClass = "anonymous"
+	// idleTTL is how long a full, unused bucket is kept before it is dropped
+	idleTTL = 10 * time.Minute
+)
+
+// Limit is a token bucket that refills at Rate tokens per second up to Burst tokens
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Status describes the bucket of one client
+type Status struct {
+	Key           string     `json:"key"`
+	Class         string     `json:"class"`
+	Limit         Limit      `json:"limit"`
+	Tokens        float64    `json:"tokens"`
+	Allowed       uint64     `json:"allowed"`
+	Throttled     uint64     `json:"throttled"`
+	LastThrottled *time.Time `json:"last_throttled,omitempty"`
+}
+
+// Limiter keeps a token bucket per client key, with limits configured per class
+type Limiter struct {
+	mu        sync.Mutex
+	limits    map[string]Limit
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+// bucket is the token bucket of one client
+type bucket struct {
+	class         string
+	limit         Limit
+	tokens        float64
+	updated       time.Time
+	lastSeen      time.Time
+	allowed       uint64
+	throttled     uint64
+	lastThrottled time.Time
+}
+
+// New creates a limiter with the given limits per class
+func New(limits map[string]Limit) *Limiter {
+	return &Limiter{
+		limits:  limits,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Limits returns the configured limits per class
+func (l *Limiter) Limits() map[string]Limit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits := make(map[string]Limit, len(l.limits))
+	for class, limit := range l.limits {
+		limits[class] = limit
+	}
+	return limits
+}
+
+// Allow takes a token from the bucket of key. If the bucket is empty, it returns false and
+// how long until a token is available. Classes without a limit fall back to DefaultClass;
+// if that has no limit either, the request is allowed.
+func (l *Limiter) Allow(key, class string) (bool, time.Duration) {
+	return l.allowAt(key, class, 1, time.Now())
+}
+
+// AllowN takes n tokens from the bucket of key, such as one per reading of a batch. It is
+// allowed while the bucket holds a token, and may leave the bucket in debt, which later calls
+// wait out; so a batch larger than the burst is not refused forever, but the average rate holds.
+func (l *Limiter) AllowN(key, class string, n int) (bool, time.Duration) {
+	return l.allowAt(key, class, n, time.Now())
+}
+
+// allowAt is AllowN at a given time
+func (l *Limiter) allowAt(key, class string, n int, now time.Time) (bool, time.Duration) {
+	if n <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pruneLocked(now)
+
+	limit, ok := l.limitLocked(class)
+	if !ok {
+		return true, 0
+	}
+
+	b, ok := l.buckets[key]
+	if !ok || b.class != class || b.limit != limit {
+		b = &bucket{class: class, limit: limit, tokens: float64(limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens -= float64(n)
+		b.allowed++
+		return true, 0
+	}
+
+	b.throttled++
+	b.lastThrottled = now
+	if limit.Rate <= 0 {
+		return false, time.Duration(math.MaxInt64)
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// Status returns the buckets of all recently seen clients, most throttled first
+func (l *Limiter) Status() []Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]Status, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		b.refill(now)
+		status := Status{
+			Key:       key,
+			Class:     b.class,
+			Limit:     b.limit,
+			Tokens:    math.Floor(b.tokens*100) / 100,
+			Allowed:   b.allowed,
+			Throttled: b.throttled,
+		}
+		if !b.lastThrottled.IsZero() {
+			lastThrottled := b.lastThrottled
+			status.LastThrottled = &lastThrottled
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Throttled != statuses[j].Throttled {
+			return statuses[i].Throttled > statuses[j].Throttled
+		}
+		return statuses[i].Key < statuses[j].Key
+	})
+	return statuses
+}
+
+// limitLocked returns the limit of a class, falling back to DefaultClass
+func (l *Limiter) limitLocked(class string) (Limit, bool) {
+	if limit, ok := l.limits[class]; ok {
+		return limit, true
+	}
+	limit, ok := l.limits[DefaultClass]
+	return limit, ok
+}
+
+// pruneLocked drops buckets that are full and have not been used for idleTTL, at most once a minute
+func (l *Limiter) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) && now.Sub(b.lastSeen) > idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// refill adds the tokens earned since the last update
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.updated = now
+	}
+}
+
+// ParseLimits parses limits in the form "class:rate:burst,class:rate:burst",
+// where rate is in requests per second
+func ParseLimits(spec string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid limit %q: expected class:rate:burst", entry)
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate in %q", entry)
+		}
+		burst, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid burst in %q", entry)
+		}
+
+		limits[strings.TrimSpace(parts[0])] = Limit{Rate: rate, Burst: burst}
+	}
+	return limits, nil
+}
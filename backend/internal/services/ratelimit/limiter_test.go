@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	limiter := New(map[string]Limit{
+		DefaultClass:   {Rate: 1, Burst: 2},
+		AnonymousClass: {Rate: 0.5, Burst: 1},
+	})
+	now := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allowAt("sensor:station-7", DefaultClass, 1, now); !ok {
+			t.Errorf("Expected request %d within the burst to be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := limiter.allowAt("sensor:station-7", DefaultClass, 1, now)
+	if ok {
+		t.Errorf("Expected request beyond the burst to be throttled")
+	}
+	if retryAfter != time.Second {
+		t.Errorf("Expected retry after 1s, got %v", retryAfter)
+	}
+
+	// Other clients have their own bucket
+	if ok, _ := limiter.allowAt("sensor:station-8", DefaultClass, 1, now); !ok {
+		t.Errorf("Expected another sensor to be allowed")
+	}
+
+	// Tokens refill over time
+	if ok, _ := limiter.allowAt("sensor:station-7", DefaultClass, 1, now.Add(1500*time.Millisecond)); !ok {
+		t.Errorf("Expected a request to be allowed after the bucket refilled")
+	}
+
+	// Anonymous clients use their own class
+	limiter.allowAt("ip:192.0.2.1", AnonymousClass, 1, now)
+	ok, retryAfter = limiter.allowAt("ip:192.0.2.1", AnonymousClass, 1, now)
+	if ok || retryAfter != 2*time.Second {
+		t.Errorf("Expected anonymous client to be throttled for 2s, got %v %v", ok, retryAfter)
+	}
+}
+
+func TestLimiterAllowN(t *testing.T) {
+	limiter := New(map[string]Limit{DefaultClass: {Rate: 10, Burst: 5}})
+	now := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	// A batch larger than the burst is allowed from a full bucket and leaves it in debt
+	if ok, _ := limiter.allowAt("sensor:station-7", DefaultClass, 25, now); !ok {
+		t.Fatalf("Expected the batch to be allowed from a full bucket")
+	}
+	ok, retryAfter := limiter.allowAt("sensor:station-7", DefaultClass, 1, now)
+	if ok || retryAfter != 2100*time.Millisecond {
+		t.Errorf("Expected to wait 2.1s for the debt of 20 tokens, got %v %v", ok, retryAfter)
+	}
+	if ok, _ := limiter.allowAt("sensor:station-7", DefaultClass, 1, now.Add(2100*time.Millisecond)); !ok {
+		t.Errorf("Expected a request to be allowed once the debt is paid")
+	}
+
+	// Charging nothing is always allowed
+	if ok, _ := limiter.allowAt("sensor:station-7", DefaultClass, 0, now); !ok {
+		t.Errorf("Expected an empty batch to be allowed")
+	}
+}
+
+func TestLimiterClassFallback(t *testing.T) {
+	limiter := New(map[string]Limit{DefaultClass: {Rate: 1, Burst: 1}})
+	now := time.Now()
+
+	limiter.allowAt("sensor:station-7", "reference", 1, now)
+	if ok, _ := limiter.allowAt("sensor:station-7", "reference", 1, now); ok {
+		t.Errorf("Expected unknown class to fall back to the default limit")
+	}
+
+	unlimited := New(map[string]Limit{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := unlimited.allowAt("sensor:station-7", DefaultClass, 1, now); !ok {
+			t.Fatalf("Expected requests without a configured limit to be allowed")
+		}
+	}
+}
+
+func TestLimiterStatus(t *testing.T) {
+	limiter := New(map[string]Limit{DefaultClass: {Rate: 1, Burst: 1}})
+	now := time.Now()
+
+	limiter.allowAt("sensor:quiet", DefaultClass, 1, now)
+	limiter.allowAt("sensor:noisy", DefaultClass, 1, now)
+	limiter.allowAt("sensor:noisy", DefaultClass, 1, now)
+	limiter.allowAt("sensor:noisy", DefaultClass, 1, now)
+
+	statuses := limiter.Status()
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(statuses))
+	}
+	if statuses[0].Key != "sensor:noisy" || statuses[0].Throttled != 2 || statuses[0].Allowed != 1 {
+		t.Errorf("Expected the throttled sensor first, got %+v", statuses[0])
+	}
+	if statuses[0].LastThrottled == nil {
+		t.Errorf("Expected the last throttle time to be set")
+	}
+	if statuses[1].Throttled != 0 || statuses[1].LastThrottled != nil {
+		t.Errorf("Expected the quiet sensor not to be throttled, got %+v", statuses[1])
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("default:5:60, reference:10:600,anonymous:0.5:10")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(limits) != 3 || limits["reference"] != (Limit{Rate: 10, Burst: 600}) || limits[AnonymousClass].Rate != 0.5 {
+		t.Errorf("Unexpected limits %+v", limits)
+	}
+
+	for _, spec := range []string{"default:5", "default:x:60", "default:5:0", ":5:60"} {
+		if _, err := ParseLimits(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
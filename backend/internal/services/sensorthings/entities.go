@@ -0,0 +1,245 @@
+package sensorthings
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/parameters"
+)
+
+// Entity set names
+const (
+	Things       = "Things"
+	Locations    = "Locations"
+	Datastreams  = "Datastreams"
+	Observations = "Observations"
+)
+
+// measurementType is the observation type of all datastreams, whose results are numbers
+const measurementType = "http://www.opengis.net/def/observationType/OGC-OM/2.0/OM_Measurement"
+
+// ThingRecord is a sensor with readings and its last reported location
+type ThingRecord struct {
+	ID        string
+	Name      string
+	Class     string
+	Latitude  float64
+	Longitude float64
+	LastSeen  time.Time
+}
+
+// DatastreamRecord is the series of readings of one parameter by one sensor
+type DatastreamRecord struct {
+	ThingID   string
+	Parameter string
+	Start     time.Time
+	End       time.Time
+}
+
+// ID returns the datastream ID, the sensor ID and parameter separated by a colon
+func (r DatastreamRecord) ID() string {
+	return DatastreamID(r.ThingID, r.Parameter)
+}
+
+// DatastreamID returns the ID of the datastream of a parameter measured by a sensor
+func DatastreamID(thingID, parameter string) string {
+	return thingID + ":" + parameter
+}
+
+// SplitDatastreamID returns the sensor ID and parameter of a datastream ID. Sensor IDs may contain
+// colons but parameter names do not, so the ID is split at the last colon.
+func SplitDatastreamID(id string) (thingID, parameter string, ok bool) {
+	i := strings.LastIndex(id, ":")
+	if i <= 0 || i == len(id)-1 {
+		return "", "", false
+	}
+	return id[:i], id[i+1:], true
+}
+
+// Collection is the response to a request for an entity set
+type Collection struct {
+	Count    *int64      `json:"@iot.count,omitempty"`
+	NextLink string      `json:"@iot.nextLink,omitempty"`
+	Value    interface{} `json:"value"`
+}
+
+// Thing is the SensorThings representation of a sensor
+type Thing struct {
+	ID              string            `json:"@iot.id"`
+	SelfLink        string            `json:"@iot.selfLink"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description"`
+	Properties      map[string]string `json:"properties,omitempty"`
+	LocationsLink   string            `json:"Locations@iot.navigationLink"`
+	DatastreamsLink string            `json:"Datastreams@iot.navigationLink"`
+}
+
+// Location is the SensorThings representation of the last reported location of a sensor
+type Location struct {
+	ID           string   `json:"@iot.id"`
+	SelfLink     string   `json:"@iot.selfLink"`
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	EncodingType string   `json:"encodingType"`
+	Location     GeoPoint `json:"location"`
+	ThingsLink   string   `json:"Things@iot.navigationLink"`
+}
+
+// GeoPoint is a GeoJSON point
+type GeoPoint struct {
+	Type string `json:"type"`
+	// Coordinates are longitude, latitude as in GeoJSON
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// UnitOfMeasurement describes the unit of a datastream
+type UnitOfMeasurement struct {
+	Name       string `json:"name"`
+	Symbol     string `json:"symbol"`
+	Definition string `json:"definition"`
+}
+
+// Datastream is the SensorThings representation of the readings of one parameter by one sensor
+type Datastream struct {
+	ID                string            `json:"@iot.id"`
+	SelfLink          string            `json:"@iot.selfLink"`
+	Name              string            `json:"name"`
+	Description       string            `json:"description"`
+	ObservationType   string            `json:"observationType"`
+	UnitOfMeasurement UnitOfMeasurement `json:"unitOfMeasurement"`
+	PhenomenonTime    string            `json:"phenomenonTime,omitempty"`
+	Properties        map[string]string `json:"properties"`
+	ThingLink         string            `json:"Thing@iot.navigationLink"`
+	ObservationsLink  string            `json:"Observations@iot.navigationLink"`
+}
+
+// Observation is the SensorThings representation of a reading
+type Observation struct {
+	ID             string                 `json:"@iot.id"`
+	SelfLink       string                 `json:"@iot.selfLink"`
+	PhenomenonTime time.Time              `json:"phenomenonTime"`
+	ResultTime     time.Time              `json:"resultTime"`
+	Result         float64                `json:"result"`
+	Parameters     map[string]interface{} `json:"parameters,omitempty"`
+	DatastreamLink string                 `json:"Datastream@iot.navigationLink"`
+}
+
+// unitNames are the names of the canonical units
+var unitNames = map[string]string{
+	parameters.MicrogramsPerCubicMeter: "microgram per cubic meter",
+	parameters.MilligramsPerCubicMeter: "milligram per cubic meter",
+	parameters.PartsPerBillion:         "part per billion",
+	parameters.PartsPerMillion:         "part per million",
+}
+
+// EntityLink returns the URL of an entity, such as <base>/Things('station-7')
+func EntityLink(base, set, id string) string {
+	return fmt.Sprintf("%s/%s('%s')", base, set, url.PathEscape(strings.ReplaceAll(id, "'", "''")))
+}
+
+// NewThing converts a thing record to its SensorThings representation
+func NewThing(base string, r ThingRecord) Thing {
+	self := EntityLink(base, Things, r.ID)
+	thing := Thing{
+		ID:              r.ID,
+		SelfLink:        self,
+		Name:            r.Name,
+		Description:     "Air quality sensor " + r.ID,
+		LocationsLink:   self + "/" + Locations,
+		DatastreamsLink: self + "/" + Datastreams,
+	}
+	if r.Class != "" {
+		thing.Properties = map[string]string{"class": r.Class}
+	}
+	return thing
+}
+
+// NewLocation converts a thing record to the SensorThings representation of its location
+func NewLocation(base string, r ThingRecord) Location {
+	self := EntityLink(base, Locations, r.ID)
+	return Location{
+		ID:           r.ID,
+		SelfLink:     self,
+		Name:         r.Name,
+		Description:  "Last reported location of " + r.ID,
+		EncodingType: "application/geo+json",
+		Location:     GeoPoint{Type: "Point", Coordinates: [2]float64{r.Longitude, r.Latitude}},
+		ThingsLink:   self + "/" + Things,
+	}
+}
+
+// NewDatastream converts a datastream record to its SensorThings representation
+func NewDatastream(base string, r DatastreamRecord) Datastream {
+	self := EntityLink(base, Datastreams, r.ID())
+
+	unit := UnitOfMeasurement{Definition: "http://unitsofmeasure.org/ucum.html"}
+	if def, ok := parameters.Default().Lookup(r.Parameter); ok {
+		unit.Symbol = def.CanonicalUnit
+		unit.Name = unitNames[def.CanonicalUnit]
+	}
+
+	datastream := Datastream{
+		ID:                r.ID(),
+		SelfLink:          self,
+		Name:              r.Parameter + " at " + r.ThingID,
+		Description:       r.Parameter + " readings of sensor " + r.ThingID,
+		ObservationType:   measurementType,
+		UnitOfMeasurement: unit,
+		Properties:        map[string]string{"parameter": r.Parameter},
+		ThingLink:         self + "/Thing",
+		ObservationsLink:  self + "/" + Observations,
+	}
+	if !r.Start.IsZero() {
+		datastream.PhenomenonTime = r.Start.UTC().Format(time.RFC3339) + "/" + r.End.UTC().Format(time.RFC3339)
+	}
+	return datastream
+}
+
+// NewObservation converts a reading to its SensorThings representation. The reading's
+// original unit, metadata and tags are returned as observation parameters.
+func NewObservation(base string, data *models.AirQualityData) Observation {
+	self := EntityLink(base, Observations, data.ID.String())
+
+	params := map[string]interface{}{}
+	if data.OriginalUnit != "" && data.OriginalUnit != data.Unit {
+		params["original_value"] = data.OriginalValue
+		params["original_unit"] = data.OriginalUnit
+	}
+	if data.ObservationID != nil {
+		params["observation_id"] = data.ObservationID.String()
+	}
+	if data.Humidity != nil {
+		params["humidity"] = *data.Humidity
+	}
+	if data.Temperature != nil {
+		params["temperature"] = *data.Temperature
+	}
+	if data.Altitude != nil {
+		params["altitude"] = *data.Altitude
+	}
+	if data.SensorModel != "" {
+		params["sensor_model"] = data.SensorModel
+	}
+	if data.FirmwareVersion != "" {
+		params["firmware_version"] = data.FirmwareVersion
+	}
+	if len(data.Tags) > 0 {
+		params["tags"] = data.Tags
+	}
+	if data.Lateness != "" {
+		params["lateness"] = data.Lateness
+	}
+
+	return Observation{
+		ID:             data.ID.String(),
+		SelfLink:       self,
+		PhenomenonTime: data.Timestamp.UTC(),
+		ResultTime:     data.Timestamp.UTC(),
+		Result:         data.Value,
+		Parameters:     params,
+		DatastreamLink: EntityLink(base, Datastreams, DatastreamID(data.SensorID, data.Parameter)),
+	}
+}
@@ -0,0 +1,298 @@
+package sensorthings
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expr is a parsed $filter expression
+type Expr interface {
+	expr()
+}
+
+// Logical combines two expressions with and or or
+type Logical struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// Not negates an expression
+type Not struct {
+	Expr Expr
+}
+
+// Comparison compares a property with a literal, such as result gt 35
+type Comparison struct {
+	Property string
+	Op       string
+	Value    Literal
+}
+
+func (Logical) expr()    {}
+func (Not) expr()        {}
+func (Comparison) expr() {}
+
+// LiteralKind is the type of a literal in a $filter expression
+type LiteralKind int
+
+const (
+	LiteralString LiteralKind = iota
+	LiteralNumber
+	LiteralTime
+	LiteralBool
+	LiteralNull
+)
+
+// Literal is a constant in a $filter expression
+type Literal struct {
+	Kind   LiteralKind
+	String string
+	Number float64
+	Time   time.Time
+	Bool   bool
+}
+
+// comparisonOps maps the OData comparison operators to SQL
+var comparisonOps = map[string]string{
+	"eq": "=",
+	"ne": "<>",
+	"gt": ">",
+	"ge": ">=",
+	"lt": "<",
+	"le": "<=",
+}
+
+// flippedOps is the operator with its operands swapped, for literals on the left such as 35 lt result
+var flippedOps = map[string]string{
+	"eq": "eq",
+	"ne": "ne",
+	"gt": "lt",
+	"ge": "le",
+	"lt": "gt",
+	"le": "ge",
+}
+
+// token is a lexical token of a $filter expression
+type token struct {
+	kind  tokenKind
+	text  string
+	value Literal
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenLiteral
+	tokenOpen
+	tokenClose
+)
+
+// ParseFilter parses the supported subset of OData $filter: the comparison operators eq, ne, gt, ge,
+// lt and le between a property and a literal, the logical operators and, or and not, and parentheses.
+// Literals are 'strings', numbers, ISO 8601 times, true, false and null.
+func ParseFilter(input string) (Expr, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q in $filter", tok.text)
+	}
+	return expr, nil
+}
+
+// tokenize splits a $filter expression into tokens
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")"})
+			i++
+		case r == '\'':
+			// Strings are quoted with single quotes; a quote inside a string is doubled
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string in $filter")
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: sb.String(), value: Literal{Kind: LiteralString, String: sb.String()}})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || strings.ContainsRune("-+.:TZeE", runes[i])) {
+				i++
+			}
+			text := string(runes[start:i])
+			literal, err := parseNumberOrTime(text)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: text, value: literal})
+		case unicode.IsLetter(r) || r == '@' || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("@_./", runes[i])) {
+				i++
+			}
+			text := string(runes[start:i])
+			switch text {
+			case "true", "false":
+				tokens = append(tokens, token{kind: tokenLiteral, text: text, value: Literal{Kind: LiteralBool, Bool: text == "true"}})
+			case "null":
+				tokens = append(tokens, token{kind: tokenLiteral, text: text, value: Literal{Kind: LiteralNull}})
+			default:
+				tokens = append(tokens, token{kind: tokenIdent, text: text})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected character %q in $filter", r)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// parseNumberOrTime parses an unquoted number or ISO 8601 time
+func parseNumberOrTime(text string) (Literal, error) {
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return Literal{Kind: LiteralTime, Time: t}, nil
+	}
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return Literal{Kind: LiteralNumber, Number: n}, nil
+	}
+	return Literal{}, fmt.Errorf("invalid literal %q in $filter", text)
+}
+
+// filterParser is a recursive descent parser over the tokens of a $filter expression
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isKeyword reports whether the next token is the given keyword
+func (p *filterParser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == tokenIdent && tok.text == keyword
+}
+
+func (p *filterParser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (Expr, error) {
+	if p.isKeyword("not") {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (Expr, error) {
+	if p.peek().kind == tokenOpen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenClose {
+			return nil, fmt.Errorf("missing closing parenthesis in $filter")
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (Expr, error) {
+	left := p.next()
+	op := p.next()
+	right := p.next()
+
+	if _, ok := comparisonOps[op.text]; !ok || op.kind != tokenIdent {
+		return nil, fmt.Errorf("expected a comparison operator after %q in $filter", left.text)
+	}
+
+	switch {
+	case left.kind == tokenIdent && right.kind == tokenLiteral:
+		return Comparison{Property: left.text, Op: op.text, Value: right.value}, nil
+	case left.kind == tokenLiteral && right.kind == tokenIdent:
+		return Comparison{Property: right.text, Op: flippedOps[op.text], Value: left.value}, nil
+	default:
+		return nil, fmt.Errorf("comparisons in $filter must be between a property and a literal")
+	}
+}
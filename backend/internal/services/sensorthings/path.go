@@ -0,0 +1,67 @@
+package sensorthings
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Segment is a resource path segment, such as Things or Things('station-7')
+type Segment struct {
+	Name  string
+	ID    string
+	HasID bool
+}
+
+// ParsePath splits a resource path such as /Things('station-7')/Datastreams into segments.
+// IDs may be quoted strings, with quotes doubled inside, or unquoted.
+func ParsePath(path string) ([]Segment, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, nil
+	}
+
+	var segments []Segment
+	for path != "" {
+		var part string
+		part, path = nextSegment(path)
+
+		open := strings.IndexByte(part, '(')
+		if open < 0 {
+			segments = append(segments, Segment{Name: part})
+			continue
+		}
+		if !strings.HasSuffix(part, ")") || open == 0 {
+			return nil, fmt.Errorf("invalid path segment %q", part)
+		}
+
+		id := part[open+1 : len(part)-1]
+		if strings.HasPrefix(id, "'") {
+			if len(id) < 2 || !strings.HasSuffix(id, "'") {
+				return nil, fmt.Errorf("invalid ID in path segment %q", part)
+			}
+			id = strings.ReplaceAll(id[1:len(id)-1], "''", "'")
+		}
+		if id == "" {
+			return nil, fmt.Errorf("empty ID in path segment %q", part)
+		}
+		segments = append(segments, Segment{Name: part[:open], ID: id, HasID: true})
+	}
+	return segments, nil
+}
+
+// nextSegment returns the first segment of a path and the rest. Slashes inside a quoted ID
+// do not end the segment.
+func nextSegment(path string) (string, string) {
+	quoted := false
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\'':
+			quoted = !quoted
+		case '/':
+			if !quoted {
+				return path[:i], path[i+1:]
+			}
+		}
+	}
+	return path, ""
+}
@@ -0,0 +1,208 @@
+package sensorthings
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultTop is the page size when $top is not given
+	DefaultTop = 100
+	// MaxTop is the largest page size a client may request
+	MaxTop = 1000
+)
+
+// ErrInvalidQuery is returned when a query refers to properties that cannot be filtered or sorted
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query holds the query options of a collection request
+type Query struct {
+	// Filter is nil when there is no $filter
+	Filter  Expr
+	OrderBy []OrderTerm
+	Top     int
+	Skip    int
+	Count   bool
+}
+
+// OrderTerm is a property in $orderby
+type OrderTerm struct {
+	Property   string
+	Descending bool
+}
+
+// ColumnType is the SQL type of a filterable property
+type ColumnType int
+
+const (
+	ColumnText ColumnType = iota
+	ColumnNumber
+	ColumnTime
+)
+
+// Column maps an entity property to a SQL column
+type Column struct {
+	SQL  string
+	Type ColumnType
+}
+
+// ParseQuery parses $filter, $top, $skip, $orderby and $count. Other system query options,
+// such as $expand and $select, are rejected because the facade does not implement them.
+func ParseQuery(values url.Values) (*Query, error) {
+	q := &Query{Top: DefaultTop}
+
+	for key := range values {
+		switch key {
+		case "$filter", "$top", "$skip", "$orderby", "$count":
+		default:
+			if strings.HasPrefix(key, "$") {
+				return nil, fmt.Errorf("query option %s is not supported", key)
+			}
+		}
+	}
+
+	if raw := values.Get("$filter"); raw != "" {
+		filter, err := ParseFilter(raw)
+		if err != nil {
+			return nil, err
+		}
+		q.Filter = filter
+	}
+
+	if raw := values.Get("$top"); raw != "" {
+		top, err := strconv.Atoi(raw)
+		if err != nil || top < 0 {
+			return nil, fmt.Errorf("$top must be a non-negative integer")
+		}
+		if top > MaxTop {
+			top = MaxTop
+		}
+		q.Top = top
+	}
+
+	if raw := values.Get("$skip"); raw != "" {
+		skip, err := strconv.Atoi(raw)
+		if err != nil || skip < 0 {
+			return nil, fmt.Errorf("$skip must be a non-negative integer")
+		}
+		q.Skip = skip
+	}
+
+	if raw := values.Get("$orderby"); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			fields := strings.Fields(part)
+			if len(fields) == 0 || len(fields) > 2 {
+				return nil, fmt.Errorf("invalid $orderby term %q", strings.TrimSpace(part))
+			}
+			term := OrderTerm{Property: fields[0]}
+			if len(fields) == 2 {
+				switch strings.ToLower(fields[1]) {
+				case "asc":
+				case "desc":
+					term.Descending = true
+				default:
+					return nil, fmt.Errorf("invalid $orderby direction %q", fields[1])
+				}
+			}
+			q.OrderBy = append(q.OrderBy, term)
+		}
+	}
+
+	switch values.Get("$count") {
+	case "", "false":
+	case "true":
+		q.Count = true
+	default:
+		return nil, fmt.Errorf("$count must be true or false")
+	}
+
+	return q, nil
+}
+
+// CompileFilter translates a $filter expression into a SQL condition over the given properties.
+// Literals are passed as placeholders appended to args, so the condition is safe to embed.
+func CompileFilter(expr Expr, properties map[string]Column, args []interface{}) (string, []interface{}, error) {
+	switch e := expr.(type) {
+	case Logical:
+		left, args, err := CompileFilter(e.Left, properties, args)
+		if err != nil {
+			return "", nil, err
+		}
+		right, args, err := CompileFilter(e.Right, properties, args)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(e.Op), right), args, nil
+	case Not:
+		inner, args, err := CompileFilter(e.Expr, properties, args)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(NOT %s)", inner), args, nil
+	case Comparison:
+		return compileComparison(e, properties, args)
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported $filter expression", ErrInvalidQuery)
+	}
+}
+
+// compileComparison translates a single property comparison
+func compileComparison(c Comparison, properties map[string]Column, args []interface{}) (string, []interface{}, error) {
+	column, ok := properties[c.Property]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: property %s cannot be filtered", ErrInvalidQuery, c.Property)
+	}
+
+	if c.Value.Kind == LiteralNull {
+		switch c.Op {
+		case "eq":
+			return fmt.Sprintf("(%s IS NULL)", column.SQL), args, nil
+		case "ne":
+			return fmt.Sprintf("(%s IS NOT NULL)", column.SQL), args, nil
+		default:
+			return "", nil, fmt.Errorf("%w: null can only be compared with eq or ne", ErrInvalidQuery)
+		}
+	}
+
+	var value interface{}
+	switch column.Type {
+	case ColumnText:
+		if c.Value.Kind != LiteralString {
+			return "", nil, fmt.Errorf("%w: property %s must be compared with a string", ErrInvalidQuery, c.Property)
+		}
+		value = c.Value.String
+	case ColumnNumber:
+		if c.Value.Kind != LiteralNumber {
+			return "", nil, fmt.Errorf("%w: property %s must be compared with a number", ErrInvalidQuery, c.Property)
+		}
+		value = c.Value.Number
+	case ColumnTime:
+		if c.Value.Kind != LiteralTime {
+			return "", nil, fmt.Errorf("%w: property %s must be compared with an ISO 8601 time", ErrInvalidQuery, c.Property)
+		}
+		value = c.Value.Time
+	}
+
+	args = append(args, value)
+	return fmt.Sprintf("(%s %s $%d)", column.SQL, comparisonOps[c.Op], len(args)), args, nil
+}
+
+// CompileOrderBy translates $orderby terms into a SQL ORDER BY list, or "" when there are none
+func CompileOrderBy(terms []OrderTerm, properties map[string]Column) (string, error) {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		column, ok := properties[term.Property]
+		if !ok {
+			return "", fmt.Errorf("%w: property %s cannot be ordered by", ErrInvalidQuery, term.Property)
+		}
+		direction := "ASC"
+		if term.Descending {
+			direction = "DESC"
+		}
+		parts = append(parts, column.SQL+" "+direction)
+	}
+	return strings.Join(parts, ", "), nil
+}
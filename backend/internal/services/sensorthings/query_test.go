@@ -0,0 +1,157 @@
+package sensorthings
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testProperties = map[string]Column{
+	"@iot.id":            {SQL: "iot_id", Type: ColumnText},
+	"result":             {SQL: "value", Type: ColumnNumber},
+	"phenomenonTime":     {SQL: "timestamp", Type: ColumnTime},
+	"Datastream/@iot.id": {SQL: "datastream_id", Type: ColumnText},
+}
+
+func TestCompileFilter(t *testing.T) {
+	since := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected string
+		args     []interface{}
+	}{
+		{"Comparison", "result gt 35", "(value > $2)", []interface{}{"fixed", 35.0}},
+		{"Literal On Left", "35 lt result", "(value > $2)", []interface{}{"fixed", 35.0}},
+		{"Time", "phenomenonTime ge 2025-05-01T00:00:00Z", "(timestamp >= $2)", []interface{}{"fixed", since}},
+		{"String With Quote", "Datastream/@iot.id eq 'o''brien:PM2.5'", "(datastream_id = $2)", []interface{}{"fixed", "o'brien:PM2.5"}},
+		{"Precedence", "result gt 35 or result lt 1 and not (phenomenonTime lt 2025-05-01T00:00:00Z)",
+			"((value > $2) OR ((value < $3) AND (NOT (timestamp < $4))))", []interface{}{"fixed", 35.0, 1.0, since}},
+		{"Null", "@iot.id ne null", "(iot_id IS NOT NULL)", []interface{}{"fixed"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, err := ParseFilter(tc.filter)
+			if err != nil {
+				t.Fatalf("Unexpected parse error: %v", err)
+			}
+
+			sql, args, err := CompileFilter(expr, testProperties, []interface{}{"fixed"})
+			if err != nil {
+				t.Fatalf("Unexpected compile error: %v", err)
+			}
+			if sql != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, sql)
+			}
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("Expected args %v, got %v", tc.args, args)
+			}
+		})
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	parseErrors := []string{
+		"result gt",
+		"result 35",
+		"(result gt 35",
+		"result gt 'open",
+		"result gt 35 and",
+		"result eq result",
+		"result gt 35; DROP TABLE sensors",
+	}
+	for _, filter := range parseErrors {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Errorf("Expected parse error for %q", filter)
+		}
+	}
+
+	compileErrors := []string{
+		"value gt 35",
+		"result gt '35'",
+		"phenomenonTime gt 35",
+		"result gt null",
+	}
+	for _, filter := range compileErrors {
+		expr, err := ParseFilter(filter)
+		if err != nil {
+			t.Fatalf("Unexpected parse error for %q: %v", filter, err)
+		}
+		if _, _, err := CompileFilter(expr, testProperties, nil); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Expected invalid query for %q, got %v", filter, err)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	values := url.Values{
+		"$top":     {"5000"},
+		"$skip":    {"20"},
+		"$orderby": {"phenomenonTime desc, result"},
+		"$count":   {"true"},
+	}
+
+	q, err := ParseQuery(values)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if q.Top != MaxTop || q.Skip != 20 || !q.Count || q.Filter != nil {
+		t.Errorf("Unexpected query %+v", q)
+	}
+
+	order, err := CompileOrderBy(q.OrderBy, testProperties)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if order != "timestamp DESC, value ASC" {
+		t.Errorf("Expected timestamp DESC, value ASC, got %s", order)
+	}
+
+	for _, invalid := range []url.Values{
+		{"$top": {"-1"}},
+		{"$skip": {"x"}},
+		{"$orderby": {"result sideways"}},
+		{"$expand": {"Datastreams"}},
+		{"$count": {"yes"}},
+	} {
+		if _, err := ParseQuery(invalid); err == nil {
+			t.Errorf("Expected error for %v", invalid)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []Segment
+		valid    bool
+	}{
+		{"/", nil, true},
+		{"/Things", []Segment{{Name: "Things"}}, true},
+		{"/Things('station-7')/Datastreams", []Segment{{Name: "Things", ID: "station-7", HasID: true}, {Name: "Datastreams"}}, true},
+		{"/Datastreams('openaq:8118:PM2.5')", []Segment{{Name: "Datastreams", ID: "openaq:8118:PM2.5", HasID: true}}, true},
+		{"/Things('a/b')", []Segment{{Name: "Things", ID: "a/b", HasID: true}}, true},
+		{"/Things('it''s')", []Segment{{Name: "Things", ID: "it's", HasID: true}}, true},
+		{"/Things(42)", []Segment{{Name: "Things", ID: "42", HasID: true}}, true},
+		{"/Things('')", nil, false},
+		{"/Things('open)", nil, false},
+		{"/(1)", nil, false},
+	}
+
+	for _, tc := range tests {
+		segments, err := ParsePath(tc.path)
+		if tc.valid && err != nil {
+			t.Errorf("Unexpected error for %s: %v", tc.path, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("Expected error for %s", tc.path)
+		}
+		if tc.valid && !reflect.DeepEqual(segments, tc.expected) {
+			t.Errorf("Expected %+v for %s, got %+v", tc.expected, tc.path, segments)
+		}
+	}
+}
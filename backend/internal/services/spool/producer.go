@@ -0,0 +1,65 @@
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/user/airpollution/internal/models"
+	"github.com/user/airpollution/internal/services/kafka"
+	"github.com/user/airpollution/internal/services/logger"
+)
+
+// forwardBatchSize is the number of spooled records sent to Kafka per write
+const forwardBatchSize = 500
+
+// Producer publishes readings to Kafka and falls back to the spool when Kafka is unavailable.
+// While the spool holds records, new readings are appended behind them to keep their order.
+type Producer struct {
+	producer *kafka.Producer
+	spool    *Spool
+}
+
+// NewProducer creates a spooling producer
+func NewProducer(producer *kafka.Producer, spool *Spool) *Producer {
+	return &Producer{
+		producer: producer,
+		spool:    spool,
+	}
+}
+
+// ProduceAirQualityData publishes a single reading
+func (p *Producer) ProduceAirQualityData(ctx context.Context, data *models.AirQualityData) error {
+	return p.ProduceAirQualityDataBatch(ctx, []*models.AirQualityData{data})
+}
+
+// ProduceAirQualityDataBatch publishes readings to Kafka, or spools them if Kafka is down or the spool is not yet drained
+func (p *Producer) ProduceAirQualityDataBatch(ctx context.Context, data []*models.AirQualityData) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if p.spool.Empty() {
+		err := p.producer.ProduceAirQualityDataBatch(ctx, data)
+		if err == nil {
+			return nil
+		}
+		logger.Warn("Kafka unavailable, spooling %d readings: %v", len(data), err)
+	}
+
+	values := make([][]byte, len(data))
+	for i, item := range data {
+		jsonData, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("error marshaling air quality data: %w", err)
+		}
+		values[i] = jsonData
+	}
+
+	return p.spool.Append(values)
+}
+
+// Forward drains the spool to Kafka until ctx is cancelled
+func (p *Producer) Forward(ctx context.Context) {
+	p.spool.Forward(ctx, p.producer.ProduceMessages, forwardBatchSize)
+}
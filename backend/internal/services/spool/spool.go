@@ -0,0 +1,528 @@
+package spool
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/airpollution/internal/services/logger"
+)
+
+const (
+	// segmentSuffix is the file extension of spool segments
+	segmentSuffix = ".seg"
+	// cursorFile stores the position of the next record to forward
+	cursorFile = "cursor"
+	// recordHeaderSize is the length prefix plus the CRC32 of each record
+	recordHeaderSize = 8
+)
+
+// ErrFull is returned when appending would exceed the maximum spool size
+var ErrFull = errors.New("spool is full")
+
+// Options configures a spool
+type Options struct {
+	// Dir is the directory holding the segment files
+	Dir string
+	// MaxBytes bounds the total size of all segments on disk
+	MaxBytes int64
+	// SegmentBytes is the size at which a new segment is started
+	SegmentBytes int64
+}
+
+// Stats describes the records waiting in the spool
+type Stats struct {
+	Records  int   `json:"records"`
+	Bytes    int64 `json:"bytes"`
+	Segments int   `json:"segments"`
+}
+
+// SendFunc delivers a batch of spooled records
+type SendFunc func(ctx context.Context, values [][]byte) error
+
+// Spool is an on-disk write-ahead queue made of append-only, fsync'd segment files.
+// Records are forwarded in the order they were appended.
+type Spool struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+
+	mu       sync.Mutex
+	segments []segment // oldest first; the last one is open for appending
+	writer   *os.File
+	cursor   position
+	records  int
+	notify   chan struct{}
+}
+
+// segment is a spool file identified by its sequence number
+type segment struct {
+	seq  uint64
+	size int64
+}
+
+// position identifies a record by segment and byte offset
+type position struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// Open opens or creates a spool in the given directory and recovers its state.
+// A partially written record at the end of a segment, left by a crash, is truncated.
+func Open(opts Options) (*Spool, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("spool directory must not be empty")
+	}
+	if opts.SegmentBytes <= 0 {
+		opts.SegmentBytes = 16 << 20
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 1 << 30
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &Spool{
+		dir:          opts.Dir,
+		maxBytes:     opts.MaxBytes,
+		segmentBytes: opts.SegmentBytes,
+		notify:       make(chan struct{}, 1),
+	}
+
+	seqs, err := s.listSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.readCursor(); err != nil {
+		return nil, err
+	}
+	if len(seqs) > 0 && s.cursor.Segment < seqs[0] {
+		s.cursor = position{Segment: seqs[0]}
+	}
+
+	for _, seq := range seqs {
+		if seq < s.cursor.Segment {
+			// Fully forwarded before the last shutdown
+			if err := os.Remove(s.segmentPath(seq)); err != nil {
+				return nil, fmt.Errorf("failed to remove forwarded segment: %w", err)
+			}
+			continue
+		}
+
+		size, count, err := s.recoverSegment(seq)
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, segment{seq: seq, size: size})
+		s.records += count
+	}
+
+	next := uint64(1)
+	if len(s.segments) > 0 {
+		next = s.segments[len(s.segments)-1].seq
+	} else if s.cursor.Segment > 0 {
+		next = s.cursor.Segment
+	}
+	if err := s.openWriter(next); err != nil {
+		return nil, err
+	}
+	if s.cursor.Segment == 0 {
+		s.cursor = position{Segment: next}
+	}
+
+	return s, nil
+}
+
+// Append durably writes records to the spool. The records are fsync'd before Append returns.
+func (s *Spool) Append(values [][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var buf []byte
+	for _, value := range values {
+		var header [recordHeaderSize]byte
+		binary.BigEndian.PutUint32(header[0:4], uint32(len(value)))
+		binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(value))
+		buf = append(buf, header[:]...)
+		buf = append(buf, value...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.diskBytesLocked()+int64(len(buf)) > s.maxBytes {
+		return ErrFull
+	}
+
+	active := &s.segments[len(s.segments)-1]
+	if active.size > 0 && active.size+int64(len(buf)) > s.segmentBytes {
+		if err := s.openWriter(active.seq + 1); err != nil {
+			return err
+		}
+		active = &s.segments[len(s.segments)-1]
+	}
+
+	if _, err := s.writer.Write(buf); err != nil {
+		// Drop the partial write so the segment stays readable
+		s.writer.Truncate(active.size)
+		return fmt.Errorf("failed to write to spool: %w", err)
+	}
+	if err := s.writer.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %w", err)
+	}
+
+	active.size += int64(len(buf))
+	s.records += len(values)
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Empty reports whether all records have been forwarded
+func (s *Spool) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records == 0
+}
+
+// Stats returns the number and size of records waiting to be forwarded
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending int64
+	for _, seg := range s.segments {
+		pending += seg.size
+	}
+	pending -= s.cursor.Offset
+
+	return Stats{
+		Records:  s.records,
+		Bytes:    pending,
+		Segments: len(s.segments),
+	}
+}
+
+// Forward sends spooled records in order until ctx is cancelled. A batch is only removed from the
+// spool after send succeeds; failed sends are retried with exponential backoff.
+func (s *Spool) Forward(ctx context.Context, send SendFunc, batchSize int) {
+	backoffTime := 1 * time.Second // Start with 1 second backoff
+	maxBackoff := 30 * time.Second // Max backoff of 30 seconds
+
+	for {
+		values, next, err := s.peek(batchSize)
+		if err != nil {
+			logger.Error("Failed to read spool: %v", err)
+		}
+
+		if len(values) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.notify:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = send(sendCtx, values)
+		cancel()
+
+		if err != nil {
+			logger.Warn("Failed to forward %d spooled records, retrying: %v", len(values), err)
+			jitter := time.Duration(rand.Intn(500)) * time.Millisecond
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoffTime + jitter):
+			}
+			backoffTime *= 2
+			if backoffTime > maxBackoff {
+				backoffTime = maxBackoff
+			}
+			continue
+		}
+
+		backoffTime = 1 * time.Second
+		if err := s.commit(next, len(values)); err != nil {
+			logger.Error("Failed to commit spool cursor: %v", err)
+		}
+	}
+}
+
+// Close closes the active segment
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writer.Close()
+}
+
+// peek reads up to max records from the cursor without removing them
+func (s *Spool) peek(max int) ([][]byte, position, error) {
+	s.mu.Lock()
+	// Skip over segments that were read completely
+	for len(s.segments) > 1 && s.segments[0].seq == s.cursor.Segment && s.cursor.Offset >= s.segments[0].size {
+		if err := s.advanceSegmentLocked(); err != nil {
+			s.mu.Unlock()
+			return nil, s.cursor, err
+		}
+	}
+	cursor := s.cursor
+	end := s.segments[0].size
+	s.mu.Unlock()
+
+	if cursor.Offset >= end {
+		return nil, cursor, nil
+	}
+
+	f, err := os.Open(s.segmentPath(cursor.Segment))
+	if err != nil {
+		return nil, cursor, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(cursor.Offset, io.SeekStart); err != nil {
+		return nil, cursor, fmt.Errorf("failed to seek spool segment: %w", err)
+	}
+
+	reader := bufio.NewReader(io.LimitReader(f, end-cursor.Offset))
+	var values [][]byte
+	next := cursor
+	for len(values) < max {
+		value, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return values, next, err
+		}
+		values = append(values, value)
+		next.Offset += int64(recordHeaderSize + len(value))
+	}
+
+	return values, next, nil
+}
+
+// commit moves the cursor past forwarded records and persists it
+func (s *Spool) commit(next position, count int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cursor = next
+	s.records -= count
+	return s.writeCursorLocked()
+}
+
+// advanceSegmentLocked deletes the first segment and moves the cursor to the start of the next one
+func (s *Spool) advanceSegmentLocked() error {
+	done := s.segments[0]
+	s.segments = s.segments[1:]
+	s.cursor = position{Segment: s.segments[0].seq}
+	if err := s.writeCursorLocked(); err != nil {
+		return err
+	}
+	if err := os.Remove(s.segmentPath(done.seq)); err != nil {
+		return fmt.Errorf("failed to remove forwarded segment: %w", err)
+	}
+	return nil
+}
+
+// diskBytesLocked returns the total size of all segments on disk
+func (s *Spool) diskBytesLocked() int64 {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	return total
+}
+
+// openWriter opens the segment with the given sequence number for appending
+func (s *Spool) openWriter(seq uint64) error {
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat spool segment: %w", err)
+	}
+
+	if s.writer != nil {
+		s.writer.Close()
+	}
+	s.writer = f
+
+	if len(s.segments) == 0 || s.segments[len(s.segments)-1].seq != seq {
+		s.segments = append(s.segments, segment{seq: seq, size: info.Size()})
+	}
+
+	return syncDir(s.dir)
+}
+
+// recoverSegment validates the records of a segment, truncates a torn tail and counts the records after the cursor
+func (s *Spool) recoverSegment(seq uint64) (int64, int, error) {
+	path := s.segmentPath(seq)
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	count := 0
+	for {
+		value, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logger.Warn("Truncating spool segment %s at offset %d: %v", path, offset, err)
+			if err := f.Truncate(offset); err != nil {
+				return 0, 0, fmt.Errorf("failed to truncate spool segment: %w", err)
+			}
+			break
+		}
+		if seq > s.cursor.Segment || offset >= s.cursor.Offset {
+			count++
+		}
+		offset += int64(recordHeaderSize + len(value))
+	}
+
+	return offset, count, nil
+}
+
+// listSegments returns the sequence numbers of all segments in order
+func (s *Spool) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spool directory: %w", err)
+	}
+
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// readCursor loads the persisted cursor, if any
+func (s *Spool) readCursor() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, cursorFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read spool cursor: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.cursor); err != nil {
+		return fmt.Errorf("failed to parse spool cursor: %w", err)
+	}
+	return nil
+}
+
+// writeCursorLocked atomically persists the cursor
+func (s *Spool) writeCursorLocked() error {
+	data, err := json.Marshal(s.cursor)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(s.dir, cursorFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync spool cursor: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+
+	if err := os.Rename(tmp, filepath.Join(s.dir, cursorFile)); err != nil {
+		return fmt.Errorf("failed to replace spool cursor: %w", err)
+	}
+	return syncDir(s.dir)
+}
+
+// segmentPath returns the file path of a segment
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+// readRecord reads one length-prefixed, checksummed record
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("torn record header: %w", err)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, fmt.Errorf("torn record: %w", err)
+	}
+	if crc32.ChecksumIEEE(value) != checksum {
+		return nil, errors.New("record checksum mismatch")
+	}
+
+	return value, nil
+}
+
+// syncDir fsyncs a directory so that created and renamed files survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
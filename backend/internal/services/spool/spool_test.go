@@ -0,0 +1,173 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func records(from, to int) [][]byte {
+	var values [][]byte
+	for i := from; i < to; i++ {
+		values = append(values, []byte(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+	return values
+}
+
+// forwardAll drains the spool with send until it is empty
+func forwardAll(t *testing.T, s *Spool, send SendFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Forward(ctx, send, 3)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.Empty() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Spool was not drained, %d records left", s.Stats().Records)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestSpoolForwardsInOrder(t *testing.T) {
+	s, err := Open(Options{Dir: t.TempDir(), SegmentBytes: 64})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 10; i += 2 {
+		if err := s.Append(records(i, i+2)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	stats := s.Stats()
+	if stats.Records != 10 {
+		t.Errorf("Expected 10 spooled records, got %d", stats.Records)
+	}
+	if stats.Segments < 2 {
+		t.Errorf("Expected the spool to rotate segments, got %d", stats.Segments)
+	}
+
+	var mu sync.Mutex
+	var got []string
+	failures := 1
+	forwardAll(t, s, func(ctx context.Context, values [][]byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return errors.New("broker unavailable")
+		}
+		for _, value := range values {
+			got = append(got, string(value))
+		}
+		return nil
+	})
+
+	if len(got) != 10 {
+		t.Fatalf("Expected 10 forwarded records, got %d", len(got))
+	}
+	for i, value := range got {
+		expected := fmt.Sprintf(`{"n":%d}`, i)
+		if value != expected {
+			t.Errorf("Expected record %d to be %s, got %s", i, expected, value)
+		}
+	}
+
+	if stats := s.Stats(); stats.Bytes != 0 {
+		t.Errorf("Expected no pending bytes, got %d", stats.Bytes)
+	}
+}
+
+func TestSpoolRecoversAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Append(records(0, 5)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Forward the first two records only
+	values, next, err := s.peek(2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.commit(next, len(values)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s.Close()
+
+	// Simulate a crash in the middle of a write
+	segmentPath := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, segmentSuffix))
+	f, err := os.OpenFile(segmentPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	f.Write([]byte{0, 0, 0, 42, 1, 2})
+	f.Close()
+
+	s, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	if stats := s.Stats(); stats.Records != 3 {
+		t.Errorf("Expected 3 records after restart, got %d", stats.Records)
+	}
+
+	if err := s.Append(records(5, 6)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var got []string
+	forwardAll(t, s, func(ctx context.Context, values [][]byte) error {
+		for _, value := range values {
+			got = append(got, string(value))
+		}
+		return nil
+	})
+
+	expected := []string{`{"n":2}`, `{"n":3}`, `{"n":4}`, `{"n":5}`}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d forwarded records, got %d: %v", len(expected), len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected record %d to be %s, got %s", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	s, err := Open(Options{Dir: t.TempDir(), MaxBytes: 40})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Append(records(0, 2)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Append(records(2, 4)); !errors.Is(err, ErrFull) {
+		t.Errorf("Expected ErrFull, got %v", err)
+	}
+	if stats := s.Stats(); stats.Records != 2 {
+		t.Errorf("Expected 2 spooled records, got %d", stats.Records)
+	}
+}
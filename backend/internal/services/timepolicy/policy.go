@@ -0,0 +1,148 @@
+package timepolicy
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+// Action is what happens to readings that are late or out of order
+type Action string
+
+const (
+	// ActionAccept accepts out-of-order readings like readings in order
+	ActionAccept Action = "accept"
+	// ActionReject rejects late readings
+	ActionReject Action = "reject"
+	// ActionSkipRealtime accepts late readings but skips real-time anomaly checks
+	ActionSkipRealtime Action = "skip_realtime"
+	// ActionBackfill routes late readings to the backfill topic
+	ActionBackfill Action = "backfill"
+)
+
+// Policy bounds how far reading timestamps may lie in the future or the past
+type Policy struct {
+	// MaxFutureSkew is how far ahead of the server clock a timestamp may be
+	MaxFutureSkew time.Duration
+	// MaxLateness is the age after which a reading is late
+	MaxLateness time.Duration
+	// LateAction decides what happens to late readings
+	LateAction Action
+	// OutOfOrderAction decides what happens to readings older than the latest one of their sensor
+	OutOfOrderAction Action
+}
+
+// defaultPolicy is the policy used by Default
+var defaultPolicy atomic.Pointer[Policy]
+
+func init() {
+	defaultPolicy.Store(&Policy{
+		MaxFutureSkew:    5 * time.Minute,
+		MaxLateness:      24 * time.Hour,
+		LateAction:       ActionSkipRealtime,
+		OutOfOrderAction: ActionSkipRealtime,
+	})
+}
+
+// Default returns the process-wide timestamp policy
+func Default() *Policy {
+	return defaultPolicy.Load()
+}
+
+// SetDefault replaces the process-wide timestamp policy, typically once at startup
+func SetDefault(policy *Policy) {
+	defaultPolicy.Store(policy)
+}
+
+// ParseAction parses a late data action
+func ParseAction(value string) (Action, error) {
+	switch action := Action(value); action {
+	case ActionReject, ActionSkipRealtime, ActionBackfill:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown late data action %q: must be reject, skip_realtime or backfill", value)
+	}
+}
+
+// ParseOutOfOrderAction parses an out-of-order data action. Out-of-order readings cannot be rejected,
+// because they are only classified when they are published.
+func ParseOutOfOrderAction(value string) (Action, error) {
+	switch action := Action(value); action {
+	case ActionAccept, ActionSkipRealtime, ActionBackfill:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown out-of-order data action %q: must be accept, skip_realtime or backfill", value)
+	}
+}
+
+// Classify returns the lateness classification of a timestamp received at now. It returns an
+// error for the zero time, for timestamps beyond the future skew, and for late timestamps when
+// late readings are rejected.
+func (p *Policy) Classify(timestamp, now time.Time) (string, error) {
+	if timestamp.IsZero() {
+		return "", fmt.Errorf("Timestamp is required")
+	}
+
+	if p.MaxFutureSkew >= 0 && timestamp.After(now.Add(p.MaxFutureSkew)) {
+		return "", fmt.Errorf("Timestamp is more than %s in the future", p.MaxFutureSkew)
+	}
+
+	if p.MaxLateness > 0 && now.Sub(timestamp) > p.MaxLateness {
+		if p.LateAction == ActionReject {
+			return models.LatenessLate, fmt.Errorf("Timestamp is more than %s in the past", p.MaxLateness)
+		}
+		return models.LatenessLate, nil
+	}
+
+	return models.LatenessOnTime, nil
+}
+
+// OrderTracker tracks the latest timestamp of each sensor and parameter to find out-of-order readings.
+// Readings without a sensor ID are not tracked, so it holds one timestamp per sensor and parameter.
+type OrderTracker struct {
+	mu     sync.Mutex
+	latest map[string]time.Time
+}
+
+// NewOrderTracker creates an empty order tracker
+func NewOrderTracker() *OrderTracker {
+	return &OrderTracker{latest: make(map[string]time.Time)}
+}
+
+// OutOfOrder reports whether a reading is older than the latest one recorded of its sensor and
+// parameter. Repeating the latest reading is not out of order.
+func (t *OrderTracker) OutOfOrder(sensorID, parameter string, timestamp time.Time) bool {
+	if sensorID == "" {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	latest, ok := t.latest[orderKey(sensorID, parameter)]
+	return ok && timestamp.Before(latest)
+}
+
+// Advance records the timestamp of a reading as the latest of its sensor and parameter, unless a
+// newer one is already recorded
+func (t *OrderTracker) Advance(sensorID, parameter string, timestamp time.Time) {
+	if sensorID == "" {
+		return
+	}
+
+	key := orderKey(sensorID, parameter)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if latest, ok := t.latest[key]; !ok || latest.Before(timestamp) {
+		t.latest[key] = timestamp
+	}
+}
+
+// orderKey identifies the readings of a sensor and parameter
+func orderKey(sensorID, parameter string) string {
+	return sensorID + "\n" + parameter
+}
@@ -0,0 +1,98 @@
+package timepolicy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+func TestClassify(t *testing.T) {
+	now := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		action    Action
+		timestamp time.Time
+		expected  string
+		valid     bool
+	}{
+		{"On Time", ActionSkipRealtime, now.Add(-time.Minute), models.LatenessOnTime, true},
+		{"Within Future Skew", ActionSkipRealtime, now.Add(4 * time.Minute), models.LatenessOnTime, true},
+		{"Beyond Future Skew", ActionSkipRealtime, now.Add(6 * time.Minute), "", false},
+		{"Zero Time", ActionSkipRealtime, time.Time{}, "", false},
+		{"Late Accepted", ActionSkipRealtime, now.Add(-25 * time.Hour), models.LatenessLate, true},
+		{"Late Backfilled", ActionBackfill, now.Add(-25 * time.Hour), models.LatenessLate, true},
+		{"Late Rejected", ActionReject, now.Add(-25 * time.Hour), models.LatenessLate, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := &Policy{MaxFutureSkew: 5 * time.Minute, MaxLateness: 24 * time.Hour, LateAction: tc.action}
+
+			lateness, err := policy.Classify(tc.timestamp, now)
+			if tc.valid && err != nil {
+				t.Errorf("Expected valid timestamp but got error: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			if lateness != tc.expected {
+				t.Errorf("Expected lateness %q, got %q", tc.expected, lateness)
+			}
+		})
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for _, value := range []string{"reject", "skip_realtime", "backfill"} {
+		if _, err := ParseAction(value); err != nil {
+			t.Errorf("Unexpected error for %s: %v", value, err)
+		}
+	}
+	if _, err := ParseAction("drop"); err == nil {
+		t.Errorf("Expected error for unknown action")
+	}
+}
+
+func TestParseOutOfOrderAction(t *testing.T) {
+	for _, value := range []string{"accept", "skip_realtime", "backfill"} {
+		if _, err := ParseOutOfOrderAction(value); err != nil {
+			t.Errorf("Unexpected error for %s: %v", value, err)
+		}
+	}
+	if _, err := ParseOutOfOrderAction("reject"); err == nil {
+		t.Errorf("Expected error for reject")
+	}
+}
+
+func TestOrderTracker(t *testing.T) {
+	tracker := NewOrderTracker()
+	now := time.Date(2025, 5, 2, 13, 45, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		sensorID  string
+		parameter string
+		timestamp time.Time
+		expected  bool
+	}{
+		{"First Reading", "station-7", "PM2.5", now, false},
+		{"Newer Reading", "station-7", "PM2.5", now.Add(time.Minute), false},
+		{"Repeated Reading", "station-7", "PM2.5", now.Add(time.Minute), false},
+		{"Older Reading", "station-7", "PM2.5", now, true},
+		{"Other Parameter", "station-7", "NO2", now, false},
+		{"Other Sensor", "station-8", "PM2.5", now, false},
+		{"Anonymous", "", "PM2.5", now.Add(-time.Hour), false},
+		{"Older Than Latest Only", "station-7", "PM2.5", now.Add(30 * time.Second), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if outOfOrder := tracker.OutOfOrder(tc.sensorID, tc.parameter, tc.timestamp); outOfOrder != tc.expected {
+				t.Errorf("Expected out of order %v, got %v", tc.expected, outOfOrder)
+			}
+			tracker.Advance(tc.sensorID, tc.parameter, tc.timestamp)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package window
+
+import "container/heap"
+
+// medianTracker keeps the median of a multiset of values that supports removal. The lower half
+// of the values is kept in a max-heap and the upper half in a min-heap; removed values are only
+// dropped once they reach the top of their heap.
+type medianTracker struct {
+	lo, hi         valueHeap
+	loSize, hiSize int
+	removed        map[float64]int
+	pendingRemoval int
+}
+
+// newMedianTracker creates an empty median tracker
+func newMedianTracker() *medianTracker {
+	return &medianTracker{
+		lo:      valueHeap{max: true},
+		hi:      valueHeap{},
+		removed: make(map[float64]int),
+	}
+}
+
+// add inserts a value
+func (m *medianTracker) add(v float64) {
+	if m.loSize == 0 || v <= m.lo.top() {
+		heap.Push(&m.lo, v)
+		m.loSize++
+	} else {
+		heap.Push(&m.hi, v)
+		m.hiSize++
+	}
+	m.rebalance()
+}
+
+// remove removes a value that was added before
+func (m *medianTracker) remove(v float64) {
+	m.removed[v]++
+	m.pendingRemoval++
+	if m.loSize > 0 && v <= m.lo.top() {
+		m.loSize--
+		if v == m.lo.top() {
+			m.prune(&m.lo)
+		}
+	} else {
+		m.hiSize--
+		if v == m.hi.top() {
+			m.prune(&m.hi)
+		}
+	}
+	m.rebalance()
+
+	// Removed values deep in the heaps are only dropped when they surface, so rebuild the heaps
+	// once they make up most of them
+	if m.pendingRemoval > m.loSize+m.hiSize && m.pendingRemoval > 64 {
+		m.compact()
+	}
+}
+
+// median returns the median of the values, or false when there are none
+func (m *medianTracker) median() (float64, bool) {
+	switch {
+	case m.loSize == 0:
+		return 0, false
+	case m.loSize > m.hiSize:
+		return m.lo.top(), true
+	default:
+		return (m.lo.top() + m.hi.top()) / 2, true
+	}
+}
+
+// rebalance keeps the lower half at the same size as the upper half, or one value larger
+func (m *medianTracker) rebalance() {
+	if m.loSize > m.hiSize+1 {
+		heap.Push(&m.hi, heap.Pop(&m.lo))
+		m.loSize--
+		m.hiSize++
+		m.prune(&m.lo)
+	} else if m.loSize < m.hiSize {
+		heap.Push(&m.lo, heap.Pop(&m.hi))
+		m.hiSize--
+		m.loSize++
+		m.prune(&m.hi)
+	}
+}
+
+// prune drops removed values from the top of a heap
+func (m *medianTracker) prune(h *valueHeap) {
+	for h.Len() > 0 {
+		v := h.top()
+		if m.removed[v] == 0 {
+			return
+		}
+		m.dropRemoved(v)
+		heap.Pop(h)
+	}
+}
+
+// compact rebuilds both heaps without the removed values
+func (m *medianTracker) compact() {
+	for _, h := range []*valueHeap{&m.lo, &m.hi} {
+		kept := h.values[:0]
+		for _, v := range h.values {
+			if m.removed[v] > 0 {
+				m.dropRemoved(v)
+				continue
+			}
+			kept = append(kept, v)
+		}
+		h.values = kept
+		heap.Init(h)
+	}
+}
+
+// dropRemoved records that one removed instance of a value has left the heaps
+func (m *medianTracker) dropRemoved(v float64) {
+	m.pendingRemoval--
+	if m.removed[v]--; m.removed[v] == 0 {
+		delete(m.removed, v)
+	}
+}
+
+// valueHeap is a min-heap of values, or a max-heap when max is set
+type valueHeap struct {
+	values []float64
+	max    bool
+}
+
+func (h valueHeap) Len() int { return len(h.values) }
+
+func (h valueHeap) Less(i, j int) bool {
+	if h.max {
+		return h.values[i] > h.values[j]
+	}
+	return h.values[i] < h.values[j]
+}
+
+func (h valueHeap) Swap(i, j int) { h.values[i], h.values[j] = h.values[j], h.values[i] }
+
+func (h *valueHeap) Push(x any) { h.values = append(h.values, x.(float64)) }
+
+func (h *valueHeap) Pop() any {
+	v := h.values[len(h.values)-1]
+	h.values = h.values[:len(h.values)-1]
+	return v
+}
+
+// top returns the first value of the heap
+func (h valueHeap) top() float64 { return h.values[0] }
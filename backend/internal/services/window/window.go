@@ -0,0 +1,313 @@
+package window
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+const (
+	// DefaultDuration is how long readings stay in a window when none is configured
+	DefaultDuration = 24 * time.Hour
+	// DefaultBucketWidth is the granularity at which readings are evicted
+	DefaultBucketWidth = 5 * time.Minute
+	// DefaultCellSize is the size of a spatial cell in degrees, about 25km
+	DefaultCellSize = 0.25
+	// DefaultRadius is the half-width of the neighborhood of a reading in degrees
+	DefaultRadius = 0.25
+)
+
+// Config configures a Store. Zero values select the defaults.
+type Config struct {
+	// Duration is how long a reading stays in its window, measured from its timestamp
+	Duration time.Duration
+	// BucketWidth is the granularity of eviction; readings are evicted up to one bucket late
+	BucketWidth time.Duration
+	// CellSize is the size of a spatial cell in degrees of latitude and longitude. Smaller cells
+	// fit neighborhoods more closely, but each reading is kept in more windows.
+	CellSize float64
+	// Radius is the half-width of the neighborhood of a reading in degrees of latitude and longitude
+	Radius float64
+}
+
+// Stats summarizes the values of a window
+type Stats struct {
+	Count  int
+	Mean   float64
+	StdDev float64 // Population standard deviation
+	Median float64
+}
+
+// Summarize computes the stats of a list of values
+func Summarize(values []float64) Stats {
+	m := newMedianTracker()
+	var sum, sumSq float64
+	for _, v := range values {
+		sum += v
+		sumSq += v * v
+		m.add(v)
+	}
+	return summarize(len(values), sum, sumSq, m)
+}
+
+// Store keeps the recent readings of each parameter in sliding time windows, one per spatial
+// cell, so that anomaly detection does not have to query the history of every reading. The window
+// of a cell holds the readings of the cells within the radius around it, so that the window of a
+// reading's cell covers the neighborhood centred on the reading. Adding a reading costs O(log n) in
+// the size of the window for each of the (2r+1)² cells it is kept in, where r is the radius in
+// cells; reading the stats of a neighborhood costs O(log n). Store is safe for concurrent use.
+type Store struct {
+	mu        sync.Mutex
+	config    Config
+	reach     int64
+	windows   map[windowKey]*window
+	lastSweep int64
+	now       func() time.Time
+}
+
+// windowKey identifies the window of a parameter around a spatial cell
+type windowKey struct {
+	parameter string
+	lat, lon  int64
+}
+
+// window holds the values of a window in time buckets, with their running sums and median
+type window struct {
+	buckets map[int64]*bucket
+	// oldest is the first bucket that has not been evicted
+	oldest int64
+	count  int
+	// own is the number of values of readings in the window's own cell
+	own    int
+	sum    float64
+	sumSq  float64
+	median *medianTracker
+}
+
+// bucket holds the values of a window whose timestamps fall in one bucket width
+type bucket struct {
+	values []float64
+	sum    float64
+	sumSq  float64
+	own    int
+}
+
+// New creates an empty store
+func New(config Config) *Store {
+	if config.Duration <= 0 {
+		config.Duration = DefaultDuration
+	}
+	if config.BucketWidth <= 0 {
+		config.BucketWidth = DefaultBucketWidth
+	}
+	if config.CellSize <= 0 {
+		config.CellSize = DefaultCellSize
+	}
+	if config.Radius <= 0 {
+		config.Radius = DefaultRadius
+	}
+
+	return &Store{
+		config: config,
+		// The radius in cells, without rounding a whole number of cells up
+		reach:   int64(math.Ceil(config.Radius/config.CellSize - 1e-9)),
+		windows: make(map[windowKey]*window),
+		now:     time.Now,
+	}
+}
+
+// Cutoff returns the time before which readings are no longer kept
+func (s *Store) Cutoff() time.Time {
+	return s.now().Add(-s.config.Duration)
+}
+
+// Add adds a reading to the windows of its parameter around the cells within the radius of its
+// cell. It reports false for readings that are older than the window or have no finite value.
+func (s *Store) Add(data *models.AirQualityData) bool {
+	if math.IsNaN(data.Value) || math.IsInf(data.Value, 0) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.cutoffBucket()
+	b := s.bucketOf(data.Timestamp)
+	if b < cutoff {
+		return false
+	}
+	s.sweep(cutoff)
+
+	home := s.keyOf(data.Parameter, data.Latitude, data.Longitude)
+	for lat := home.lat - s.reach; lat <= home.lat+s.reach; lat++ {
+		for lon := home.lon - s.reach; lon <= home.lon+s.reach; lon++ {
+			key := windowKey{parameter: data.Parameter, lat: lat, lon: lon}
+			w, ok := s.windows[key]
+			if !ok {
+				w = &window{buckets: make(map[int64]*bucket), oldest: cutoff, median: newMedianTracker()}
+				s.windows[key] = w
+			}
+			w.evict(cutoff)
+			w.add(b, data.Value, key == home)
+		}
+	}
+	return true
+}
+
+// Stats returns the stats of the readings of a parameter in the neighborhood of a location: the
+// readings within the radius of it, and possibly some up to one cell size further
+func (s *Store) Stats(parameter string, latitude, longitude float64) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.windows[s.keyOf(parameter, latitude, longitude)]
+	if !ok {
+		return Stats{}
+	}
+	w.evict(s.cutoffBucket())
+	return summarize(w.count, w.sum, w.sumSq, w.median)
+}
+
+// Len returns the number of readings in all windows, counting each reading once
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, w := range s.windows {
+		n += w.own
+	}
+	return n
+}
+
+// sweep evicts old readings from all windows once per bucket width and drops empty windows,
+// so that cells that stopped reporting do not hold memory
+func (s *Store) sweep(cutoff int64) {
+	if cutoff <= s.lastSweep {
+		return
+	}
+	s.lastSweep = cutoff
+
+	for key, w := range s.windows {
+		w.evict(cutoff)
+		if w.count == 0 {
+			delete(s.windows, key)
+		}
+	}
+}
+
+// cutoffBucket returns the first bucket that is still inside the window
+func (s *Store) cutoffBucket() int64 {
+	return s.bucketOf(s.Cutoff())
+}
+
+// bucketOf returns the bucket of a timestamp
+func (s *Store) bucketOf(t time.Time) int64 {
+	width := int64(s.config.BucketWidth)
+	n := t.UnixNano()
+	b := n / width
+	if n%width < 0 {
+		b-- // Round down for timestamps before 1970
+	}
+	return b
+}
+
+// keyOf returns the key of the window of a parameter around the cell of a location
+func (s *Store) keyOf(parameter string, latitude, longitude float64) windowKey {
+	return windowKey{
+		parameter: parameter,
+		lat:       int64(math.Floor(latitude / s.config.CellSize)),
+		lon:       int64(math.Floor(longitude / s.config.CellSize)),
+	}
+}
+
+// add adds a value to the bucket b of the window; own marks a reading in the window's own cell
+func (w *window) add(b int64, value float64, own bool) {
+	bk, ok := w.buckets[b]
+	if !ok {
+		bk = &bucket{}
+		w.buckets[b] = bk
+	}
+	bk.values = append(bk.values, value)
+	bk.sum += value
+	bk.sumSq += value * value
+	if own {
+		bk.own++
+		w.own++
+	}
+
+	w.count++
+	w.sum += value
+	w.sumSq += value * value
+	w.median.add(value)
+}
+
+// evict removes the buckets before cutoff
+func (w *window) evict(cutoff int64) {
+	if cutoff <= w.oldest {
+		return
+	}
+
+	evicted := false
+	if cutoff-w.oldest <= int64(len(w.buckets)) {
+		for b := w.oldest; b < cutoff; b++ {
+			evicted = w.evictBucket(b) || evicted
+		}
+	} else {
+		// After a long pause it is cheaper to look at the buckets than at every bucket width
+		for b := range w.buckets {
+			if b < cutoff {
+				evicted = w.evictBucket(b) || evicted
+			}
+		}
+	}
+	w.oldest = cutoff
+
+	// Recompute the running sums from the buckets so that rounding errors do not accumulate
+	if evicted {
+		w.sum, w.sumSq = 0, 0
+		for _, bk := range w.buckets {
+			w.sum += bk.sum
+			w.sumSq += bk.sumSq
+		}
+	}
+}
+
+// evictBucket removes a bucket and its values, reporting whether it existed
+func (w *window) evictBucket(b int64) bool {
+	bk, ok := w.buckets[b]
+	if !ok {
+		return false
+	}
+	for _, v := range bk.values {
+		w.median.remove(v)
+	}
+	w.count -= len(bk.values)
+	w.own -= bk.own
+	delete(w.buckets, b)
+	return true
+}
+
+// summarize computes stats from the running sums and median of a window
+func summarize(count int, sum, sumSq float64, m *medianTracker) Stats {
+	if count == 0 {
+		return Stats{}
+	}
+
+	n := float64(count)
+	mean := sum / n
+	variance := sumSq/n - mean*mean
+	if variance < 0 {
+		variance = 0 // Rounding error of a constant window
+	}
+	median, _ := m.median()
+
+	return Stats{
+		Count:  count,
+		Mean:   mean,
+		StdDev: math.Sqrt(variance),
+		Median: median,
+	}
+}
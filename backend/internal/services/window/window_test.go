@@ -0,0 +1,140 @@
+package window
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/user/airpollution/internal/models"
+)
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		expected Stats
+	}{
+		{"Empty", nil, Stats{}},
+		{"Single Value", []float64{4}, Stats{Count: 1, Mean: 4, Median: 4}},
+		{"Odd Count", []float64{3, 1, 2}, Stats{Count: 3, Mean: 2, StdDev: math.Sqrt(2.0 / 3.0), Median: 2}},
+		{"Even Count", []float64{4, 1, 3, 2}, Stats{Count: 4, Mean: 2.5, StdDev: math.Sqrt(1.25), Median: 2.5}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := Summarize(tc.values)
+			if stats.Count != tc.expected.Count || !near(stats.Mean, tc.expected.Mean) ||
+				!near(stats.StdDev, tc.expected.StdDev) || !near(stats.Median, tc.expected.Median) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, stats)
+			}
+		})
+	}
+}
+
+func TestMedianTrackerMatchesSortedValues(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	m := newMedianTracker()
+	var values []float64
+
+	for i := 0; i < 5000; i++ {
+		if len(values) > 0 && rng.Intn(3) == 0 {
+			j := rng.Intn(len(values))
+			m.remove(values[j])
+			values = append(values[:j], values[j+1:]...)
+		} else {
+			v := float64(rng.Intn(20)) // Small range to exercise duplicates
+			m.add(v)
+			values = append(values, v)
+		}
+
+		median, ok := m.median()
+		if ok != (len(values) > 0) {
+			t.Fatalf("Expected a median for %d values, got %v", len(values), ok)
+		}
+		if ok && median != sortedMedian(values) {
+			t.Fatalf("Expected median %v after step %d, got %v", sortedMedian(values), i, median)
+		}
+	}
+}
+
+func TestStoreWindows(t *testing.T) {
+	now := time.Date(2025, 5, 2, 12, 0, 0, 0, time.UTC)
+	store := New(Config{Duration: time.Hour, BucketWidth: time.Minute})
+	store.now = func() time.Time { return now }
+
+	reading := func(parameter string, lat, lon, value float64, age time.Duration) *models.AirQualityData {
+		return &models.AirQualityData{Parameter: parameter, Latitude: lat, Longitude: lon, Value: value, Timestamp: now.Add(-age)}
+	}
+
+	if !store.Add(reading("PM2.5", 41.015, 28.979, 10, 50*time.Minute)) ||
+		!store.Add(reading("PM2.5", 41.2, 28.6, 20, 10*time.Minute)) ||
+		!store.Add(reading("PM2.5", 41.3, 28.9, 30, 0)) {
+		t.Fatalf("Expected readings inside the window to be added")
+	}
+	store.Add(reading("PM10", 41.015, 28.979, 100, 0))
+	store.Add(reading("PM2.5", 40.4, 28.979, 100, 0))
+	if store.Add(reading("PM2.5", 41.015, 28.979, 100, 2*time.Hour)) {
+		t.Errorf("Expected a reading older than the window to be rejected")
+	}
+	if store.Add(reading("PM2.5", 41.015, 28.979, math.NaN(), 0)) {
+		t.Errorf("Expected a NaN reading to be rejected")
+	}
+
+	// Readings of the same parameter in the neighborhood of a location share its window
+	if stats := store.Stats("PM2.5", 41.1, 28.8); stats.Count != 3 || stats.Mean != 20 || stats.Median != 20 {
+		t.Errorf("Expected 3 readings with mean 20, got %+v", stats)
+	}
+
+	// The oldest reading leaves the window as time passes
+	now = now.Add(15 * time.Minute)
+	if stats := store.Stats("PM2.5", 41.1, 28.8); stats.Count != 2 || stats.Mean != 25 {
+		t.Errorf("Expected 2 readings with mean 25, got %+v", stats)
+	}
+
+	// Windows of cells that stopped reporting are dropped
+	now = now.Add(2 * time.Hour)
+	store.Add(reading("NO2", 41.015, 28.979, 5, 0))
+	if store.Len() != 1 || len(store.windows) != 9 {
+		t.Errorf("Expected only the new reading to remain, got %d readings in %d windows", store.Len(), len(store.windows))
+	}
+}
+
+func TestStoreNeighborhoodIsCentredOnTheReading(t *testing.T) {
+	now := time.Date(2025, 5, 2, 12, 0, 0, 0, time.UTC)
+	store := New(Config{Duration: time.Hour, CellSize: 0.05, Radius: 0.25})
+	store.now = func() time.Time { return now }
+
+	for _, lat := range []float64{40.76, 41.0, 41.24, 41.31, 40.69} {
+		store.Add(&models.AirQualityData{Parameter: "PM2.5", Latitude: lat, Longitude: 29.0, Value: lat, Timestamp: now})
+	}
+
+	// Readings on both sides of a cell boundary are neighbors, and readings more than a cell beyond
+	// the radius are not
+	if stats := store.Stats("PM2.5", 41.0, 29.0); stats.Count != 3 {
+		t.Errorf("Expected 3 readings around 41.0, got %+v", stats)
+	}
+	if stats := store.Stats("PM2.5", 41.24, 29.0); stats.Count != 3 || stats.Median != 41.24 {
+		t.Errorf("Expected 3 readings around 41.24, got %+v", stats)
+	}
+	if store.Len() != 5 {
+		t.Errorf("Expected 5 readings, got %d", store.Len())
+	}
+}
+
+// near reports whether two floats are equal up to rounding
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// sortedMedian computes the median of values by sorting them
+func sortedMedian(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
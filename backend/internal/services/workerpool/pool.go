@@ -0,0 +1,73 @@
+package workerpool
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	// DefaultWorkers is the number of workers when none is configured
+	DefaultWorkers = 8
+	// DefaultQueueSize is the number of jobs each worker buffers when none is configured
+	DefaultQueueSize = 100
+)
+
+// Pool runs jobs on a fixed number of workers. Jobs with the same key always run on the same
+// worker, so they run one at a time in the order they were submitted, while jobs with different
+// keys run in parallel.
+type Pool struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+// New starts a pool with the given number of workers, each buffering up to queueSize jobs
+func New(workers, queueSize int) *Pool {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if queueSize < 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	p := &Pool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		queue := make(chan func(), queueSize)
+		p.queues[i] = queue
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range queue {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// Submit queues a job on the worker of its key. It blocks while the queue of that worker is
+// full, which holds back the caller when workers fall behind, and fails when ctx is done first.
+func (p *Pool) Submit(ctx context.Context, key string, job func()) error {
+	select {
+	case p.queues[p.worker(key)] <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting jobs and waits until all queued jobs have run. Submit must not be
+// called after Close.
+func (p *Pool) Close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// worker returns the index of the worker that runs jobs of a key
+func (p *Pool) worker(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
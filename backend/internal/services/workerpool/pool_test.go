@@ -0,0 +1,87 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolOrdersJobsPerKey(t *testing.T) {
+	pool := New(4, 10)
+
+	var mu sync.Mutex
+	order := make(map[string][]int)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("sensor-%d", i%5)
+		i := i
+		if err := pool.Submit(context.Background(), key, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			order[key] = append(order[key], i)
+		}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	pool.Close()
+
+	for key, jobs := range order {
+		if len(jobs) != 20 {
+			t.Errorf("Expected 20 jobs for %s, got %d", key, len(jobs))
+		}
+		for j := 1; j < len(jobs); j++ {
+			if jobs[j] < jobs[j-1] {
+				t.Errorf("Jobs of %s ran out of order: %v", key, jobs)
+				break
+			}
+		}
+	}
+}
+
+func TestPoolRunsKeysInParallel(t *testing.T) {
+	pool := New(8, 0)
+	defer pool.Close()
+
+	var running, peak int32
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go pool.Submit(context.Background(), fmt.Sprintf("sensor-%d", i), func() {
+			defer wg.Done()
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt32(&running, -1)
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if peak < 2 {
+		t.Errorf("Expected jobs of different keys to run in parallel, peak was %d", peak)
+	}
+}
+
+func TestPoolSubmitBlocksWhenFull(t *testing.T) {
+	pool := New(1, 0)
+	defer pool.Close()
+
+	release := make(chan struct{})
+	pool.Submit(context.Background(), "a", func() { <-release })
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(ctx, "a", func() {}); err != context.DeadlineExceeded {
+		t.Errorf("Expected the full queue to block until the deadline, got %v", err)
+	}
+}
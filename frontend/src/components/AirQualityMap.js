@@ -2,12 +2,12 @@ import React, { useEffect, useState, useRef } from 'react';
 import mapboxgl from 'mapbox-gl';
 import 'mapbox-gl/dist/mapbox-gl.css';
 import { notifierService } from '../services/api';
-import { getColorForValue } from '../utils/airQualityUtils';
+import { findIndex, getIndexColor } from '../utils/airQualityUtils';
 
 // Set your Mapbox token here
 mapboxgl.accessToken = process.env.REACT_APP_MAPBOX_TOKEN || '';
 
-const AirQualityMap = ({ onRegionSelect }) => {
+const AirQualityMap = ({ onRegionSelect, indices }) => {
   const mapContainer = useRef(null);
   const map = useRef(null);
   const [airQualityData, setAirQualityData] = useState([]);
@@ -15,6 +15,10 @@ const AirQualityMap = ({ onRegionSelect }) => {
   const [error, setError] = useState(null);
   const [noToken, setNoToken] = useState(!mapboxgl.accessToken);
 
+  // Keep the latest indices for the map callbacks, which are registered once
+  const indicesRef = useRef(indices);
+  indicesRef.current = indices;
+
   // Initialize map when component mounts
   useEffect(() => {
     if (map.current) return; // initialize map only once
@@ -67,6 +71,25 @@ const AirQualityMap = ({ onRegionSelect }) => {
     }
   };
 
+  // Prepare GeoJSON data for the map, colored by the air quality index of each location
+  const toGeoJson = (data) => ({
+    type: 'FeatureCollection',
+    features: data.map(item => ({
+      type: 'Feature',
+      properties: {
+        id: item.id,
+        parameter: item.parameter,
+        value: item.value,
+        color: getIndexColor(findIndex(indicesRef.current, item)),
+        detected_at: item.detected_at
+      },
+      geometry: {
+        type: 'Point',
+        coordinates: [item.longitude, item.latitude]
+      }
+    }))
+  });
+
   // Update map with new data
   const updateMapData = (data) => {
     if (!map.current || !map.current.loaded()) return;
@@ -78,29 +101,10 @@ const AirQualityMap = ({ onRegionSelect }) => {
       map.current.removeSource('air-quality-data');
     }
     
-    // Prepare GeoJSON data for the map
-    const geoJsonData = {
-      type: 'FeatureCollection',
-      features: data.map(item => ({
-        type: 'Feature',
-        properties: {
-          id: item.id,
-          parameter: item.parameter,
-          value: item.value,
-          color: getColorForValue(item.parameter.replace('.', ''), item.value),
-          detected_at: item.detected_at
-        },
-        geometry: {
-          type: 'Point',
-          coordinates: [item.longitude, item.latitude]
-        }
-      }))
-    };
-    
     // Add a new source with the data
     map.current.addSource('air-quality-data', {
       type: 'geojson',
-      data: geoJsonData
+      data: toGeoJson(data)
     });
     
     // Add a heatmap layer
@@ -170,6 +174,14 @@ const AirQualityMap = ({ onRegionSelect }) => {
     });
   };
 
+  // Recolor the points when the indices are refreshed
+  useEffect(() => {
+    const source = map.current && map.current.getSource('air-quality-data');
+    if (source) {
+      source.setData(toGeoJson(airQualityData));
+    }
+  }, [indices]);
+
   // Refresh data periodically
   useEffect(() => {
     if (noToken) {
@@ -217,7 +229,7 @@ const AirQualityMap = ({ onRegionSelect }) => {
                 {airQualityData.map(item => (
                   <tr key={item.id}>
                     <td>{item.parameter}</td>
-                    <td style={{ color: getColorForValue(item.parameter.replace('.', ''), item.value) }}>
+                    <td style={{ color: getIndexColor(findIndex(indices, item)) }}>
                       {item.value.toFixed(1)}
                     </td>
                     <td>
@@ -1,7 +1,7 @@
 import React, { useEffect, useState } from 'react';
-import { timeAgo, getColorForValue, getQualityDescription } from '../utils/airQualityUtils';
+import { timeAgo, findIndex, getIndexColor, getIndexCategory, getTextColor } from '../utils/airQualityUtils';
 
-const AnomalyAlertPanel = ({ alerts }) => {
+const AnomalyAlertPanel = ({ alerts, indices }) => {
   const [filteredAlerts, setFilteredAlerts] = useState([]);
 
   // Filter alerts to only keep those less than 1 hour old
@@ -21,6 +21,7 @@ const AnomalyAlertPanel = ({ alerts }) => {
   const getSeverityClass = (type) => {
     switch (type) {
       case 'ThresholdExceeded':
+      case 'AlertLevelExceeded':
       case 'SpikeDetected':
         return 'danger';
       case 'StatisticalOutlier':
@@ -36,6 +37,8 @@ const AnomalyAlertPanel = ({ alerts }) => {
     switch (type) {
       case 'ThresholdExceeded':
         return 'Exceeded WHO guidelines';
+      case 'AlertLevelExceeded':
+        return 'Reading above alert level';
       case 'StatisticalOutlier':
         return 'Statistical anomaly detected';
       case 'SpikeDetected':
@@ -60,21 +63,22 @@ const AnomalyAlertPanel = ({ alerts }) => {
     <div>
       {filteredAlerts.map(alert => {
         const alertTime = new Date(alert.createdAt || alert.timestamp || alert.detected_at);
-        const parameterKey = alert.parameter?.replace('.', '') || 'PM25';
+        const index = findIndex(indices, alert);
+        const color = getIndexColor(index);
         
         return (
           <div 
             key={alert.id} 
             className={`alert-item ${getSeverityClass(alert.type)}`}
-            style={{ borderLeftColor: getColorForValue(parameterKey, alert.value) }}
+            style={{ borderLeftColor: color }}
           >
             <div className="alert-header">
               <strong>{alert.parameter || 'Unknown'}: {alert.value?.toFixed(1)}</strong>
               <span className="badge" style={{ 
-                backgroundColor: getColorForValue(parameterKey, alert.value),
-                color: alert.value > 100 ? 'white' : 'black'
+                backgroundColor: color,
+                color: getTextColor(color)
               }}>
-                {getQualityDescription(parameterKey, alert.value)}
+                {getIndexCategory(index)}
               </span>
             </div>
             
@@ -11,7 +11,7 @@ import {
   Legend
 } from 'chart.js';
 import { notifierService } from '../services/api';
-import { getColorForValue } from '../utils/airQualityUtils';
+import { findIndex, getIndexColor } from '../utils/airQualityUtils';
 
 // Register required Chart.js components
 ChartJS.register(
@@ -24,7 +24,7 @@ ChartJS.register(
   Legend
 );
 
-const HistoricalChart = ({ region }) => {
+const HistoricalChart = ({ region, indices }) => {
   const [chartData, setChartData] = useState(null);
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState(null);
@@ -81,7 +81,6 @@ const HistoricalChart = ({ region }) => {
     });
     
     const values = sortedData.map(item => item.value);
-    const parameterKey = region.parameter?.replace('.', '') || 'PM25';
     
     const chartData = {
       labels,
@@ -89,7 +88,6 @@ const HistoricalChart = ({ region }) => {
         {
           label: region.parameter,
           data: values,
-          borderColor: getColorForValue(parameterKey, Math.max(...values)),
           backgroundColor: 'rgba(255, 255, 255, 0.2)',
           fill: false,
           tension: 0.4,
@@ -119,15 +117,12 @@ const HistoricalChart = ({ region }) => {
       values.push(Math.max(0, baseValue + randomVariation));
     }
     
-    const parameterKey = region.parameter?.replace('.', '') || 'PM25';
-    
     const chartData = {
       labels,
       datasets: [
         {
           label: region.parameter,
           data: values,
-          borderColor: getColorForValue(parameterKey, Math.max(...values)),
           backgroundColor: 'rgba(255, 255, 255, 0.2)',
           fill: false,
           tension: 0.4,
@@ -176,7 +171,14 @@ const HistoricalChart = ({ region }) => {
       {chartData && (
         <div className="chart-container">
           <Line 
-            data={chartData} 
+            data={{
+              ...chartData,
+              // Color the line like the current air quality index of the location
+              datasets: chartData.datasets.map(dataset => ({
+                ...dataset,
+                borderColor: getIndexColor(findIndex(indices, region)),
+              })),
+            }} 
             options={{
               responsive: true,
               plugins: {
@@ -1,7 +1,7 @@
 import React from 'react';
-import { formatTimestamp, getQualityDescription, getColorForValue } from '../utils/airQualityUtils';
+import { formatTimestamp, findIndex, getIndexColor, getIndexCategory, getTextColor, HEALTH_RECOMMENDATIONS } from '../utils/airQualityUtils';
 
-const RegionDetail = ({ region }) => {
+const RegionDetail = ({ region, indices }) => {
   if (!region) {
     return (
       <div className="empty-state">
@@ -10,9 +10,11 @@ const RegionDetail = ({ region }) => {
     );
   }
 
-  const parameterKey = region.parameter?.replace('.', '') || 'PM25';
-  const color = getColorForValue(parameterKey, region.value);
-  const quality = getQualityDescription(parameterKey, region.value);
+  // The category and color come from the current air quality index of the location
+  const index = findIndex(indices, region);
+  const color = getIndexColor(index);
+  const category = getIndexCategory(index);
+  const recommendation = index && HEALTH_RECOMMENDATIONS[index.category];
   
   return (
     <div className="region-detail">
@@ -29,9 +31,14 @@ const RegionDetail = ({ region }) => {
         <div className="measurement-value" style={{ color }}>
           <strong>{region.parameter}: {region.value?.toFixed(2)}</strong>
         </div>
-        <div className="quality-indicator" style={{ backgroundColor: color, color: region.value > 100 ? 'white' : 'black' }}>
-          {quality}
+        <div className="quality-indicator" style={{ backgroundColor: color, color: getTextColor(color) }}>
+          {index ? `AQI ${index.value}: ${category}` : category}
         </div>
+        {index && (
+          <p>
+            <small>{index.scale} index, dominant pollutant {index.dominant_pollutant}, observed {formatTimestamp(index.observed_at)}</small>
+          </p>
+        )}
         <p>
           Detected at: {formatTimestamp(region.detected_at)}
         </p>
@@ -50,20 +57,10 @@ const RegionDetail = ({ region }) => {
       
       <div className="recommendations">
         <h4>Health Recommendations</h4>
-        {quality === 'Good' && (
-          <p>Air quality is considered satisfactory, and air pollution poses little or no risk.</p>
-        )}
-        {quality === 'Moderate' && (
-          <p>Air quality is acceptable; however, for some pollutants there may be a moderate health concern for a very small number of people.</p>
-        )}
-        {quality === 'Unhealthy' && (
-          <p>Members of sensitive groups may experience health effects. The general public is not likely to be affected.</p>
-        )}
-        {quality === 'Very Unhealthy' && (
-          <p>Health warnings of emergency conditions. The entire population is more likely to be affected.</p>
-        )}
-        {quality === 'Hazardous' && (
-          <p>Health alert: everyone may experience more serious health effects.</p>
+        {recommendation ? (
+          <p>{recommendation}</p>
+        ) : (
+          <p>{index ? `No recommendations for the ${index.scale} scale.` : 'No recommendations until the location has an air quality index.'}</p>
         )}
       </div>
     </div>
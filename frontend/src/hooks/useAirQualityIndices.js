@@ -0,0 +1,33 @@
+import { useState, useEffect } from 'react';
+import { notifierService } from '../services/api';
+import { indicesByLocation } from '../utils/airQualityUtils';
+
+// Load the current air quality indices on a scale, mapped by location, and refresh them every minute
+export const useAirQualityIndices = (scale) => {
+  const [indices, setIndices] = useState({});
+
+  useEffect(() => {
+    let cancelled = false;
+
+    const fetchIndices = async () => {
+      try {
+        const data = await notifierService.getAirQualityIndices(scale);
+        if (!cancelled) {
+          setIndices(indicesByLocation(data));
+        }
+      } catch (err) {
+        console.error('Error fetching air quality indices:', err);
+      }
+    };
+
+    fetchIndices();
+    const interval = setInterval(fetchIndices, 60000); // Refresh every minute
+
+    return () => {
+      cancelled = true;
+      clearInterval(interval);
+    };
+  }, [scale]);
+
+  return indices;
+};
@@ -4,10 +4,15 @@ import AnomalyAlertPanel from '../components/AnomalyAlertPanel';
 import RegionDetail from '../components/RegionDetail';
 import HistoricalChart from '../components/HistoricalChart';
 import { useWebSocket } from '../hooks/useWebSocket';
+import { useAirQualityIndices } from '../hooks/useAirQualityIndices';
+import { AQI_SCALE } from '../utils/airQualityUtils';
 
 const Home = () => {
   const [selectedRegion, setSelectedRegion] = useState(null);
   const [alerts, setAlerts] = useState([]);
+
+  // Current air quality indices, whose categories and colors are shown for each location
+  const indices = useAirQualityIndices(AQI_SCALE);
   
   // Connect to WebSocket for real-time anomaly alerts
   const { lastMessage } = useWebSocket(
@@ -37,7 +42,7 @@ const Home = () => {
               <h5>Real-time Air Quality Map</h5>
             </div>
             <div className="card-body">
-              <AirQualityMap onRegionSelect={handleMapClick} />
+              <AirQualityMap onRegionSelect={handleMapClick} indices={indices} />
             </div>
           </div>
         </div>
@@ -48,7 +53,7 @@ const Home = () => {
               <h5>Anomaly Alerts</h5>
             </div>
             <div className="card-body alert-panel">
-              <AnomalyAlertPanel alerts={alerts} />
+              <AnomalyAlertPanel alerts={alerts} indices={indices} />
             </div>
           </div>
         </div>
@@ -62,7 +67,7 @@ const Home = () => {
                 <h5>Region Details</h5>
               </div>
               <div className="card-body">
-                <RegionDetail region={selectedRegion} />
+                <RegionDetail region={selectedRegion} indices={indices} />
               </div>
             </div>
           </div>
@@ -73,7 +78,7 @@ const Home = () => {
                 <h5>Historical Data</h5>
               </div>
               <div className="card-body">
-                <HistoricalChart region={selectedRegion} />
+                <HistoricalChart region={selectedRegion} indices={indices} />
               </div>
             </div>
           </div>
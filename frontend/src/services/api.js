@@ -64,7 +64,20 @@ export const notifierService = {
       return handleApiError(error);
     }
   },
-  
+
+  // Get the current air quality indices, of all locations or of one, on all scales or on one
+  getAirQualityIndices: async (scale, location) => {
+    try {
+      const path = location ? `/api/aqi/${encodeURIComponent(location)}` : '/api/aqi';
+      const response = await notifierApi.get(path, {
+        params: scale ? { scale } : {},
+      });
+      return response.data;
+    } catch (error) {
+      return handleApiError(error);
+    }
+  },
+
   // Check health of notifier service
   healthCheck: async () => {
     try {
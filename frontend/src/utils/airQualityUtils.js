@@ -1,76 +1,51 @@
-// Constants for air quality levels based on WHO guidelines
-export const AQ_LEVELS = {
-  PM25: {
-    GOOD: 10,        // WHO guideline
-    MODERATE: 25,    // 2.5x guideline
-    UNHEALTHY: 55,   // 5.5x guideline
-    VERY_UNHEALTHY: 150, // 15x guideline
-    HAZARDOUS: 250,  // 25x guideline
-  },
-  PM10: {
-    GOOD: 20,        // WHO guideline
-    MODERATE: 50,    // 2.5x guideline
-    UNHEALTHY: 100,  // 5x guideline
-    VERY_UNHEALTHY: 200, // 10x guideline
-    HAZARDOUS: 400,  // 20x guideline
-  },
-  O3: {
-    GOOD: 100,       // WHO guideline (8-hour mean)
-    MODERATE: 160,   // 1.6x guideline
-    UNHEALTHY: 200,  // 2x guideline
-    VERY_UNHEALTHY: 300, // 3x guideline
-    HAZARDOUS: 400,  // 4x guideline
-  },
-  NO2: {
-    GOOD: 40,        // WHO guideline (annual mean)
-    MODERATE: 100,   // 2.5x guideline
-    UNHEALTHY: 200,  // 5x guideline
-    VERY_UNHEALTHY: 400, // 10x guideline
-    HAZARDOUS: 600,  // 15x guideline
-  },
-  SO2: {
-    GOOD: 40,        // WHO guideline (24-hour mean)
-    MODERATE: 100,   // 2.5x guideline
-    UNHEALTHY: 200,  // 5x guideline
-    VERY_UNHEALTHY: 400, // 10x guideline
-    HAZARDOUS: 600,  // 15x guideline
-  },
+// Scale of the air quality indices shown on the dashboard: us-epa, eu-caqi, uk-daqi or in-naqi
+export const AQI_SCALE = process.env.REACT_APP_AQI_SCALE || 'us-epa';
+
+// Color of locations that have no index yet
+export const NO_INDEX_COLOR = '#9E9E9E';
+
+// Health recommendations for the categories of the US EPA AQI
+export const HEALTH_RECOMMENDATIONS = {
+  'Good': 'Air quality is considered satisfactory, and air pollution poses little or no risk.',
+  'Moderate': 'Air quality is acceptable; however, for some pollutants there may be a moderate health concern for a very small number of people.',
+  'Unhealthy for Sensitive Groups': 'Members of sensitive groups may experience health effects. The general public is not likely to be affected.',
+  'Unhealthy': 'Everyone may begin to experience health effects; members of sensitive groups may experience more serious health effects.',
+  'Very Unhealthy': 'Health warnings of emergency conditions. The entire population is more likely to be affected.',
+  'Hazardous': 'Health alert: everyone may experience more serious health effects.',
 };
 
-// Get color based on pollution level
-export const getColorForValue = (parameter, value) => {
-  // Default to PM2.5 if parameter not found
-  const levels = AQ_LEVELS[parameter] || AQ_LEVELS.PM25;
-  
-  if (value <= levels.GOOD) {
-    return '#00E400'; // Green
-  } else if (value <= levels.MODERATE) {
-    return '#FFFF00'; // Yellow
-  } else if (value <= levels.UNHEALTHY) {
-    return '#FF7E00'; // Orange
-  } else if (value <= levels.VERY_UNHEALTHY) {
-    return '#FF0000'; // Red
-  } else {
-    return '#7F0023'; // Dark Red
+// Key of a location by its coordinates, rounded to four decimals like the locations of the AQI API
+export const locationKey = (latitude, longitude) => (
+  `${Number(latitude).toFixed(4)},${Number(longitude).toFixed(4)}`
+);
+
+// Map the air quality indices returned by the API by the coordinates of their location
+export const indicesByLocation = (indices = []) => (
+  indices.reduce((byLocation, index) => {
+    byLocation[locationKey(index.latitude, index.longitude)] = index;
+    return byLocation;
+  }, {})
+);
+
+// Find the air quality index of the location of an item with coordinates, such as an anomaly
+export const findIndex = (indices, item) => {
+  if (!indices || item?.latitude == null || item?.longitude == null) {
+    return null;
   }
+  return indices[locationKey(item.latitude, item.longitude)] || null;
 };
 
-// Get quality description based on pollution level
-export const getQualityDescription = (parameter, value) => {
-  // Default to PM2.5 if parameter not found
-  const levels = AQ_LEVELS[parameter] || AQ_LEVELS.PM25;
-  
-  if (value <= levels.GOOD) {
-    return 'Good';
-  } else if (value <= levels.MODERATE) {
-    return 'Moderate';
-  } else if (value <= levels.UNHEALTHY) {
-    return 'Unhealthy';
-  } else if (value <= levels.VERY_UNHEALTHY) {
-    return 'Very Unhealthy';
-  } else {
-    return 'Hazardous';
-  }
+// Get the color of an air quality index, as defined by its scale
+export const getIndexColor = (index) => index?.color || NO_INDEX_COLOR;
+
+// Get the category of an air quality index, as defined by its scale
+export const getIndexCategory = (index) => index?.category || 'No index yet';
+
+// Get black or white text, whichever is more readable on a #RRGGBB background
+export const getTextColor = (background) => {
+  const rgb = parseInt((background || NO_INDEX_COLOR).slice(1), 16);
+  const luminance = 0.299 * (rgb >> 16) + 0.587 * ((rgb >> 8) & 0xff) + 0.114 * (rgb & 0xff);
+  return luminance > 150 ? 'black' : 'white';
 };
 
 // Format timestamp to readable date/time
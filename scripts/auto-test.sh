@@ -6,6 +6,7 @@
 
 # Default values
 API_HOST="${API_HOST:-http://localhost:8080}"
+API_KEY="${API_KEY:-}" # Sensor API key, required when SENSOR_AUTH_REQUIRED is true
 DURATION=60  # seconds
 RATE=10      # requests per minute
 ANOMALY_CHANCE=20  # percent chance of generating an anomaly
@@ -168,6 +169,7 @@ EOF
     
     curl -s -X POST \
       -H "Content-Type: application/json" \
+      -H "X-API-Key: $API_KEY" \
       -d "$JSON_PAYLOAD" \
       $API_HOST/api/data > /dev/null
     
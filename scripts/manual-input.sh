@@ -6,6 +6,7 @@
 
 # Default values
 API_HOST="${API_HOST:-http://localhost:8080}"
+API_KEY="${API_KEY:-}" # Sensor API key, required when SENSOR_AUTH_REQUIRED is true
 CURRENT_TIME=$(date -u +"%Y-%m-%dT%H:%M:%SZ")
 
 # Check if required parameters are provided
@@ -38,6 +39,7 @@ EOF
 echo "Sending data: $JSON_PAYLOAD"
 curl -X POST \
   -H "Content-Type: application/json" \
+  -H "X-API-Key: $API_KEY" \
   -d "$JSON_PAYLOAD" \
   $API_HOST/api/data
 